@@ -6,6 +6,7 @@ require (
 	github.com/ghodss/yaml v1.0.0
 	github.com/imdario/mergo v0.3.11
 	github.com/jroimartin/gocui v0.4.0
+	github.com/mattn/go-isatty v0.0.10
 	github.com/mattn/go-runewidth v0.0.9 // indirect
 	github.com/nsf/termbox-go v0.0.0-20200418040025-38ba6e5628f1 // indirect
 	github.com/pkg/errors v0.9.1
@@ -19,6 +20,7 @@ require (
 
 replace (
 	github.com/nsf/termbox-go => github.com/gitlawr/termbox-go v0.0.0-20201103025537-250e644d56a6
+	github.com/rancher/k3os => ./k3os
 	k8s.io/api => github.com/rancher/kubernetes/staging/src/k8s.io/api v1.19.3-k3s1
 	k8s.io/apiextensions-apiserver => github.com/rancher/kubernetes/staging/src/k8s.io/apiextensions-apiserver v1.19.3-k3s1
 	k8s.io/apimachinery => github.com/rancher/kubernetes/staging/src/k8s.io/apimachinery v1.19.3-k3s1
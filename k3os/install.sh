@@ -1,5 +1,8 @@
 #!/bin/bash
 set -e
+# fail on errors of any command of a pipeline, e.g. the copy and the image import
+# piped to the progress reporting
+set -o pipefail
 
 PROG=$0
 PROGS="dd curl mkfs.ext4 mkfs.vfat fatlabel parted partprobe grub-install"
@@ -31,6 +34,17 @@ get_url()
     esac
 }
 
+# progress events parsed by the harvester installer console
+stage()
+{
+    echo "::stage $1"
+}
+
+progress()
+{
+    echo "::progress $1"
+}
+
 cleanup2()
 {
     if [ -n "${TARGET}" ]; then
@@ -75,6 +89,7 @@ do_format()
         return 0
     fi
 
+    stage partition
     dd if=/dev/zero of=${DEVICE} bs=1M count=1
     parted -s ${DEVICE} mklabel ${PARTTABLE}
     if [ "$PARTTABLE" = "gpt" ]; then
@@ -106,11 +121,13 @@ do_format()
     fi
     STATE=${PREFIX}${STATE_NUM}
 
+    stage format
     mkfs.ext4 -F -L K3OS_STATE ${STATE}
     if [ -n "${BOOT}" ]; then
         mkfs.vfat -F 32 ${BOOT}
         fatlabel ${BOOT} K3OS_GRUB
     fi
+    progress 100
 }
 
 do_mount()
@@ -130,7 +147,10 @@ do_mount()
 
 do_copy()
 {
-    tar cf - -C ${DISTRO} k3os | tar xvf - -C ${TARGET}
+    stage copy
+    FILES=$(find ${DISTRO}/k3os | wc -l)
+    tar cf - -C ${DISTRO} k3os | tar xvf - -C ${TARGET} | \
+        awk -v files=$FILES '{ print; if (NR % 10 == 0) printf "::progress %d\n", NR * 50 / files; fflush() }'
     if [ -n "$STATE_NUM" ]; then
         echo $DEVICE $STATE_NUM > $TARGET/k3os/system/growpart
     fi
@@ -153,9 +173,14 @@ do_copy()
     echo "Copying ISO artifacts"
     root_path="${TARGET}/k3os/data"
     mkdir -p "${root_path}"
+    progress 50
     cp -r "${DISTRO}/var" "${root_path}"
+    progress 100
 
+    stage preload
     offline_image_path="var/lib/rancher/k3s/agent/images/harvester-images.tar"
+    # the list of images preloaded in the ISO, for reporting the import progress
+    export IMAGE_COUNT=$(cat "${DISTRO}/harvester-images.txt" 2>/dev/null | grep -c . || true)
     if [ -f "${root_path}/${offline_image_path}.zst" ]; then
         echo "Decompressing container images"
         zstd -d --rm "${root_path}/${offline_image_path}.zst" -o "${root_path}/${offline_image_path}" > /dev/null
@@ -187,7 +212,17 @@ do_copy()
       sleep 1
     done
     # import images
-    ctr -n k8s.io images import /var/lib/rancher/k3s/agent/images/harvester*
+    ctr -n k8s.io images import /var/lib/rancher/k3s/agent/images/harvester* | while read -r line; do
+        echo "$line"
+        case "$line" in
+            unpacking*)
+                imported=$((imported + 1))
+                if [ "$IMAGE_COUNT" -gt 0 ]; then
+                    echo "::progress $((imported * 100 / IMAGE_COUNT))"
+                fi
+                ;;
+        esac
+    done
     rm /var/lib/rancher/k3s/agent/images/harvester*
     # stop containerd
     pkill containerd
@@ -205,6 +240,7 @@ EOF
 
 install_grub()
 {
+    stage grub
     if [ "$K3OS_INSTALL_DEBUG" ]; then
         GRUB_DEBUG="k3os.debug"
     fi
@@ -229,7 +265,7 @@ menuentry "Start Harvester" {
 }
 EOF
     if [ -z "${K3OS_INSTALL_TTY}" ]; then
-        TTY=$(tty | sed 's!/dev/!!')
+        TTY=$(tty | sed 's!/dev/!!' || true)
     else
         TTY=$K3OS_INSTALL_TTY
     fi
@@ -314,6 +350,7 @@ validate_device()
 
 create_opt()
 {
+    stage finalize
     mkdir -p "${TARGET}/k3os/data/opt"
 }
 
@@ -391,6 +428,8 @@ do_copy
 install_grub
 create_opt
 
+progress 100
+
 if [ -n "$INTERACTIVE" ]; then
     exit 0
 fi
@@ -30,6 +30,7 @@ func RunApply(cfg *config.CloudConfig) error {
 		ApplySSHKeysWithNet,
 		ApplyWriteFiles,
 		ApplyEnvironment,
+		ApplyRegistries,
 		ApplyRuncmd,
 		ApplyInstall,
 		ApplyK3SInstall,
@@ -52,6 +53,7 @@ func BootApply(cfg *config.CloudConfig) error {
 		ApplyWifi,
 		ApplyPassword,
 		ApplySSHKeys,
+		ApplyRegistries,
 		ApplyK3SNoRestart,
 		ApplyWriteFiles,
 		ApplyEnvironment,
@@ -16,6 +16,7 @@ import (
 	"github.com/rancher/k3os/pkg/hostname"
 	"github.com/rancher/k3os/pkg/mode"
 	"github.com/rancher/k3os/pkg/module"
+	"github.com/rancher/k3os/pkg/registries"
 	"github.com/rancher/k3os/pkg/ssh"
 	"github.com/rancher/k3os/pkg/sysctl"
 	"github.com/rancher/k3os/pkg/version"
@@ -23,6 +24,16 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
+// proxyEnvironment are the variables of k3os.environment passed to the k3s service
+var proxyEnvironment = []string{
+	"HTTP_PROXY",
+	"HTTPS_PROXY",
+	"NO_PROXY",
+	"http_proxy",
+	"https_proxy",
+	"no_proxy",
+}
+
 func ApplyModules(cfg *config.CloudConfig) error {
 	return module.LoadModules(cfg)
 }
@@ -35,6 +46,10 @@ func ApplyHostname(cfg *config.CloudConfig) error {
 	return hostname.SetHostname(cfg)
 }
 
+func ApplyRegistries(cfg *config.CloudConfig) error {
+	return registries.SetRegistries(cfg)
+}
+
 func ApplyPassword(cfg *config.CloudConfig) error {
 	return command.SetPassword(cfg.K3OS.Password)
 }
@@ -128,6 +143,14 @@ func ApplyK3S(cfg *config.CloudConfig, restart, install bool) error {
 		}
 	}
 
+	// the install script copies the proxy variables to the environment file of
+	// the service, so that k3s and the embedded containerd use the proxy
+	for _, key := range proxyEnvironment {
+		if val, ok := cfg.K3OS.Environment[key]; ok {
+			vars = append(vars, fmt.Sprintf("%s=%s", key, val))
+		}
+	}
+
 	if strings.HasPrefix(cfg.K3OS.Token, "K10") {
 		vars = append(vars, fmt.Sprintf("K3S_TOKEN=%s", cfg.K3OS.Token))
 	} else if cfg.K3OS.Token != "" {
@@ -21,6 +21,39 @@ type K3OS struct {
 	Environment    map[string]string `json:"environment,omitempty"`
 	Taints         []string          `json:"taints,omitempty"`
 	Install        *Install          `json:"install,omitempty"`
+	Registries     *Registries       `json:"registries,omitempty"`
+}
+
+// Registries is the private registry configuration of k3s, which is written to
+// /etc/rancher/k3s/registries.yaml
+type Registries struct {
+	// Mirrors are keyed by registry name, e.g. docker.io
+	Mirrors map[string]Mirror `json:"mirrors,omitempty"`
+	// Configs are keyed by registry host, e.g. harbor.example.com:5000
+	Configs map[string]RegistryConfig `json:"configs,omitempty"`
+}
+
+type Mirror struct {
+	Endpoints []string `json:"endpoint,omitempty"`
+}
+
+type RegistryConfig struct {
+	Auth *RegistryAuth `json:"auth,omitempty"`
+	TLS  *RegistryTLS  `json:"tls,omitempty"`
+}
+
+type RegistryAuth struct {
+	Username      string `json:"username,omitempty"`
+	Password      string `json:"password,omitempty"`
+	Auth          string `json:"auth,omitempty"`
+	IdentityToken string `json:"identityToken,omitempty"`
+}
+
+type RegistryTLS struct {
+	CAFile             string `json:"caFile,omitempty"`
+	CertFile           string `json:"certFile,omitempty"`
+	KeyFile            string `json:"keyFile,omitempty"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
 }
 
 type Wifi struct {
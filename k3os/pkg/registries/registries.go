@@ -0,0 +1,85 @@
+package registries
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	"github.com/rancher/k3os/pkg/config"
+)
+
+const registriesFile = "/etc/rancher/k3s/registries.yaml"
+
+// SetRegistries writes the registries of the config to the file read by k3s on
+// start, an existing file is kept if no registries are configured
+func SetRegistries(cfg *config.CloudConfig) error {
+	if cfg.K3OS.Registries == nil {
+		return nil
+	}
+	data, err := Render(cfg.K3OS.Registries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(registriesFile), 0755); err != nil {
+		return err
+	}
+	// the file holds registry credentials
+	return ioutil.WriteFile(registriesFile, data, 0600)
+}
+
+// Render returns the registries in the format of registries.yaml of k3s, which
+// uses snake case keys
+func Render(r *config.Registries) ([]byte, error) {
+	mirrors := map[string]interface{}{}
+	for name, mirror := range r.Mirrors {
+		mirrors[name] = map[string]interface{}{
+			"endpoint": mirror.Endpoints,
+		}
+	}
+	configs := map[string]interface{}{}
+	for host, registryConfig := range r.Configs {
+		c := map[string]interface{}{}
+		if auth := registryConfig.Auth; auth != nil {
+			c["auth"] = omitEmpty(map[string]interface{}{
+				"username":       auth.Username,
+				"password":       auth.Password,
+				"auth":           auth.Auth,
+				"identity_token": auth.IdentityToken,
+			})
+		}
+		if tls := registryConfig.TLS; tls != nil {
+			c["tls"] = omitEmpty(map[string]interface{}{
+				"ca_file":              tls.CAFile,
+				"cert_file":            tls.CertFile,
+				"key_file":             tls.KeyFile,
+				"insecure_skip_verify": tls.InsecureSkipVerify,
+			})
+		}
+		configs[host] = c
+	}
+	return yaml.Marshal(omitEmpty(map[string]interface{}{
+		"mirrors": mirrors,
+		"configs": configs,
+	}))
+}
+
+func omitEmpty(m map[string]interface{}) map[string]interface{} {
+	for k, v := range m {
+		switch v := v.(type) {
+		case string:
+			if v == "" {
+				delete(m, k)
+			}
+		case bool:
+			if !v {
+				delete(m, k)
+			}
+		case map[string]interface{}:
+			if len(v) == 0 {
+				delete(m, k)
+			}
+		}
+	}
+	return m
+}
@@ -0,0 +1,40 @@
+package registries
+
+import (
+	"testing"
+
+	"github.com/rancher/k3os/pkg/config"
+)
+
+func TestRender(t *testing.T) {
+	data, err := Render(&config.Registries{
+		Mirrors: map[string]config.Mirror{
+			"docker.io": {Endpoints: []string{"https://harbor.example.com"}},
+		},
+		Configs: map[string]config.RegistryConfig{
+			"harbor.example.com": {
+				Auth: &config.RegistryAuth{Username: "admin", Password: "secret"},
+				TLS:  &config.RegistryTLS{CAFile: "/etc/ssl/harbor.pem", InsecureSkipVerify: true},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `configs:
+  harbor.example.com:
+    auth:
+      password: secret
+      username: admin
+    tls:
+      ca_file: /etc/ssl/harbor.pem
+      insecure_skip_verify: true
+mirrors:
+  docker.io:
+    endpoint:
+    - https://harbor.example.com
+`
+	if string(data) != expected {
+		t.Fatalf("got\n%s\nexpected\n%s", data, expected)
+	}
+}
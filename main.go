@@ -1,13 +1,52 @@
 package main
 
 import (
-	"log"
+	"fmt"
+	"io/ioutil"
+	"os"
 
+	"github.com/rancher/harvester-installer/pkg/config"
 	"github.com/rancher/harvester-installer/pkg/console"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "validate" {
+		os.Exit(validate(os.Args[2:]))
+	}
 	if err := console.RunConsole(); err != nil {
-		log.Panicln(err)
+		// the failure is already shown by the console
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// validate checks an install config file is complete and valid for an
+// automatic installation, it prints every invalid field
+func validate(args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: %s validate <file>\n", os.Args[0])
+		return 2
+	}
+	data, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	c, err := config.ToInstallConfig(data)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if err := c.Validate(); err != nil {
+		if errs, ok := err.(config.ValidationErrors); ok {
+			for _, fieldErr := range errs {
+				fmt.Fprintln(os.Stderr, fieldErr)
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		return 1
 	}
+	fmt.Printf("%s is valid\n", args[0])
+	return 0
 }
@@ -0,0 +1,245 @@
+package cluster
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// prefix of the secure tokens of k3s, followed by the hash of the CA of the cluster
+	tokenPrefix = "K10"
+	// user of the node credentials when the token has none
+	defaultUsername = "node"
+)
+
+var (
+	ErrCAMismatch   = errors.New("the CA of the server does not match the cluster token")
+	ErrInvalidToken = errors.New("the cluster token is rejected by the server")
+)
+
+// Token is a cluster token, CAHash is only set for secure tokens of the form
+// K10<CA hash>::<username>:<password>
+type Token struct {
+	CAHash   string
+	Username string
+	Password string
+}
+
+// ParseToken parses a plain or a secure cluster token
+func ParseToken(token string) (*Token, error) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return &Token{Username: defaultUsername, Password: token}, nil
+	}
+	parts := strings.SplitN(strings.TrimPrefix(token, tokenPrefix), "::", 2)
+	if len(parts) != 2 {
+		return nil, errors.New("secure cluster token must be in the form of K10<CA hash>::<credentials>")
+	}
+	if _, err := hex.DecodeString(parts[0]); err != nil || len(parts[0]) != sha256.Size*2 {
+		return nil, errors.Errorf("invalid CA hash %q in cluster token", parts[0])
+	}
+	t := &Token{CAHash: parts[0], Username: defaultUsername, Password: parts[1]}
+	if i := strings.Index(parts[1], ":"); i >= 0 {
+		t.Username, t.Password = parts[1][:i], parts[1][i+1:]
+	}
+	if t.Password == "" {
+		return nil, errors.New("cluster token has no password")
+	}
+	return t, nil
+}
+
+// UnreachableError is returned when the server can't be contacted
+type UnreachableError struct {
+	URL string
+	Err error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("unable to reach %s: %v", e.URL, e.Err)
+}
+
+// ClockSkewError is returned when the clock of the host differs too much from
+// the one of the server, Skew is positive if the host is ahead
+type ClockSkewError struct {
+	Skew time.Duration
+}
+
+func (e *ClockSkewError) Error() string {
+	return fmt.Sprintf("the clock of this host differs from the server by %s, check the date and time settings", e.Skew.Round(time.Second))
+}
+
+// Result is the outcome of a successful check
+type Result struct {
+	// Fingerprint is the SHA256 fingerprint of the CA of the server
+	Fingerprint string
+}
+
+// Checker checks that a node can join the cluster at a server URL with a token.
+// Now is configurable for testing.
+type Checker struct {
+	Timeout      time.Duration
+	MaxClockSkew time.Duration
+	Now          func() time.Time
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		Timeout:      15 * time.Second,
+		MaxClockSkew: 5 * time.Minute,
+		Now:          time.Now,
+	}
+}
+
+// Check fetches the CA of the server, verifies it against the hash of a secure
+// token and authenticates to the server with the credentials of the token
+func (c *Checker) Check(serverURL, token string) (*Result, error) {
+	t, err := ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	caCerts, date, err := c.getCACerts(serverURL, t)
+	if err != nil {
+		return nil, err
+	}
+	if !date.IsZero() {
+		skew := c.Now().Sub(date)
+		if skew > c.MaxClockSkew || -skew > c.MaxClockSkew {
+			return nil, &ClockSkewError{Skew: skew}
+		}
+	}
+	fingerprint, err := GetFingerprint(caCerts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.get(serverURL+"/api/v1/nodes", c.getVerifiedTLSConfig(caCerts), t)
+	if err != nil {
+		var certErr x509.CertificateInvalidError
+		if errors.As(err, &certErr) && certErr.Reason == x509.Expired {
+			return nil, errors.Wrap(err, "the certificate of the server is expired or not yet valid, check the date and time settings")
+		}
+		return nil, errors.Wrap(err, "failed to verify the server against its CA")
+	}
+	defer resp.Body.Close()
+	// the credentials are valid even if the node isn't allowed to list nodes
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, ErrInvalidToken
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusForbidden {
+		return nil, errors.Errorf("got %d status code from %s", resp.StatusCode, resp.Request.URL)
+	}
+	return &Result{Fingerprint: fingerprint}, nil
+}
+
+// GetNodeNames lists the names of the nodes of the cluster, the credentials of
+// the token are only sent to the server verified against its CA like Check does
+func (c *Checker) GetNodeNames(serverURL, token string) ([]string, error) {
+	t, err := ParseToken(token)
+	if err != nil {
+		return nil, err
+	}
+	caCerts, _, err := c.getCACerts(serverURL, t)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.get(serverURL+"/api/v1/nodes", c.getVerifiedTLSConfig(caCerts), t)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to verify the server against its CA")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("got %d status code from %s", resp.StatusCode, resp.Request.URL)
+	}
+	var nodes struct {
+		Items []struct {
+			Metadata struct {
+				Name string `json:"name"`
+			} `json:"metadata"`
+		} `json:"items"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, item := range nodes.Items {
+		names = append(names, item.Metadata.Name)
+	}
+	return names, nil
+}
+
+// getCACerts fetches the CA of the server and the date of the server, which is
+// zero when unknown. The CA is fetched without verification, a secure token
+// pins it by its hash.
+func (c *Checker) getCACerts(serverURL string, t *Token) ([]byte, time.Time, error) {
+	resp, err := c.get(serverURL+"/cacerts", &tls.Config{InsecureSkipVerify: true}, nil)
+	if err != nil {
+		return nil, time.Time{}, &UnreachableError{URL: serverURL, Err: err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, errors.Errorf("got %d status code from %s", resp.StatusCode, resp.Request.URL)
+	}
+	caCerts, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, time.Time{}, &UnreachableError{URL: serverURL, Err: err}
+	}
+	if t.CAHash != "" && t.CAHash != HashCACerts(caCerts) {
+		return nil, time.Time{}, ErrCAMismatch
+	}
+	date, _ := http.ParseTime(resp.Header.Get("Date"))
+	return caCerts, date, nil
+}
+
+func (c *Checker) getVerifiedTLSConfig(caCerts []byte) *tls.Config {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(caCerts)
+	return &tls.Config{RootCAs: pool, Time: c.Now}
+}
+
+func (c *Checker) get(url string, tlsConfig *tls.Config, t *Token) (*http.Response, error) {
+	client := http.Client{
+		Timeout: c.Timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: tlsConfig,
+		},
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if t != nil {
+		req.SetBasicAuth(t.Username, t.Password)
+	}
+	return client.Do(req)
+}
+
+// HashCACerts returns the hash of the CA bundle the way it's put in secure tokens
+func HashCACerts(caCerts []byte) string {
+	hash := sha256.Sum256(caCerts)
+	return hex.EncodeToString(hash[:])
+}
+
+// GetFingerprint returns the SHA256 fingerprint of the first certificate of a
+// PEM bundle, e.g. SHA256:AB:CD:...
+func GetFingerprint(caCerts []byte) (string, error) {
+	block, _ := pem.Decode(caCerts)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return "", errors.New("the server returned no CA certificate")
+	}
+	hash := sha256.Sum256(block.Bytes)
+	parts := make([]string, len(hash))
+	for i, b := range hash {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return "SHA256:" + strings.Join(parts, ":"), nil
+}
@@ -0,0 +1,145 @@
+package cluster
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+const testHash = "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3a94a8fe5ccb19ba61c4c0873"
+
+func TestParseToken(t *testing.T) {
+	testCases := []struct {
+		name     string
+		token    string
+		expected *Token
+		err      bool
+	}{
+		{
+			name:     "plain",
+			token:    "secret",
+			expected: &Token{Username: "node", Password: "secret"},
+		},
+		{
+			name:     "secure",
+			token:    "K10" + testHash + "::server:secret",
+			expected: &Token{CAHash: testHash, Username: "server", Password: "secret"},
+		},
+		{
+			name:     "secure without username",
+			token:    "K10" + testHash + "::secret",
+			expected: &Token{CAHash: testHash, Username: "node", Password: "secret"},
+		},
+		{
+			name:  "missing credentials",
+			token: "K10" + testHash,
+			err:   true,
+		},
+		{
+			name:  "short hash",
+			token: "K10abcd::server:secret",
+			err:   true,
+		},
+		{
+			name:  "empty password",
+			token: "K10" + testHash + "::server:",
+			err:   true,
+		},
+	}
+	for _, testCase := range testCases {
+		token, err := ParseToken(testCase.token)
+		if testCase.err {
+			assert.NotNil(t, err, testCase.name)
+			continue
+		}
+		assert.Nil(t, err, testCase.name)
+		assert.Equal(t, testCase.expected, token, testCase.name)
+	}
+}
+
+func newTestServer(password string) *httptest.Server {
+	var caCerts []byte
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cacerts":
+			w.Write(caCerts)
+		case "/api/v1/nodes":
+			if _, p, ok := r.BasicAuth(); !ok || p != password {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"items":[{"metadata":{"name":"node1"}},{"metadata":{"name":"node2"}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	caCerts = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return server
+}
+
+func TestCheck(t *testing.T) {
+	server := newTestServer("secret")
+	defer server.Close()
+	caCerts := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	fingerprint, err := GetFingerprint(caCerts)
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(fingerprint, "SHA256:"))
+	assert.Len(t, fingerprint, len("SHA256:")+32*3-1)
+
+	c := NewChecker()
+	result, err := c.Check(server.URL, "secret")
+	assert.Nil(t, err)
+	assert.Equal(t, &Result{Fingerprint: fingerprint}, result)
+
+	result, err = c.Check(server.URL, "K10"+HashCACerts(caCerts)+"::node:secret")
+	assert.Nil(t, err)
+	assert.Equal(t, &Result{Fingerprint: fingerprint}, result)
+
+	_, err = c.Check(server.URL, "K10"+testHash+"::node:secret")
+	assert.Equal(t, ErrCAMismatch, err)
+
+	_, err = c.Check(server.URL, "wrong")
+	assert.Equal(t, ErrInvalidToken, err)
+
+	c.Now = func() time.Time {
+		return time.Now().Add(time.Hour)
+	}
+	_, err = c.Check(server.URL, "secret")
+	var skewErr *ClockSkewError
+	assert.True(t, errors.As(err, &skewErr))
+	assert.True(t, skewErr.Skew > 59*time.Minute)
+}
+
+func TestCheckUnreachable(t *testing.T) {
+	server := newTestServer("secret")
+	url := server.URL
+	server.Close()
+
+	_, err := NewChecker().Check(url, "secret")
+	var unreachableErr *UnreachableError
+	assert.True(t, errors.As(err, &unreachableErr))
+	assert.Equal(t, url, unreachableErr.URL)
+}
+
+func TestGetNodeNames(t *testing.T) {
+	server := newTestServer("secret")
+	defer server.Close()
+	caCerts := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	c := NewChecker()
+	names, err := c.GetNodeNames(server.URL, "K10"+HashCACerts(caCerts)+"::node:secret")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"node1", "node2"}, names)
+
+	_, err = c.GetNodeNames(server.URL, "K10"+testHash+"::node:secret")
+	assert.Equal(t, ErrCAMismatch, err, "the credentials aren't sent to a server with another CA")
+
+	_, err = c.GetNodeNames(server.URL, "wrong")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ExpandValues returns chart values with the dotted keys expanded to nested
+// values, e.g. "longhorn.enabled: true" becomes "longhorn: {enabled: true}".
+// A dot in a key is kept when escaped with a backslash.
+func ExpandValues(values map[string]interface{}) (map[string]interface{}, error) {
+	result := map[string]interface{}{}
+	for key, value := range values {
+		if nested, ok := value.(map[string]interface{}); ok {
+			expanded, err := ExpandValues(nested)
+			if err != nil {
+				return nil, err
+			}
+			value = expanded
+		}
+		path := splitKey(key)
+		for _, name := range path {
+			if name == "" {
+				return nil, fmt.Errorf("invalid chart value key %q", key)
+			}
+		}
+		m := result
+		for _, name := range path[:len(path)-1] {
+			if _, ok := m[name]; !ok {
+				m[name] = map[string]interface{}{}
+			}
+			next, ok := m[name].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("chart value %s conflicts with another value", key)
+			}
+			m = next
+		}
+		name := path[len(path)-1]
+		if err := mergeValue(m, name, value); err != nil {
+			return nil, fmt.Errorf("chart value %s conflicts with another value", key)
+		}
+	}
+	return result, nil
+}
+
+// MergeValues deep merges the expanded values of src over the ones of dst, the
+// values of src take precedence. Lists are replaced rather than merged.
+func MergeValues(dst, src map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(dst))
+	for key, value := range dst {
+		result[key] = value
+	}
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]interface{})
+		dstMap, dstIsMap := result[key].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			result[key] = MergeValues(dstMap, srcMap)
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
+
+// mergeValue sets m[name] to value, nested values given by both a dotted and
+// a nested key are merged
+func mergeValue(m map[string]interface{}, name string, value interface{}) error {
+	existing, ok := m[name]
+	if !ok {
+		m[name] = value
+		return nil
+	}
+	existingMap, existingIsMap := existing.(map[string]interface{})
+	valueMap, valueIsMap := value.(map[string]interface{})
+	if !existingIsMap || !valueIsMap {
+		return fmt.Errorf("value %s is set twice", name)
+	}
+	for key, v := range valueMap {
+		if err := mergeValue(existingMap, key, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitKey splits a dotted key on the dots which are not escaped
+func splitKey(key string) []string {
+	var (
+		parts   []string
+		current strings.Builder
+	)
+	for i := 0; i < len(key); i++ {
+		switch {
+		case key[i] == '\\' && i+1 < len(key) && key[i+1] == '.':
+			current.WriteByte('.')
+			i++
+		case key[i] == '.':
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(key[i])
+		}
+	}
+	return append(parts, current.String())
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandValues(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    map[string]interface{}
+		expected map[string]interface{}
+		err      bool
+	}{
+		{
+			name: "dotted and nested keys",
+			input: map[string]interface{}{
+				"longhorn.enabled": true,
+				"longhorn": map[string]interface{}{
+					"defaultSettings.defaultDataPath": "/data",
+				},
+				`service.annotations.metallb\.universe\.tf/address-pool`: "default",
+			},
+			expected: map[string]interface{}{
+				"longhorn": map[string]interface{}{
+					"enabled": true,
+					"defaultSettings": map[string]interface{}{
+						"defaultDataPath": "/data",
+					},
+				},
+				"service": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						"metallb.universe.tf/address-pool": "default",
+					},
+				},
+			},
+		},
+		{
+			name:     "empty",
+			expected: map[string]interface{}{},
+		},
+		{
+			name:  "value and nested value",
+			input: map[string]interface{}{"multus": true, "multus.enabled": true},
+			err:   true,
+		},
+		{
+			name:  "value set twice",
+			input: map[string]interface{}{"multus.enabled": true, "multus": map[string]interface{}{"enabled": false}},
+			err:   true,
+		},
+		{
+			name:  "empty key",
+			input: map[string]interface{}{"multus.": true},
+			err:   true,
+		},
+	}
+	for _, testCase := range testCases {
+		output, err := ExpandValues(testCase.input)
+		if testCase.err {
+			assert.NotNil(t, err, testCase.name)
+			continue
+		}
+		assert.Nil(t, err, testCase.name)
+		assert.Equal(t, testCase.expected, output, testCase.name)
+	}
+}
+
+func TestMergeValues(t *testing.T) {
+	dst := map[string]interface{}{
+		"image": map[string]interface{}{"repository": "rancher/harvester", "tag": "v0.1.0"},
+		"args":  []interface{}{"--debug"},
+	}
+	src := map[string]interface{}{
+		"image": map[string]interface{}{"tag": "master-head"},
+		"args":  []interface{}{"--trace"},
+	}
+	expected := map[string]interface{}{
+		"image": map[string]interface{}{"repository": "rancher/harvester", "tag": "master-head"},
+		"args":  []interface{}{"--trace"},
+	}
+	assert.Equal(t, expected, MergeValues(dst, src))
+	// the values are not changed
+	assert.Equal(t, "v0.1.0", dst["image"].(map[string]interface{})["tag"])
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	cmdlinePrefix = "harvester.install."
+)
+
+// Cmdline is the set of harvester.install.* options passed on the kernel cmdline
+type Cmdline struct {
+	Automatic bool
+	ConfigURL string
+	// TextMode asks the install questions line by line instead of drawing the
+	// panels, for serial consoles
+	TextMode bool
+	// RemoteAPI serves the installation API to remote clients along with the
+	// panels, on RemoteAPIAddress or the default address
+	RemoteAPI        bool
+	RemoteAPIAddress string
+}
+
+// ReadCmdline reads the installer options from the given cmdline file, usually /proc/cmdline
+func ReadCmdline(path string) (*Cmdline, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &Cmdline{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return parseCmdline(string(data)), nil
+}
+
+func parseCmdline(data string) *Cmdline {
+	result := &Cmdline{}
+	for _, item := range strings.Fields(data) {
+		if !strings.HasPrefix(item, cmdlinePrefix) {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimPrefix(item, cmdlinePrefix), "=", 2)
+		value := "true"
+		if len(parts) > 1 {
+			value = strings.Trim(parts[1], `"`)
+		}
+		switch parts[0] {
+		case "automatic":
+			result.Automatic = value == "true"
+		case "config_url":
+			result.ConfigURL = value
+		case "text_mode":
+			result.TextMode = value == "true"
+		case "remote_api":
+			result.RemoteAPI = value == "true"
+		case "remote_api_address":
+			result.RemoteAPIAddress = value
+		}
+	}
+	return result
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCmdline(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		input    string
+		expected *Cmdline
+	}{
+		{
+			Name:     "no harvester options",
+			input:    "printk.devkmsg=on k3os.mode=install console=ttyS0 console=tty1",
+			expected: &Cmdline{},
+		},
+		{
+			Name:  "automatic",
+			input: "k3os.mode=install harvester.install.automatic=true harvester.install.config_url=http://10.0.0.1/harvester.yaml",
+			expected: &Cmdline{
+				Automatic: true,
+				ConfigURL: "http://10.0.0.1/harvester.yaml",
+			},
+		},
+		{
+			Name:  "flag without value",
+			input: "harvester.install.automatic harvester.install.config_url=\"http://10.0.0.1/a.yaml\"",
+			expected: &Cmdline{
+				Automatic: true,
+				ConfigURL: "http://10.0.0.1/a.yaml",
+			},
+		},
+		{
+			Name:  "text mode",
+			input: "console=ttyS0,115200n8 harvester.install.text_mode",
+			expected: &Cmdline{
+				TextMode: true,
+			},
+		},
+		{
+			Name:  "remote API",
+			input: "harvester.install.remote_api harvester.install.remote_api_address=0.0.0.0:9000",
+			expected: &Cmdline{
+				RemoteAPI:        true,
+				RemoteAPIAddress: "0.0.0.0:9000",
+			},
+		},
+		{
+			Name:     "disabled",
+			input:    "harvester.install.automatic=false",
+			expected: &Cmdline{},
+		},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, parseCmdline(testCase.input), testCase.Name)
+	}
+}
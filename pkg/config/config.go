@@ -4,14 +4,90 @@ import (
 	"github.com/rancher/k3os/pkg/config"
 )
 
+const (
+	// Version is the current version of the harvester section
+	Version = "v1"
+)
+
 var (
 	Config = InstallConfig{}
 )
 
+// InstallConfig is a k3OS cloud-config with a harvester section, e.g.
+//
+//	harvester:
+//	  version: v1
+//	  install_mode: join
+//	  ssh_key_url: https://github.com/username.keys
+//	  network:
+//	    interface: eth0
+//	k3os:
+//	  token: TOKEN_VALUE
 type InstallConfig struct {
 	config.CloudConfig
+	Harvester `json:"harvester,omitempty"`
+}
+
+// Harvester holds the Harvester install options
+type Harvester struct {
+	// Version of the section, defaults to the current version
+	Version      string   `json:"version,omitempty"`
+	ExtraK3sArgs []string `json:"extraK3sArgs,omitempty"`
+	InstallMode  string   `json:"installMode,omitempty"`
+	SSHKeyURL    string   `json:"sshKeyUrl,omitempty"`
+	Network      Network  `json:"network,omitempty"`
+	// DataDisks are formatted and used by Longhorn to store volumes
+	DataDisks []string `json:"dataDisks,omitempty"`
+	// SkipChecks allows installing on hosts failing the preflight checks
+	SkipChecks bool `json:"skipChecks,omitempty"`
+	// Chart customizes the Harvester Helm chart installed in create mode
+	Chart *Chart `json:"chart,omitempty"`
+	// Addons are deployed with the cluster in create mode, in the order given
+	Addons []Addon `json:"addons,omitempty"`
+}
+
+// Addon is a Kubernetes manifest deployed with the cluster, its source is one
+// of Content, URL and Path
+type Addon struct {
+	Name string `json:"name,omitempty"`
+	// Content is the inline YAML of the manifest
+	Content string `json:"content,omitempty"`
+	URL     string `json:"url,omitempty"`
+	// Path of the manifest on the installer media, relative to its root
+	Path string `json:"path,omitempty"`
+}
+
+// Chart overrides the Harvester Helm chart and its values
+type Chart struct {
+	// URL of the chart, defaults to the chart bundled with the installer
+	URL string `json:"url,omitempty"`
+	// Version of the chart, which selects the bundled chart of this version
+	// when no URL is given
+	Version string `json:"version,omitempty"`
+	// Values are merged over the default values. Keys may be nested or dotted
+	// paths like "longhorn.enabled", dots in keys are escaped with a backslash.
+	Values map[string]interface{} `json:"values,omitempty"`
+}
+
+// Network is the configuration of the management network interface
+type Network struct {
+	Interface string `json:"interface,omitempty"`
+	// Method is dhcp or static, an interface without a method uses dhcp. It
+	// is left empty when not set, so that the wizard still asks for it.
+	Method  string `json:"method,omitempty"`
+	IP      string `json:"ip,omitempty"`
+	Gateway string `json:"gateway,omitempty"`
+}
+
+// legacyInstallConfig has the Harvester install options as top level keys,
+// the format used before the harvester section was introduced
+type legacyInstallConfig struct {
+	config.CloudConfig
+	Harvester
+}
 
-	ExtraK3sArgs []string
-	InstallMode string
-	SSHKeyURL   string
+func setDefaults(c *InstallConfig) {
+	if c.Version == "" {
+		c.Version = Version
+	}
 }
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 
 	"github.com/ghodss/yaml"
+	"github.com/imdario/mergo"
 	"github.com/rancher/k3os/pkg/config"
 	"github.com/rancher/mapper"
 	"github.com/rancher/mapper/convert"
@@ -20,8 +23,10 @@ var (
 			}
 		}
 		return s
-	}).MustImport(config.CloudConfig{})
-	schema = schemas.Schema("cloudConfig")
+	}).MustImport(config.CloudConfig{}).MustImport(InstallConfig{}).MustImport(legacyInstallConfig{})
+	schema        = schemas.Schema("cloudConfig")
+	installSchema = schemas.Schema("installConfig")
+	legacySchema  = schemas.Schema("legacyInstallConfig")
 )
 
 func ToCloudConfig(yamlBytes []byte) (*config.CloudConfig, error) {
@@ -33,3 +38,77 @@ func ToCloudConfig(yamlBytes []byte) (*config.CloudConfig, error) {
 	schema.Mapper.ToInternal(data)
 	return result, convert.ToObj(data, result)
 }
+
+// ToInstallConfig parses a k3OS cloud-config carrying the Harvester install
+// options in the harvester section. Options given as top level keys, as in
+// configs written before the section was introduced, are still accepted but
+// the ones in the section take precedence.
+func ToInstallConfig(yamlBytes []byte) (*InstallConfig, error) {
+	result := &InstallConfig{}
+	data := map[string]interface{}{}
+	if err := yaml.Unmarshal(yamlBytes, &data); err != nil {
+		return result, fmt.Errorf("failed to unmarshal yaml: %v", err)
+	}
+	// the mappers change the data in place, so the legacy keys are read from a copy
+	legacyData := map[string]interface{}{}
+	if err := yaml.Unmarshal(yamlBytes, &legacyData); err != nil {
+		return result, fmt.Errorf("failed to unmarshal yaml: %v", err)
+	}
+
+	installSchema.Mapper.ToInternal(data)
+	if err := convert.ToObj(data, result); err != nil {
+		return result, err
+	}
+	legacy := &legacyInstallConfig{}
+	legacySchema.Mapper.ToInternal(legacyData)
+	if err := convert.ToObj(legacyData, legacy); err != nil {
+		return result, err
+	}
+	if err := mergo.Merge(&result.Harvester, legacy.Harvester); err != nil {
+		return result, err
+	}
+
+	if result.Version != "" && result.Version != Version {
+		return result, fmt.Errorf("unsupported harvester config version %q, expected %q", result.Version, Version)
+	}
+	setDefaults(result)
+	return result, nil
+}
+
+// FromInstallConfig marshals c to YAML with the Harvester install options in
+// a versioned harvester section. Keys are sorted, so the output is stable.
+// Empty sections like "network: {}" are left out.
+func FromInstallConfig(c *InstallConfig) ([]byte, error) {
+	result := *c
+	setDefaults(&result)
+	data, err := json.Marshal(&result)
+	if err != nil {
+		return nil, err
+	}
+	// numbers are kept as they are, e.g. large integers of chart values
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	values := map[string]interface{}{}
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+	if data, err = json.Marshal(removeEmptySections(values)); err != nil {
+		return nil, err
+	}
+	return yaml.JSONToYAML(data)
+}
+
+// removeEmptySections removes the maps without any values from values, the
+// sections of structs are marshalled even if all their fields are empty
+func removeEmptySections(values map[string]interface{}) map[string]interface{} {
+	for key, value := range values {
+		section, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if len(removeEmptySections(section)) == 0 {
+			delete(values, key)
+		}
+	}
+	return values
+}
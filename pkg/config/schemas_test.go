@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/rancher/k3os/pkg/config"
@@ -132,3 +133,251 @@ k3os:
 		assert.Equal(t, testCase.err, err)
 	}
 }
+
+func TestToInstallConfig(t *testing.T) {
+	testCases := []struct {
+		input    []byte
+		expected *InstallConfig
+		err      error
+	}{
+		{
+			input: []byte(`install_mode: join
+extra_k3s_args:
+- "--flannel-iface"
+- eth1
+ssh_key_url: https://github.com/username.keys
+skip_checks: true
+network:
+  interface: eth1
+  method: static
+  ip: 192.168.1.10/24
+  gateway: 192.168.1.1
+k3os:
+  password: rancher
+  server_url: https://someserver:6443
+  token: TOKEN_VALUE
+  install:
+    device: /dev/vda
+    power_off: true
+`),
+			expected: &InstallConfig{
+				CloudConfig: config.CloudConfig{
+					K3OS: config.K3OS{
+						Password:  "rancher",
+						ServerURL: "https://someserver:6443",
+						Token:     "TOKEN_VALUE",
+						Install: &config.Install{
+							Device:   "/dev/vda",
+							PowerOff: true,
+						},
+					},
+				},
+				Harvester: Harvester{
+					Version:      "v1",
+					ExtraK3sArgs: []string{"--flannel-iface", "eth1"},
+					InstallMode:  "join",
+					SSHKeyURL:    "https://github.com/username.keys",
+					Network: Network{
+						Interface: "eth1",
+						Method:    "static",
+						IP:        "192.168.1.10/24",
+						Gateway:   "192.168.1.1",
+					},
+					SkipChecks: true,
+				},
+			},
+			err: nil,
+		},
+		{
+			input: []byte(`harvester:
+  version: v1
+  install_mode: create
+  extra_k3s_args:
+  - "--flannel-iface"
+  - eth0
+  ssh_key_url: https://github.com/username.keys
+  network:
+    interface: eth0
+  data_disks:
+  - /dev/sdb
+  - /dev/sdc
+  skip_checks: true
+k3os:
+  token: TOKEN_VALUE
+`),
+			expected: &InstallConfig{
+				CloudConfig: config.CloudConfig{
+					K3OS: config.K3OS{
+						Token: "TOKEN_VALUE",
+					},
+				},
+				Harvester: Harvester{
+					Version:      "v1",
+					ExtraK3sArgs: []string{"--flannel-iface", "eth0"},
+					InstallMode:  "create",
+					SSHKeyURL:    "https://github.com/username.keys",
+					Network: Network{
+						// the method is asked by the wizard
+						Interface: "eth0",
+					},
+					DataDisks:  []string{"/dev/sdb", "/dev/sdc"},
+					SkipChecks: true,
+				},
+			},
+			err: nil,
+		},
+		{
+			input: []byte(`harvester:
+  installMode: create
+  sshKeyUrl: https://github.com/username.keys
+install_mode: join
+data_disks: /dev/sdb
+`),
+			expected: &InstallConfig{
+				Harvester: Harvester{
+					Version:     "v1",
+					InstallMode: "create",
+					SSHKeyURL:   "https://github.com/username.keys",
+					DataDisks:   []string{"/dev/sdb"},
+				},
+			},
+			err: nil,
+		},
+		{
+			input: []byte(`hostname: myhost
+`),
+			expected: &InstallConfig{
+				CloudConfig: config.CloudConfig{
+					Hostname: "myhost",
+				},
+				Harvester: Harvester{
+					Version: "v1",
+				},
+			},
+			err: nil,
+		},
+		{
+			input: []byte(`harvester:
+  install_mode: create
+k3os:
+  registries:
+    mirrors:
+      docker.io:
+        endpoint: https://harbor.example.com
+    configs:
+      harbor.example.com:
+        auth:
+          username: admin
+          password: secret
+        tls:
+          ca_file: /etc/ssl/harbor.pem
+          insecure_skip_verify: true
+`),
+			expected: &InstallConfig{
+				CloudConfig: config.CloudConfig{
+					K3OS: config.K3OS{
+						Registries: &config.Registries{
+							Mirrors: map[string]config.Mirror{
+								"docker.io": {Endpoints: []string{"https://harbor.example.com"}},
+							},
+							Configs: map[string]config.RegistryConfig{
+								"harbor.example.com": {
+									Auth: &config.RegistryAuth{Username: "admin", Password: "secret"},
+									TLS:  &config.RegistryTLS{CAFile: "/etc/ssl/harbor.pem", InsecureSkipVerify: true},
+								},
+							},
+						},
+					},
+				},
+				Harvester: Harvester{
+					Version:     "v1",
+					InstallMode: "create",
+				},
+			},
+			err: nil,
+		},
+		{
+			input: []byte(`harvester:
+  version: v2
+  install_mode: create
+`),
+			expected: &InstallConfig{
+				Harvester: Harvester{
+					Version:     "v2",
+					InstallMode: "create",
+				},
+			},
+			err: fmt.Errorf(`unsupported harvester config version "v2", expected "v1"`),
+		},
+	}
+
+	for _, testCase := range testCases {
+		output, err := ToInstallConfig(testCase.input)
+		assert.Equal(t, testCase.expected, output)
+		assert.Equal(t, testCase.err, err)
+	}
+}
+
+func TestFromInstallConfig(t *testing.T) {
+	c := &InstallConfig{
+		CloudConfig: config.CloudConfig{
+			Hostname: "myhost",
+			K3OS: config.K3OS{
+				Token:          "TOKEN_VALUE",
+				DNSNameservers: []string{"8.8.8.8"},
+			},
+		},
+		Harvester: Harvester{
+			InstallMode: "join",
+			Network: Network{
+				Interface: "eth0",
+			},
+			DataDisks: []string{"/dev/sdb"},
+		},
+	}
+	expected := `harvester:
+  dataDisks:
+  - /dev/sdb
+  installMode: join
+  network:
+    interface: eth0
+  version: v1
+hostname: myhost
+k3os:
+  dnsNameservers:
+  - 8.8.8.8
+  token: TOKEN_VALUE
+`
+	output, err := FromInstallConfig(c)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(output))
+	// c is not changed
+	assert.Equal(t, "", c.Version)
+
+	parsed, err := ToInstallConfig(output)
+	assert.Nil(t, err)
+	again, err := FromInstallConfig(parsed)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(again))
+}
+
+func TestFromInstallConfigEmptySections(t *testing.T) {
+	c := &InstallConfig{
+		Harvester: Harvester{
+			InstallMode: "create",
+			Chart: &Chart{
+				Values: map[string]interface{}{"minio.persistence.size": 1000000},
+			},
+		},
+	}
+	expected := `harvester:
+  chart:
+    values:
+      minio.persistence.size: 1000000
+  installMode: create
+  version: v1
+`
+	output, err := FromInstallConfig(c)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, string(output))
+}
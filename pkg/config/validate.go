@@ -0,0 +1,553 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ghodss/yaml"
+	"github.com/rancher/k3os/pkg/config"
+)
+
+const (
+	ModeCreate = "create"
+	ModeJoin   = "join"
+
+	NetworkMethodDHCP   = "dhcp"
+	NetworkMethodStatic = "static"
+
+	// tokens entered by the user must be at least MinTokenLength characters
+	MinTokenLength = 16
+	// TokenPlaceholder replaces the cluster token in saved answers, it has to be
+	// replaced with the token before installing
+	TokenPlaceholder = "<cluster token>"
+
+	// fields of the install config in the form of their YAML paths
+	FieldInstallMode  = "harvester.install_mode"
+	FieldExtraK3sArgs = "harvester.extra_k3s_args"
+	FieldSSHKeyURL    = "harvester.ssh_key_url"
+	FieldNetwork      = "harvester.network"
+	FieldDataDisks    = "harvester.data_disks"
+	FieldChart        = "harvester.chart"
+	FieldAddons       = "harvester.addons"
+	FieldHostname     = "hostname"
+	FieldDevice       = "k3os.install.device"
+	FieldToken        = "k3os.token"
+	FieldServerURL    = "k3os.server_url"
+	FieldPassword     = "k3os.password"
+	FieldDNSServers   = "k3os.dns_nameservers"
+	FieldNTPServers   = "k3os.ntp_servers"
+	FieldEnvironment  = "k3os.environment"
+	FieldRegistries   = "k3os.registries"
+)
+
+var (
+	domainNameRegexp = regexp.MustCompile(`^(?i)[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?(\.[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?)*$`)
+	hostnameRegexp   = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`)
+	// chart versions are part of the file name of bundled charts
+	chartVersionRegexp = regexp.MustCompile(`^v?[0-9A-Za-z][-0-9A-Za-z.+]*$`)
+	// placeholder of the address of the Kubernetes API in chart URLs, which is
+	// replaced by the Helm controller of k3s
+	chartURLPlaceholders = strings.NewReplacer("%{KUBERNETES_API}%", "localhost")
+	// separator of the documents of a YAML stream
+	manifestSeparator = regexp.MustCompile(`(?m)^---[ \t]*$`)
+	// placeholders of hostname templates and values of the same kind for validation
+	hostnamePlaceholders = strings.NewReplacer("{mac}", "000000000000", "{serial}", "0")
+	// environment variables holding proxy URLs
+	proxyVariables = []string{"http_proxy", "https_proxy", "HTTP_PROXY", "HTTPS_PROXY"}
+	// environment variables holding the addresses that bypass the proxies
+	noProxyVariables = []string{"no_proxy", "NO_PROXY"}
+	// k3s arguments set by the installer from other fields
+	managedK3sArgs = map[string]string{
+		"--token":      FieldToken,
+		"-t":           FieldToken,
+		"--token-file": FieldToken,
+		"--server":     FieldServerURL,
+		"-s":           FieldServerURL,
+	}
+)
+
+// FieldError is an invalid field of the install config. Message is a sentence
+// which is shown next to the input of the field.
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ValidationErrors are the invalid fields of the install config
+type ValidationErrors []*FieldError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Validate checks the config is complete for installing without asking, it
+// returns ValidationErrors listing every invalid field
+func (c *InstallConfig) Validate() error {
+	var errs ValidationErrors
+	check := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, &FieldError{Field: field, Message: err.Error()})
+		}
+	}
+
+	var device string
+	if c.K3OS.Install != nil {
+		device = c.K3OS.Install.Device
+	}
+	check(FieldInstallMode, ValidateInstallMode(c.InstallMode))
+	check(FieldDevice, ValidateDevice(device))
+	check(FieldDataDisks, ValidateDataDisks(c.DataDisks, device))
+	check(FieldToken, ValidateToken(c.InstallMode, c.K3OS.Token))
+	if c.InstallMode == ModeJoin {
+		check(FieldServerURL, ValidateServerURL(c.K3OS.ServerURL))
+	}
+	check(FieldPassword, ValidatePassword(c.K3OS.Password))
+	if c.SSHKeyURL != "" {
+		check(FieldSSHKeyURL, ValidateSSHKeyURL(c.SSHKeyURL))
+	}
+	if c.Hostname != "" {
+		check(FieldHostname, ValidateHostname(hostnamePlaceholders.Replace(c.Hostname)))
+	}
+	check(FieldNetwork, ValidateNetwork(c.Network))
+	check(FieldDNSServers, ValidateDNSServers(c.K3OS.DNSNameservers))
+	check(FieldNTPServers, ValidateNTPServers(c.K3OS.NTPServers))
+	check(FieldExtraK3sArgs, ValidateK3sArgs(c.ExtraK3sArgs))
+	if c.Chart != nil {
+		check(FieldChart, ValidateChart(c.Chart))
+	}
+	for i, addon := range c.Addons {
+		check(fmt.Sprintf("%s[%d]", FieldAddons, i), ValidateAddon(addon))
+	}
+	if c.K3OS.Registries != nil {
+		check(FieldRegistries, ValidateRegistries(c.K3OS.Registries))
+	}
+
+	var keys []string
+	for key := range c.K3OS.Environment {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, proxyVariable := range proxyVariables {
+			if key == proxyVariable {
+				check(FieldEnvironment+"."+key, ValidateProxyURL(c.K3OS.Environment[key]))
+			}
+		}
+		for _, noProxyVariable := range noProxyVariables {
+			if key == noProxyVariable {
+				check(FieldEnvironment+"."+key, ValidateNoProxy(c.K3OS.Environment[key]))
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func ValidateInstallMode(mode string) error {
+	if mode != ModeCreate && mode != ModeJoin {
+		return fmt.Errorf("Install mode must be %q or %q, got %q", ModeCreate, ModeJoin, mode)
+	}
+	return nil
+}
+
+func ValidateDevice(device string) error {
+	if device == "" {
+		return fmt.Errorf("Installation target is required")
+	}
+	if !strings.HasPrefix(device, "/dev/") {
+		return fmt.Errorf("Installation target must be a device path like /dev/sda, got %q", device)
+	}
+	return nil
+}
+
+// ValidateToken checks the cluster token, which is required to join a cluster.
+// A new cluster gets a random token if none is given.
+func ValidateToken(mode, token string) error {
+	if token == TokenPlaceholder {
+		return fmt.Errorf("Cluster token is not set, replace the placeholder %q with the cluster token", TokenPlaceholder)
+	}
+	if token == "" {
+		if mode == ModeJoin {
+			return fmt.Errorf("Cluster token is required")
+		}
+		return nil
+	}
+	if mode != ModeCreate {
+		return nil
+	}
+	if len(token) < MinTokenLength {
+		return fmt.Errorf("Cluster token must be at least %d characters", MinTokenLength)
+	}
+	var hasLetter, hasDigit bool
+	for _, r := range token {
+		switch {
+		case r > unicode.MaxASCII || !unicode.IsPrint(r) || unicode.IsSpace(r) || r == ':':
+			return fmt.Errorf("Cluster token must consist of printable ASCII characters except spaces and ':'")
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return fmt.Errorf("Cluster token must contain both letters and digits")
+	}
+	return nil
+}
+
+// ValidateServerURL checks the address of the cluster to join, which is an
+// HTTPS URL or a bare host name or IP address
+func ValidateServerURL(serverURL string) error {
+	if serverURL == "" {
+		return fmt.Errorf("Management address is required")
+	}
+	if ip := net.ParseIP(serverURL); ip != nil && ip.To4() == nil {
+		// an IPv6 address without port
+		serverURL = "[" + serverURL + "]"
+	}
+	if !strings.Contains(serverURL, "://") {
+		serverURL = "https://" + serverURL
+	}
+	u, err := url.Parse(serverURL)
+	if err != nil || u.Scheme != "https" || (u.Path != "" && u.Path != "/") || !isHost(u.Hostname()) {
+		return fmt.Errorf("Invalid management address %q, it should be an IP address or a domain name", serverURL)
+	}
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("Invalid port %q in management address", port)
+		}
+	}
+	return nil
+}
+
+func ValidatePassword(password string) error {
+	if password == "" {
+		return fmt.Errorf("Password is required")
+	}
+	return nil
+}
+
+// ValidateProxyURL checks a proxy address like http://[[user][:pass]@]host[:port]/
+func ValidateProxyURL(proxy string) error {
+	u, err := url.Parse(proxy)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5") || !isHost(u.Hostname()) {
+		return fmt.Errorf("Invalid proxy address %q, it should be in the form of http://[[user][:pass]@]host[:port]/", MaskPassword(proxy))
+	}
+	return nil
+}
+
+// ValidateNoProxy checks a comma separated list of addresses that bypass the
+// proxy, which are IP addresses, CIDRs, domain names with an optional leading
+// dot and host:port pairs
+func ValidateNoProxy(noProxy string) error {
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" || entry == "*" {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(entry); err == nil {
+			continue
+		}
+		host := entry
+		if h, port, err := net.SplitHostPort(entry); err == nil {
+			if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+				return fmt.Errorf("Invalid port %q in no proxy address %q", port, entry)
+			}
+			host = h
+		}
+		if !isHost(strings.TrimPrefix(strings.TrimPrefix(host, "*"), ".")) {
+			return fmt.Errorf("Invalid no proxy address %q, it should be an IP address, a CIDR or a domain name", entry)
+		}
+	}
+	return nil
+}
+
+// ValidateRegistries checks the registry mirrors and the settings of registry
+// hosts, which are written to registries.yaml of k3s
+func ValidateRegistries(r *config.Registries) error {
+	// sorted, so that the same error is reported every time
+	var names, hosts []string
+	for name := range r.Mirrors {
+		names = append(names, name)
+	}
+	for host := range r.Configs {
+		hosts = append(hosts, host)
+	}
+	sort.Strings(names)
+	sort.Strings(hosts)
+
+	for _, name := range names {
+		if !isHostPort(name) {
+			return fmt.Errorf("Invalid registry name %q, it should be a domain name with an optional port", name)
+		}
+		if len(r.Mirrors[name].Endpoints) == 0 {
+			return fmt.Errorf("Registry mirror for %s has no endpoint", name)
+		}
+		for _, endpoint := range r.Mirrors[name].Endpoints {
+			if err := ValidateRegistryEndpoint(endpoint); err != nil {
+				return err
+			}
+		}
+	}
+	for _, host := range hosts {
+		if !isHostPort(host) {
+			return fmt.Errorf("Invalid registry host %q, it should be a domain name or an IP address with an optional port", host)
+		}
+		registryConfig := r.Configs[host]
+		if auth := registryConfig.Auth; auth != nil && auth.Username != "" && auth.Password == "" {
+			return fmt.Errorf("Registry %s has a username without a password", host)
+		}
+		if tls := registryConfig.TLS; tls != nil && (tls.CertFile == "") != (tls.KeyFile == "") {
+			return fmt.Errorf("Registry %s needs both a client certificate and a key file", host)
+		}
+	}
+	return nil
+}
+
+// ValidateRegistryEndpoint checks a registry mirror endpoint is an HTTP or HTTPS URL
+func ValidateRegistryEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || !isHostPort(u.Host) {
+		return fmt.Errorf("Invalid registry endpoint %q, it should be in the form of https://host[:port]", endpoint)
+	}
+	return nil
+}
+
+func ValidateSSHKeyURL(keyURL string) error {
+	u, err := url.Parse(keyURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Invalid SSH key URL %q, it should be an HTTP or HTTPS URL", keyURL)
+	}
+	return nil
+}
+
+// ValidateHostname checks name is a valid RFC 1123 DNS label usable as node name
+func ValidateHostname(name string) error {
+	if name == "" {
+		return fmt.Errorf("Hostname is required")
+	}
+	if len(name) > 63 {
+		return fmt.Errorf("Hostname must be no more than 63 characters")
+	}
+	if !hostnameRegexp.MatchString(name) {
+		return fmt.Errorf("Hostname must consist of lower case alphanumeric characters or '-', and start and end with an alphanumeric character")
+	}
+	return nil
+}
+
+// ValidateNetwork checks the method of network, which is dhcp when not set. A
+// static address is configured for the MAC of the interface, which is required.
+func ValidateNetwork(network Network) error {
+	switch network.Method {
+	case "", NetworkMethodDHCP:
+		return nil
+	case NetworkMethodStatic:
+		if network.Interface == "" {
+			return fmt.Errorf("Management NIC is required for a static IP")
+		}
+		return ValidateStaticIP(network.IP, network.Gateway)
+	}
+	return fmt.Errorf("Network method must be %q or %q, got %q", NetworkMethodDHCP, NetworkMethodStatic, network.Method)
+}
+
+// ValidateStaticIP checks address is an IPv4 address in CIDR notation and gateway
+// is an IPv4 address in the same subnet
+func ValidateStaticIP(address, gateway string) error {
+	ip, ipNet, err := net.ParseCIDR(address)
+	if err != nil {
+		return fmt.Errorf("Invalid IPv4 address %q, it should be in CIDR notation like 192.168.1.10/24", address)
+	}
+	if ip.To4() == nil {
+		return fmt.Errorf("Only IPv4 address is supported, got %q", address)
+	}
+	gatewayIP := net.ParseIP(gateway)
+	if gatewayIP == nil || gatewayIP.To4() == nil {
+		return fmt.Errorf("Invalid IPv4 gateway %q", gateway)
+	}
+	if !ipNet.Contains(gatewayIP) {
+		return fmt.Errorf("Gateway %s is not in subnet %s", gateway, ipNet.String())
+	}
+	return nil
+}
+
+func ValidateDNSServers(servers []string) error {
+	for _, server := range servers {
+		if net.ParseIP(server) == nil {
+			return fmt.Errorf("Invalid DNS server address %q", server)
+		}
+	}
+	return nil
+}
+
+// ValidateNTPServers checks servers are IP addresses or domain names
+func ValidateNTPServers(servers []string) error {
+	for _, server := range servers {
+		if !isHost(server) {
+			return fmt.Errorf("Invalid NTP server %q", server)
+		}
+	}
+	return nil
+}
+
+// ValidateDataDisks checks data disks are distinct from each other and the install device
+func ValidateDataDisks(dataDisks []string, installDevice string) error {
+	seen := map[string]bool{}
+	for _, d := range dataDisks {
+		if d == installDevice {
+			return fmt.Errorf("Data disk %s is the installation target", d)
+		}
+		if seen[d] {
+			return fmt.Errorf("Data disk %s is listed more than once", d)
+		}
+		seen[d] = true
+	}
+	return nil
+}
+
+// ValidateChart checks the chart URL and version overrides and that the values
+// are expanded without conflicting keys
+func ValidateChart(chart *Chart) error {
+	if chart.URL != "" {
+		u, err := url.Parse(chartURLPlaceholders.Replace(chart.URL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Invalid chart URL %q, it should be an HTTP or HTTPS URL", chart.URL)
+		}
+	}
+	if chart.Version != "" && !chartVersionRegexp.MatchString(chart.Version) {
+		return fmt.Errorf("Invalid chart version %q", chart.Version)
+	}
+	if _, err := ExpandValues(chart.Values); err != nil {
+		return fmt.Errorf("Invalid chart values: %v", err)
+	}
+	return nil
+}
+
+// ValidateAddon checks an addon has a valid name and exactly one source, inline
+// content is checked to be Kubernetes YAML
+func ValidateAddon(addon Addon) error {
+	if addon.Name != "" && !hostnameRegexp.MatchString(addon.Name) {
+		return fmt.Errorf("Addon name %q must consist of lower case alphanumeric characters or '-'", addon.Name)
+	}
+	sources := 0
+	for _, source := range []string{addon.Content, addon.URL, addon.Path} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("Addon %s must have exactly one of content, url and path", addon.Name)
+	}
+	switch {
+	case addon.URL != "":
+		u, err := url.Parse(addon.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Invalid addon URL %q, it should be an HTTP or HTTPS URL", addon.URL)
+		}
+	case addon.Path != "":
+		if strings.Contains(addon.Path, "..") {
+			return fmt.Errorf("Invalid addon path %q, it should be a path on the installer media", addon.Path)
+		}
+	default:
+		if err := ValidateManifest([]byte(addon.Content)); err != nil {
+			return fmt.Errorf("Addon %s is invalid: %v", addon.Name, err)
+		}
+	}
+	return nil
+}
+
+// ValidateManifest checks data is Kubernetes YAML, each document of it must be
+// an object with an API version, a kind and a name
+func ValidateManifest(data []byte) error {
+	objects := 0
+	for i, doc := range manifestSeparator.Split(string(data), -1) {
+		obj := map[string]interface{}{}
+		if err := yaml.Unmarshal([]byte(doc), &obj); err != nil {
+			return fmt.Errorf("document %d is not a YAML object: %v", i+1, err)
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		objects++
+		metadata, _ := obj["metadata"].(map[string]interface{})
+		fields := []string{"apiVersion", "kind", "metadata.name"}
+		for j, value := range []interface{}{obj["apiVersion"], obj["kind"], metadata["name"]} {
+			if s, ok := value.(string); !ok || s == "" {
+				return fmt.Errorf("document %d has no %s", i+1, fields[j])
+			}
+		}
+	}
+	if objects == 0 {
+		return fmt.Errorf("no Kubernetes object found")
+	}
+	return nil
+}
+
+// ValidateK3sArgs checks the extra k3s arguments are flags, the k3s command and
+// the flags of the cluster to join are set by the installer
+func ValidateK3sArgs(args []string) error {
+	for i, arg := range args {
+		if arg == "" {
+			return fmt.Errorf("K3s arguments must not be empty")
+		}
+		if i == 0 && !strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("K3s arguments must start with a flag, got %q", arg)
+		}
+		name := strings.SplitN(arg, "=", 2)[0]
+		if field, ok := managedK3sArgs[name]; ok {
+			return fmt.Errorf("K3s argument %s is set by the installer from %s", name, field)
+		}
+	}
+	return nil
+}
+
+// IsDomainName checks name is a RFC 1123 domain name
+func IsDomainName(name string) bool {
+	return len(name) <= 253 && domainNameRegexp.MatchString(name)
+}
+
+func isHost(host string) bool {
+	return net.ParseIP(host) != nil || IsDomainName(host)
+}
+
+// isHostPort checks value is a host with an optional port
+func isHostPort(value string) bool {
+	host, port, err := net.SplitHostPort(value)
+	if err != nil {
+		return isHost(value)
+	}
+	n, err := strconv.Atoi(port)
+	return err == nil && n > 0 && n <= 65535 && isHost(host)
+}
+
+// MaskPassword replaces the password of the user info of a URL, other values are
+// returned as they are
+func MaskPassword(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	if _, ok := u.User.Password(); !ok {
+		return value
+	}
+	// asterisks would be escaped in the user info, so they are added afterwards
+	u.User = url.User(u.User.Username())
+	user := "//" + u.User.String() + "@"
+	return strings.Replace(u.String(), user, strings.TrimSuffix(user, "@")+":*****@", 1)
+}
@@ -0,0 +1,118 @@
+package console
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/rancher/harvester-installer/pkg/disk"
+	"github.com/rancher/k3os/pkg/config"
+)
+
+const answersHeader = `# Harvester install config saved by the installer.
+# Secrets are removed: wifi passphrases, proxy and registry passwords. The password is hashed.
+# Replace the placeholder of k3os.token with the cluster token before installing with this config.
+`
+
+// getAnswers returns the answers of the wizard as an install config without
+// secrets, which is accepted by pre-seeded and automatic installations
+func getAnswers(c *cfg.InstallConfig) ([]byte, error) {
+	answers, err := copyConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	if err := encryptPassword(answers); err != nil {
+		return nil, err
+	}
+	if answers.K3OS.Token != "" {
+		answers.K3OS.Token = cfg.TokenPlaceholder
+	}
+	for i := range answers.K3OS.Wifi {
+		answers.K3OS.Wifi[i].Passphrase = ""
+	}
+	for key, value := range answers.K3OS.Environment {
+		answers.K3OS.Environment[key] = redactURL(value)
+	}
+	if answers.K3OS.Registries != nil {
+		for host, registryConfig := range answers.K3OS.Registries.Configs {
+			if registryConfig.Auth != nil {
+				registryConfig.Auth = &config.RegistryAuth{Username: registryConfig.Auth.Username}
+				answers.K3OS.Registries.Configs[host] = registryConfig
+			}
+		}
+	}
+	data, err := cfg.FromInstallConfig(answers)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(answersHeader), data...), nil
+}
+
+// redactURL removes the password of the user info of a URL, other values are
+// returned as they are
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.User == nil {
+		return value
+	}
+	if _, ok := u.User.Password(); !ok {
+		return value
+	}
+	u.User = url.User(u.User.Username())
+	return u.String()
+}
+
+// getDefaultAnswersPath returns the first file system of a removable disk
+// other than the installer media, if any
+func getDefaultAnswersPath() string {
+	disks, err := disk.NewScanner().Scan()
+	if err != nil {
+		return ""
+	}
+	for _, d := range disks {
+		if !d.Removable || d.IsInstaller() {
+			continue
+		}
+		for _, fs := range d.FileSystems {
+			return fs.Device
+		}
+	}
+	return ""
+}
+
+// saveAnswers writes data to path, which is a file, a directory or a block
+// device that gets mounted. It returns a description of the written file.
+func saveAnswers(data []byte, path string) (string, error) {
+	info, err := os.Stat(path)
+	switch {
+	case err != nil && !os.IsNotExist(err):
+		return "", err
+	case err == nil && info.Mode()&os.ModeDevice != 0:
+		dir, err := ioutil.TempDir("", "answers")
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(dir)
+		if output, err := exec.Command("mount", path, dir).CombinedOutput(); err != nil {
+			return "", errors.Errorf("failed to mount %s: %s", path, output)
+		}
+		writeErr := ioutil.WriteFile(filepath.Join(dir, answersFileName), data, 0600)
+		if output, err := exec.Command("umount", dir).CombinedOutput(); err != nil && writeErr == nil {
+			return "", errors.Errorf("failed to unmount %s: %s", path, output)
+		}
+		if writeErr != nil {
+			return "", writeErr
+		}
+		return answersFileName + " on " + path, nil
+	case err == nil && info.IsDir():
+		path = filepath.Join(path, answersFileName)
+	}
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,170 @@
+package console
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rancher/harvester-installer/pkg/cluster"
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/rancher/harvester-installer/pkg/preflight"
+	"github.com/sirupsen/logrus"
+)
+
+// runAutomaticInstall installs Harvester without any panels, using the install
+// config fetched from configURL. Progress and failures are written to the
+// system consoles.
+func runAutomaticInstall(configURL string) error {
+	out := getConsoleWriter()
+	printer := func(message string) {
+		logrus.Info(message)
+		fmt.Fprintln(out, message)
+	}
+	if err := installAutomatically(configURL, printer); err != nil {
+		printer(getAutomaticInstallFailure(err))
+		return err
+	}
+	return nil
+}
+
+func installAutomatically(configURL string, printer func(string)) error {
+	if configURL == "" {
+		return errors.New("harvester.install.config_url is required for automatic installation")
+	}
+	printer(fmt.Sprintf("Fetching Harvester install config from %s", configURL))
+	installConfig, err := getRemoteInstallConfig(configURL)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch install config")
+	}
+	if err := prepareInstallConfig(installConfig, printer); err != nil {
+		return err
+	}
+	cfg.Config = *installConfig
+	printer(fmt.Sprintf("Installing Harvester in %s mode to %s", cfg.Config.InstallMode, cfg.Config.K3OS.Install.Device))
+	powerOff := cfg.Config.K3OS.Install.PowerOff
+	current := installProgress{Stage: -1}
+	err = doInstall(&cfg.Config, printer, func(progress installProgress) {
+		if progress.Stage != current.Stage {
+			printer(progress.Description())
+		}
+		current = progress
+	})
+	if err != nil {
+		return errors.Wrapf(err, "installation failed at %s", current.Description())
+	}
+	printer(getInstallSummary(&cfg.Config, getManagementAddress(&cfg.Config)))
+	printer(fmt.Sprintf("The system restarts in %d seconds", int(rebootTimeout.Seconds())))
+	time.Sleep(rebootTimeout)
+	return rebootAfterInstall(powerOff)
+}
+
+// getAutomaticInstallFailure tells why the automatic installation failed, the
+// invalid fields of the config are listed one per line
+func getAutomaticInstallFailure(err error) string {
+	message := fmt.Sprintf("Automatic installation failed: %v\n", err)
+	var errs cfg.ValidationErrors
+	if errors.As(err, &errs) {
+		message = "Automatic installation failed: invalid install config\n"
+		for _, fieldErr := range errs {
+			message += fmt.Sprintf("  %v\n", fieldErr)
+		}
+	}
+	return message + "Fix the install config and restart the host to install again"
+}
+
+// prepareInstallConfig completes an install config which is not entered in the
+// panels, the way the panels do, and checks it against the host and the cluster
+// to join. Warnings are printed.
+func prepareInstallConfig(c *cfg.InstallConfig, printer func(string)) error {
+	if err := c.Validate(); err != nil {
+		return errors.Wrap(err, "invalid install config")
+	}
+	results := preflight.NewChecker().Run(c.K3OS.Install.Device)
+	printer(preflight.FormatResults(results))
+	if preflight.HasFailure(results) && !c.SkipChecks {
+		return errors.New("hardware checks failed, set skip_checks to install anyway")
+	}
+	c.K3OS.Install.Silent = true
+	if c.InstallMode == modeJoin {
+		c.K3OS.ServerURL = getFormattedServerURL(c.K3OS.ServerURL)
+	}
+	if err := encryptPassword(c); err != nil {
+		return err
+	}
+	if c.InstallMode == modeCreate && c.K3OS.Token == "" {
+		token, err := generateToken()
+		if err != nil {
+			return err
+		}
+		c.K3OS.Token = token
+	}
+
+	if err := customizeConfig(c); err != nil {
+		return err
+	}
+	if err := cfg.ValidateHostname(c.Hostname); err != nil {
+		return err
+	}
+	if httpProxy, httpsProxy, _ := getProxies(c); httpProxy != "" || httpsProxy != "" {
+		if err := checkProxies(httpProxy, httpsProxy); err != nil {
+			printer(err.Error())
+		}
+	}
+	if c.InstallMode == modeJoin {
+		result, err := cluster.NewChecker().Check(c.K3OS.ServerURL, c.K3OS.Token)
+		if err != nil {
+			var unreachableErr *cluster.UnreachableError
+			if !errors.As(err, &unreachableErr) {
+				return errors.Wrap(err, "failed to check joining the cluster")
+			}
+			printer(err.Error())
+		} else {
+			printer(fmt.Sprintf("Management CA fingerprint is %s", result.Fingerprint))
+		}
+		names, err := cluster.NewChecker().GetNodeNames(c.K3OS.ServerURL, c.K3OS.Token)
+		if err != nil {
+			printer(fmt.Sprintf("Unable to check the hostname against cluster nodes: %v", err))
+		}
+		for _, name := range names {
+			if name == c.Hostname {
+				return fmt.Errorf("hostname %q is used by another node of the cluster", name)
+			}
+		}
+	}
+	return nil
+}
+
+// getConsoleWriter returns a writer to every tty set by console= on the kernel
+// cmdline, so that progress is visible on both VGA and serial consoles
+func getConsoleWriter() io.Writer {
+	data, err := ioutil.ReadFile("/proc/cmdline")
+	if err != nil {
+		return os.Stdout
+	}
+	var writers []io.Writer
+	opened := map[string]bool{}
+	for _, item := range strings.Fields(string(data)) {
+		if !strings.HasPrefix(item, "console=") {
+			continue
+		}
+		tty := strings.SplitN(strings.TrimPrefix(item, "console="), ",", 2)[0]
+		if opened[tty] {
+			continue
+		}
+		f, err := os.OpenFile("/dev/"+tty, os.O_WRONLY, 0)
+		if err != nil {
+			logrus.Warnf("failed to open console %s: %v", tty, err)
+			continue
+		}
+		opened[tty] = true
+		writers = append(writers, f)
+	}
+	if len(writers) == 0 {
+		return os.Stdout
+	}
+	return io.MultiWriter(writers...)
+}
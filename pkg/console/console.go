@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/jroimartin/gocui"
+	cfg "github.com/rancher/harvester-installer/pkg/config"
 	"github.com/rancher/harvester-installer/pkg/widgets"
 	"github.com/sirupsen/logrus"
 )
@@ -32,31 +33,80 @@ type Console struct {
 	context context.Context
 	*gocui.Gui
 	elements map[string]widgets.Element
+	// flow is the navigation of the install wizard
+	flow *flow
+	// serverFingerprint is the fingerprint of the CA of the cluster to join
+	serverFingerprint string
+	// remote is the remote API, which is nil unless enabled on the cmdline
+	remote *remoteAPI
+	// checks counts the checks run in the background, checking is the one
+	// awaited by the current step, see runCheck
+	checks   int
+	checking int
 }
 
 // RunConsole starts the console
 func RunConsole() error {
+	if err := initLogs(); err != nil {
+		return err
+	}
+	var remoteAPIAddress string
+	if !isDashboardMode() {
+		cmdline, err := cfg.ReadCmdline("/proc/cmdline")
+		if err != nil {
+			return err
+		}
+		if cmdline.Automatic {
+			return runAutomaticInstall(cmdline.ConfigURL)
+		}
+		if err := loadPreseededConfig(cmdline.ConfigURL); err != nil {
+			logrus.Errorf("failed to load pre-seeded config: %v", err)
+		}
+		if cmdline.RemoteAPI {
+			remoteAPIAddress = cmdline.RemoteAPIAddress
+			if remoteAPIAddress == "" {
+				remoteAPIAddress = defaultRemoteAPIAddress
+			}
+		}
+		if cmdline.TextMode || isTextTerminal() {
+			if cmdline.RemoteAPI {
+				logrus.Warn("the remote API is served along with the panels only, not in text mode")
+			}
+			return runTextInstall(cmdline.RemoteAPI)
+		}
+	}
 	c, err := NewConsole()
 	if err != nil {
 		return err
 	}
-	if err := initLogs(); err != nil {
-		return err
+	if remoteAPIAddress != "" {
+		if err := c.serveRemoteAPI(remoteAPIAddress); err != nil {
+			logrus.Errorf("failed to serve the remote API: %v", err)
+		}
 	}
 	return c.doRun()
 }
 
+func isDashboardMode() bool {
+	hd, _ := os.LookupEnv("HARVESTER_DASHBOARD")
+	return hd == "true"
+}
+
 // NewConsole initialize the console
 func NewConsole() (*Console, error) {
 	g, err := gocui.NewGui(gocui.OutputNormal)
 	if err != nil {
 		return nil, err
 	}
-	return &Console{
+	c := &Console{
 		context:  context.Background(),
 		Gui:      g,
 		elements: make(map[string]widgets.Element),
-	}, nil
+	}
+	c.flow = newInstallFlow(func() bool {
+		return c.serverFingerprint != ""
+	})
+	return c, nil
 }
 
 // GetElement gets an element by name
@@ -92,7 +142,7 @@ func (c *Console) setContentByName(name string, content string) error {
 func (c *Console) doRun() error {
 	defer c.Close()
 
-	if hd, _ := os.LookupEnv("HARVESTER_DASHBOARD"); hd == "true" {
+	if isDashboardMode() {
 		c.SetManagerFunc(c.layoutDashboard)
 	} else {
 		c.SetManagerFunc(c.layoutInstall)
@@ -1,31 +1,136 @@
 package console
 
+import (
+	"time"
+
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+)
+
 const (
-	titlePanel           = "title"
-	debugPanel           = "debug"
-	diskPanel            = "disk"
-	askCreatePanel       = "askCreate"
-	serverURLPanel       = "serverUrl"
-	passwordPanel        = "osPassword"
-	passwordConfirmPanel = "osPasswordConfirm"
-	sshKeyPanel          = "sshKey"
-	tokenPanel           = "token"
-	proxyPanel           = "proxy"
-	networkPanel         = "network"
-	cloudInitPanel       = "cloudInit"
-	validatorPanel       = "validator"
-	notePanel            = "note"
-	confirmPanel         = "confirm"
-	installPanel         = "install"
-	footerPanel          = "footer"
-
-	modeCreate = "create"
-	modeJoin   = "join"
-
-	clusterTokenNote = "Note: The token is used for adding nodes to the cluster"
+	titlePanel            = "title"
+	debugPanel            = "debug"
+	diskPanel             = "disk"
+	diskConfirmPanel      = "diskConfirm"
+	dataDisksPanel        = "dataDisks"
+	dataDisksConfirmPanel = "dataDisksConfirm"
+	askCreatePanel        = "askCreate"
+	serverURLPanel        = "serverUrl"
+	passwordPanel         = "osPassword"
+	passwordConfirmPanel  = "osPasswordConfirm"
+	sshKeyPanel           = "sshKey"
+	tokenPanel            = "token"
+	proxyPanel            = "proxy"
+	httpsProxyPanel       = "httpsProxy"
+	proxyUserPanel        = "proxyUser"
+	proxyPasswordPanel    = "proxyPassword"
+	noProxyPanel          = "noProxy"
+	registryPanel         = "registry"
+	networkPanel          = "network"
+	networkMethodPanel    = "networkMethod"
+	addressPanel          = "address"
+	gatewayPanel          = "gateway"
+	dnsServersPanel       = "dnsServers"
+	ntpServersPanel       = "ntpServers"
+	hostnamePanel         = "hostname"
+	preflightPanel        = "preflight"
+	cloudInitPanel        = "cloudInit"
+	validatorPanel        = "validator"
+	notePanel             = "note"
+	confirmPanel          = "confirm"
+	saveAnswersPanel      = "saveAnswers"
+	installPanel          = "install"
+	installProgressPanel  = "installProgress"
+	installFailedPanel    = "installFailed"
+	installDonePanel      = "installDone"
+	footerPanel           = "footer"
+	remoteInfoPanel       = "remoteInfo"
+	remotePanel           = "remote"
+
+	modeCreate = cfg.ModeCreate
+	modeJoin   = cfg.ModeJoin
+
+	dataDisksDone = "done"
+
+	installRetry    = "retry"
+	installEdit     = "edit"
+	installShell    = "shell"
+	installSaveLog  = "saveLog"
+	installLogLines = 10
+
+	// generated cluster tokens are long enough to not be guessed
+	generatedTokenLength = 32
+	// the token of the remote API is typed by hand from the console
+	remoteTokenLength = 16
+	// the node will reboot when the user doesn't after the installation
+	rebootTimeout = 60 * time.Second
+
+	networkMethodDHCP   = cfg.NetworkMethodDHCP
+	networkMethodStatic = cfg.NetworkMethodStatic
+
+	defaultDNSServer = "8.8.8.8"
+	defaultNTPServer = "ntp.ubuntu.com"
+
+	// default networks of k3s, which are not reached through the proxy
+	defaultClusterCIDR = "10.42.0.0/16"
+	defaultServiceCIDR = "10.43.0.0/16"
+	// the registry of the Harvester images, which is mirrored by the endpoint
+	// entered in the installer
+	mirroredRegistry = "docker.io"
+	// the proxies are checked by requesting this host with their protocols
+	proxyCheckHost = "releases.rancher.com"
+
+	clusterTokenNote = "Note: The token is used for adding nodes to the cluster, a random one is generated"
 	serverURLNote    = "Note: Input IP/domain name of the management node"
-	proxyNote        = "Note: In the form of \"http://[[user][:pass]@]host[:port]/\"."
+	noProxyNote      = "Note: Comma separated list of addresses, CIDRs and domain names which are not reached through the proxy"
+	registryNote     = "Note: Images of " + mirroredRegistry + " are pulled from the mirror, e.g. https://harbor.example.com"
 	sshKeyNote       = "For example: https://github.com/<username>.keys"
+	dnsServersNote   = "Note: Comma separated list of DNS server addresses"
+	ntpServersNote   = "Note: Comma separated list of NTP server addresses or domain names"
+	saveAnswersNote  = "Note: A file, a directory or a partition such as a USB stick, which is mounted"
+	hostnameNote     = "Note: Press TAB for suggestions, {mac} and {serial} are replaced with the MAC address and serial number"
+
+	harvesterNamespace    = "harvester-system"
+	harvesterManifestFile = "/var/lib/rancher/k3s/server/manifests/harvester.yaml"
+	// k3s applies the manifests in the order of their file names, addons sort
+	// after the Harvester manifest and in the order they are configured
+	addonManifestFile = "/var/lib/rancher/k3s/server/manifests/zz-addon-%03d-%s.yaml"
+	defaultAddonName  = "addon"
+	// the root of the installer media, where the paths of addons are relative to
+	isoMountPoint = "/run/k3os/iso"
+	// the chart bundled with the installer, which is served by k3s
+	harvesterChartURL        = "https://%{KUBERNETES_API}%/static/charts/harvester-0.1.0.tgz"
+	harvesterBundledChartURL = "https://%%{KUBERNETES_API}%%/static/charts/harvester-%s.tgz"
+	// the charts bundled on the installer media, relative to its root
+	harvesterBundledChartFile = "var/lib/rancher/k3s/server/static/charts/harvester-%s.tgz"
+
+	authorizedFile    = "/home/rancher/.ssh/authorized_keys"
+	connmanConfigFile = "/var/lib/connman/harvester.config"
+
+	defaultRemoteAPIAddress = ":8080"
+	// install configs are small, except for inline addon manifests
+	remoteConfigMaxSize = 10 << 20
+	// a config is read and a response is written within the timeouts, the
+	// events are streamed with a write timeout per event
+	remoteReadTimeout  = time.Minute
+	remoteWriteTimeout = time.Minute
+	remoteIdleTimeout  = 2 * time.Minute
+	// the certificate of the remote API is generated when the installer
+	// starts, its validity covers a wrong clock of the host
+	remoteCertificateValidity = 10 * 365 * 24 * time.Hour
+
+	dmiProductSerialFile = "/sys/class/dmi/id/product_serial"
+	installLogFile       = "/var/log/harvester-install.log"
+	answersFileName      = "harvester-config.yaml"
+	nodeTokenFile        = "/var/lib/rancher/k3s/server/node-token"
+	k3osConfigFile       = "/k3os/system/config.yaml"
+
+	// the first data disk is mounted to the default data path of Longhorn, the
+	// others are mounted under the extra disks directory
+	longhornDataPath    = "/var/lib/harvester/defaultdisk"
+	extraDisksDir       = "/var/lib/harvester/extra-disks"
+	dataDiskLabelPrefix = "HARVESTER_DATA"
 
-	authorizedFile = "/home/rancher/.ssh/authorized_keys"
+	longhornDefaultDiskLabel       = "node.longhorn.io/create-default-disk"
+	longhornDefaultDisksAnnotation = "node.longhorn.io/default-disks-config"
+	kubeletKubeconfigFile          = "/var/lib/rancher/k3s/agent/kubelet.kubeconfig"
 )
@@ -12,6 +12,7 @@ import (
 
 	"github.com/jroimartin/gocui"
 	"github.com/pkg/errors"
+	cfg "github.com/rancher/harvester-installer/pkg/config"
 	"github.com/rancher/harvester-installer/pkg/util"
 	"github.com/rancher/harvester-installer/pkg/version"
 	"github.com/rancher/harvester-installer/pkg/widgets"
@@ -39,6 +40,9 @@ type state struct {
 	installed    bool
 	harvesterURL string
 	isMaster     bool
+	// managementAddress and clusterToken are used for adding nodes
+	managementAddress string
+	clusterToken      string
 }
 
 var (
@@ -75,7 +79,7 @@ func (c *Console) layoutDashboard(g *gocui.Gui) error {
 		v.Frame = false
 		v.Wrap = true
 		fmt.Fprintf(v, "Current status: ")
-		go syncHarvesterStatus(context.Background(), g)
+		go syncHarvesterStatus(c.context, g)
 	}
 	if v, err := g.SetView("footer", 0, maxY-2, maxX, maxY); err != nil {
 		if err != gocui.ErrUnknownView {
@@ -84,6 +88,16 @@ func (c *Console) layoutDashboard(g *gocui.Gui) error {
 		v.Frame = false
 		fmt.Fprintf(v, "<Use F12 to switch between Harvester console and Shell>")
 	}
+	if current.isMaster {
+		if v, err := g.SetView("join", maxX/2-40, 18, maxX/2+40, 24); err != nil {
+			if err != gocui.ErrUnknownView {
+				return err
+			}
+			v.Frame = false
+			v.Wrap = true
+			fmt.Fprint(v, getJoinSummary(current.managementAddress, current.clusterToken))
+		}
+	}
 	if err := logoPanel(g); err != nil {
 		return err
 	}
@@ -199,9 +213,30 @@ func initState() error {
 		return err
 	}
 	current.harvesterURL = fmt.Sprintf("https://%s:8443", ip.String())
+	current.managementAddress = ip.String()
+	current.clusterToken = getClusterToken()
 	return nil
 }
 
+// getClusterToken returns the token of the node token file which pins the CA
+// of the cluster, or the configured token if k3s hasn't written it yet
+func getClusterToken() string {
+	if data, err := ioutil.ReadFile(nodeTokenFile); err == nil {
+		return strings.TrimSpace(string(data))
+	}
+	data, err := ioutil.ReadFile(k3osConfigFile)
+	if err != nil {
+		logrus.Warnf("failed to read cluster token: %v", err)
+		return ""
+	}
+	c, err := cfg.ToCloudConfig(data)
+	if err != nil {
+		logrus.Warnf("failed to read cluster token: %v", err)
+		return ""
+	}
+	return c.K3OS.Token
+}
+
 func syncHarvesterStatus(ctx context.Context, g *gocui.Gui) {
 	//sync status at the begining
 	doSyncHarvesterStatus(g)
@@ -221,7 +256,10 @@ func doSyncHarvesterStatus(g *gocui.Gui) {
 	status := getHarvesterStatus()
 	g.Update(func(g *gocui.Gui) error {
 		v, err := g.View("status")
-		if err != nil {
+		if err == gocui.ErrUnknownView {
+			// the dashboard is closed
+			return nil
+		} else if err != nil {
 			return err
 		}
 		v.Clear()
@@ -0,0 +1,30 @@
+//go:build linux
+// +build linux
+
+package console
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLayoutDashboard(t *testing.T) {
+	tc := newTestDashboard(t, 100, 30)
+	defer tc.close()
+
+	assert.Contains(t, tc.viewContent("logo"), "version: ")
+	assert.Equal(t, "Harvester management URL: \n\nUnavailable", tc.viewContent("url"))
+	assert.Equal(t, "<Use F12 to switch between Harvester console and Shell>", tc.viewContent("footer"))
+	assert.Equal(t, "", tc.viewContent("join"), "the join summary is shown on servers")
+	// no cluster is reachable to tell the status
+	tc.waitForContent("status", "Current status: \n\nSetting up Harvester")
+
+	tc.press(keyF12)
+	assert.Equal(t, "adminPassword", tc.currentPanel())
+	tc.press("wrong", keyEnter)
+	tc.waitForContent(validatorPanel, "Invalid credential")
+	tc.press(keyEsc)
+	assert.Equal(t, "", tc.viewContent("adminPasswordFrame"))
+	assert.Equal(t, "", tc.viewContent(validatorPanel))
+}
@@ -0,0 +1,358 @@
+package console
+
+import (
+	"github.com/jroimartin/gocui"
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+)
+
+// step is a screen of the install wizard
+type step struct {
+	// name of the step, which is the panel focused when the step is shown
+	name string
+	// panels are the other inputs of the screen, they are shown before the
+	// focused panel
+	panels []string
+	// when tells whether the step is asked for the config, the step is always
+	// asked when it is nil
+	when func(c *cfg.InstallConfig) bool
+	// validate checks the answers of the step before the next step is shown
+	validate func(c *cfg.InstallConfig) error
+	// unchecked tells whether the answers of the step are still to be checked
+	// against a server, the step is asked then even if it is pre-seeded or
+	// answered before an edit
+	unchecked func(c *cfg.InstallConfig) bool
+}
+
+// flow is a sequence of steps. It keeps the steps shown before the current
+// one, going back returns to them in the reverse order.
+type flow struct {
+	steps []step
+	// skip tells whether a step is answered beforehand, e.g. by the pre-seeded
+	// config, skipped steps are not shown
+	skip    func(name string) bool
+	history []string
+	// review is the step which a step is edited from, it is shown again once
+	// the steps brought in by the new answers are answered
+	review string
+	// reviewHistory and reviewPath are the steps shown and the steps asked
+	// when the edit starts
+	reviewHistory []string
+	reviewPath    map[string]bool
+}
+
+// newInstallFlow returns the steps of the install wizard in the order they are
+// shown, the panels answered by the pre-seeded config are skipped. In join mode
+// the cluster token is asked until joinChecked tells the cluster is checked, so
+// that the CA of the cluster is shown before the installation is confirmed.
+func newInstallFlow(joinChecked func() bool) *flow {
+	isJoin := func(c *cfg.InstallConfig) bool {
+		return c.InstallMode == modeJoin
+	}
+	isStatic := func(c *cfg.InstallConfig) bool {
+		return c.Network.Method == networkMethodStatic
+	}
+	return &flow{
+		steps: []step{
+			{name: askCreatePanel},
+			{name: preflightPanel},
+			{name: diskPanel},
+			{
+				name: dataDisksPanel,
+				validate: func(c *cfg.InstallConfig) error {
+					var installDevice string
+					if c.K3OS.Install != nil {
+						installDevice = c.K3OS.Install.Device
+					}
+					return cfg.ValidateDataDisks(c.DataDisks, installDevice)
+				},
+			},
+			{name: serverURLPanel, when: isJoin},
+			{
+				name: tokenPanel,
+				unchecked: func(c *cfg.InstallConfig) bool {
+					return isJoin(c) && !joinChecked()
+				},
+			},
+			{name: passwordPanel, panels: []string{passwordConfirmPanel}},
+			{
+				name: sshKeyPanel,
+				validate: func(c *cfg.InstallConfig) error {
+					if c.SSHKeyURL == "" {
+						return nil
+					}
+					return cfg.ValidateSSHKeyURL(c.SSHKeyURL)
+				},
+			},
+			{name: networkPanel},
+			{name: networkMethodPanel},
+			{
+				name:   addressPanel,
+				panels: []string{gatewayPanel},
+				when:   isStatic,
+				validate: func(c *cfg.InstallConfig) error {
+					return cfg.ValidateStaticIP(c.Network.IP, c.Network.Gateway)
+				},
+			},
+			{name: dnsServersPanel},
+			{name: ntpServersPanel},
+			{name: hostnamePanel},
+			{name: proxyPanel, panels: []string{httpsProxyPanel}},
+			{name: proxyUserPanel, panels: []string{proxyPasswordPanel}, when: hasProxy},
+			{name: noProxyPanel, when: hasProxy},
+			{
+				name: registryPanel,
+				validate: func(c *cfg.InstallConfig) error {
+					if c.K3OS.Registries == nil {
+						return nil
+					}
+					return cfg.ValidateRegistries(c.K3OS.Registries)
+				},
+			},
+			{name: cloudInitPanel},
+			{name: confirmPanel},
+		},
+		skip: func(name string) bool {
+			return preseededPanels[name]
+		},
+	}
+}
+
+// getStep returns the step of name and its index, the index is -1 if the flow
+// has no such step
+func (f *flow) getStep(name string) (step, int) {
+	for i, s := range f.steps {
+		if s.name == name {
+			return s, i
+		}
+	}
+	return step{}, -1
+}
+
+// getStepOf returns the name of the step which shows panel, e.g. the proxy
+// step for the HTTPS proxy panel
+func (f *flow) getStepOf(panel string) string {
+	for _, s := range f.steps {
+		for _, p := range s.panels {
+			if p == panel {
+				return s.name
+			}
+		}
+	}
+	return panel
+}
+
+// isAsked tells whether the step is shown for the config
+func (f *flow) isAsked(s step, c *cfg.InstallConfig) bool {
+	if f.skip != nil && f.skip(s.name) && !isUnchecked(s, c) {
+		return false
+	}
+	return s.when == nil || s.when(c)
+}
+
+func isUnchecked(s step, c *cfg.InstallConfig) bool {
+	return s.unchecked != nil && s.unchecked(c)
+}
+
+// path returns the steps shown for the config in order
+func (f *flow) path(c *cfg.InstallConfig) []string {
+	var names []string
+	for _, s := range f.steps {
+		if f.isAsked(s, c) {
+			names = append(names, s.name)
+		}
+	}
+	return names
+}
+
+// next returns the first step after current which is asked for the config, or
+// the first step of the flow when current is empty. It is empty at the end of
+// the flow.
+func (f *flow) next(c *cfg.InstallConfig, current string) string {
+	_, i := f.getStep(current)
+	for i++; i < len(f.steps); i++ {
+		if f.isAsked(f.steps[i], c) {
+			return f.steps[i].name
+		}
+	}
+	return ""
+}
+
+// forward checks the answers of current and records it to go back to, it
+// returns the step to show next
+func (f *flow) forward(c *cfg.InstallConfig, current string) (string, error) {
+	s, i := f.getStep(current)
+	if i < 0 {
+		return f.next(c, current), nil
+	}
+	if s.validate != nil {
+		if err := s.validate(c); err != nil {
+			return "", err
+		}
+	}
+	next := f.next(c, current)
+	if f.review != "" {
+		// only the steps which the edit brings in are asked, e.g. the
+		// management address once the mode is changed to join, and the
+		// steps to check again, e.g. the token once the address is changed
+		for next != "" && next != f.review && f.reviewPath[next] {
+			if s, _ := f.getStep(next); isUnchecked(s, c) {
+				break
+			}
+			next = f.next(c, next)
+		}
+		if next == "" || next == f.review {
+			return f.endEdit(), nil
+		}
+	}
+	if next != "" {
+		f.history = append(f.history, current)
+	}
+	return next, nil
+}
+
+// back returns the step shown before the current one, it is empty when the
+// current step is the first one shown
+func (f *flow) back(c *cfg.InstallConfig) string {
+	for len(f.history) > 0 {
+		prev := f.history[len(f.history)-1]
+		f.history = f.history[:len(f.history)-1]
+		if f.review != "" && prev == f.review {
+			return f.endEdit()
+		}
+		// answers of the steps shown before are not changed by going back,
+		// check anyway so that a step which no longer applies is never shown.
+		// Pre-seeded steps are shown when edited, they aren't skipped here.
+		if s, i := f.getStep(prev); i >= 0 && (s.when == nil || s.when(c)) {
+			return prev
+		}
+	}
+	return ""
+}
+
+// edit starts editing the answers of a step from review, going forward or
+// back from the step returns to review
+func (f *flow) edit(c *cfg.InstallConfig, review string) {
+	f.review = review
+	f.reviewHistory = append([]string{}, f.history...)
+	f.reviewPath = make(map[string]bool)
+	for _, name := range f.path(c) {
+		f.reviewPath[name] = true
+	}
+	f.history = append(f.history, review)
+}
+
+// endEdit returns to the review step with the steps shown before the edit
+func (f *flow) endEdit() string {
+	review := f.review
+	f.history = f.reviewHistory
+	f.review, f.reviewHistory, f.reviewPath = "", nil, nil
+	return review
+}
+
+// reset forgets the steps shown, e.g. to start over
+func (f *flow) reset() {
+	f.history = nil
+	f.review, f.reviewHistory, f.reviewPath = "", nil, nil
+}
+
+// showStep shows the panels of the step, the step panel is focused
+func showStep(c *Console, name string) error {
+	s, _ := c.flow.getStep(name)
+	return showNext(c, append(append([]string{}, s.panels...), name)...)
+}
+
+// closeStep closes the panels of the step and its note, the panels already
+// closed by the step, e.g. to show a confirmation, are left alone
+func closeStep(c *Console, name string) error {
+	// the result of a check still running is dropped
+	c.checking = 0
+	s, _ := c.flow.getStep(name)
+	for _, panel := range append([]string{name}, s.panels...) {
+		if _, err := c.Gui.View(panel); err == gocui.ErrUnknownView {
+			continue
+		}
+		v, err := c.GetElement(panel)
+		if err != nil {
+			return err
+		}
+		if err := v.Close(); err != nil {
+			return err
+		}
+	}
+	return c.setContentByName(notePanel, "")
+}
+
+// showFirstStep starts the wizard over from the first step to ask
+func showFirstStep(c *Console) error {
+	c.flow.reset()
+	first := c.flow.next(&cfg.Config, "")
+	if first == "" {
+		first = confirmPanel
+	}
+	return showStep(c, first)
+}
+
+// runCheck runs check in the background, so that the console keeps responding
+// while servers are contacted, and passes its error to done in the main loop.
+// Enter is ignored while checking, the result is dropped if the step is left.
+func runCheck(c *Console, check func() error, done func(err error) error) error {
+	if c.checking != 0 {
+		return nil
+	}
+	c.checks++
+	id := c.checks
+	c.checking = id
+	if err := c.setContentByName(validatorPanel, "Checking..."); err != nil {
+		return err
+	}
+	go func() {
+		err := check()
+		c.Gui.Update(func(g *gocui.Gui) error {
+			if c.checking != id {
+				return nil
+			}
+			c.checking = 0
+			return done(err)
+		})
+	}()
+	return nil
+}
+
+// showEditStep leaves the review step to change the answers of the step showing
+// the panel name, the step is shown even if it is pre-seeded
+func showEditStep(c *Console, review, name string) error {
+	c.flow.edit(&cfg.Config, review)
+	if err := closeStep(c, review); err != nil {
+		return err
+	}
+	return showStep(c, c.flow.getStepOf(name))
+}
+
+// showNextStep leaves the current step for the next one once its answers are
+// valid, the validation error is shown otherwise
+func showNextStep(c *Console, current string) error {
+	next, err := c.flow.forward(&cfg.Config, current)
+	if err != nil {
+		return c.setContentByName(validatorPanel, err.Error())
+	}
+	if next == "" {
+		return nil
+	}
+	if err := closeStep(c, current); err != nil {
+		return err
+	}
+	return showStep(c, next)
+}
+
+// showPrevStep goes back to the step shown before the current one, the current
+// step stays when it is the first one
+func showPrevStep(c *Console, current string) error {
+	prev := c.flow.back(&cfg.Config)
+	if prev == "" {
+		return nil
+	}
+	if err := closeStep(c, current); err != nil {
+		return err
+	}
+	return showStep(c, prev)
+}
@@ -0,0 +1,313 @@
+package console
+
+import (
+	"fmt"
+	"testing"
+
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstallFlowNext(t *testing.T) {
+	defer func() {
+		preseededPanels = map[string]bool{}
+	}()
+	testCases := []struct {
+		Name      string
+		mode      string
+		method    string
+		proxy     string
+		preseeded map[string]bool
+		current   string
+		output    string
+	}{
+		{
+			Name:    "first panel",
+			current: "",
+			output:  askCreatePanel,
+		},
+		{
+			Name:    "hardware checks before disk",
+			mode:    modeCreate,
+			current: askCreatePanel,
+			output:  preflightPanel,
+		},
+		{
+			Name:    "data disks follow disk",
+			mode:    modeCreate,
+			current: diskPanel,
+			output:  dataDisksPanel,
+		},
+		{
+			Name:    "create mode skips server URL",
+			mode:    modeCreate,
+			current: dataDisksPanel,
+			output:  tokenPanel,
+		},
+		{
+			Name:    "join mode asks server URL",
+			mode:    modeJoin,
+			current: dataDisksPanel,
+			output:  serverURLPanel,
+		},
+		{
+			Name:      "skip pre-seeded panels",
+			mode:      modeCreate,
+			preseeded: map[string]bool{tokenPanel: true, passwordPanel: true},
+			current:   dataDisksPanel,
+			output:    sshKeyPanel,
+		},
+		{
+			Name:    "DHCP skips static address",
+			mode:    modeCreate,
+			method:  networkMethodDHCP,
+			current: networkMethodPanel,
+			output:  dnsServersPanel,
+		},
+		{
+			Name:    "static IP asks address",
+			mode:    modeCreate,
+			method:  networkMethodStatic,
+			current: networkMethodPanel,
+			output:  addressPanel,
+		},
+		{
+			Name:    "hostname follows NTP servers",
+			mode:    modeJoin,
+			current: ntpServersPanel,
+			output:  hostnamePanel,
+		},
+		{
+			Name:    "no proxy settings without proxy",
+			mode:    modeCreate,
+			current: proxyPanel,
+			output:  registryPanel,
+		},
+		{
+			Name:    "proxy credentials follow proxy",
+			mode:    modeCreate,
+			proxy:   "http://proxy:3128",
+			current: proxyPanel,
+			output:  proxyUserPanel,
+		},
+		{
+			Name:      "no proxy follows pre-seeded proxy credentials",
+			mode:      modeCreate,
+			proxy:     "http://proxy:3128",
+			preseeded: map[string]bool{proxyUserPanel: true},
+			current:   proxyPanel,
+			output:    noProxyPanel,
+		},
+		{
+			Name:    "end of the flow",
+			mode:    modeCreate,
+			current: confirmPanel,
+			output:  "",
+		},
+	}
+	for _, testCase := range testCases {
+		c := &cfg.InstallConfig{}
+		c.InstallMode = testCase.mode
+		c.Network.Method = testCase.method
+		c.K3OS.Environment = map[string]string{"http_proxy": testCase.proxy}
+		preseededPanels = testCase.preseeded
+		assert.Equal(t, testCase.output, newInstallFlow(isJoinChecked).next(c, testCase.current), testCase.Name)
+	}
+}
+
+func TestInstallFlowPath(t *testing.T) {
+	defer func() {
+		preseededPanels = map[string]bool{}
+	}()
+	c := &cfg.InstallConfig{}
+	c.InstallMode = modeJoin
+	c.Network.Method = networkMethodStatic
+	c.K3OS.Environment = map[string]string{"http_proxy": "http://proxy:3128"}
+	preseededPanels = getPreseededPanels(c)
+
+	assert.Equal(t, []string{
+		preflightPanel,
+		diskPanel,
+		dataDisksPanel,
+		serverURLPanel,
+		tokenPanel,
+		passwordPanel,
+		sshKeyPanel,
+		networkPanel,
+		addressPanel,
+		dnsServersPanel,
+		ntpServersPanel,
+		hostnamePanel,
+		noProxyPanel,
+		registryPanel,
+		cloudInitPanel,
+		confirmPanel,
+	}, newInstallFlow(isJoinChecked).path(c))
+}
+
+func TestFlowNavigation(t *testing.T) {
+	var invalid bool
+	f := &flow{
+		steps: []step{
+			{name: "mode"},
+			{
+				name: "server",
+				when: func(c *cfg.InstallConfig) bool {
+					return c.InstallMode == modeJoin
+				},
+			},
+			{
+				name: "token",
+				validate: func(c *cfg.InstallConfig) error {
+					if invalid {
+						return fmt.Errorf("invalid token")
+					}
+					return nil
+				},
+			},
+			{name: "preseeded"},
+			{name: "confirm"},
+		},
+		skip: func(name string) bool {
+			return name == "preseeded"
+		},
+	}
+	c := &cfg.InstallConfig{}
+	c.InstallMode = modeJoin
+
+	assert.Equal(t, "", f.back(c), "nothing to go back to")
+	assert.Equal(t, "mode", f.next(c, ""))
+
+	next, err := f.forward(c, "mode")
+	assert.Nil(t, err)
+	assert.Equal(t, "server", next)
+	next, err = f.forward(c, "server")
+	assert.Nil(t, err)
+	assert.Equal(t, "token", next)
+
+	invalid = true
+	_, err = f.forward(c, "token")
+	assert.EqualError(t, err, "invalid token")
+	assert.Equal(t, []string{"mode", "server"}, f.history, "stay on an invalid step")
+
+	invalid = false
+	next, err = f.forward(c, "token")
+	assert.Nil(t, err)
+	assert.Equal(t, "confirm", next, "skip pre-seeded steps")
+
+	assert.Equal(t, "token", f.back(c))
+	assert.Equal(t, "server", f.back(c))
+	assert.Equal(t, "mode", f.back(c))
+
+	// the mode changes to create, which doesn't ask the server
+	c.InstallMode = modeCreate
+	next, err = f.forward(c, "mode")
+	assert.Nil(t, err)
+	assert.Equal(t, "token", next)
+	assert.Equal(t, "mode", f.back(c))
+
+	f.forward(c, "mode")
+	f.reset()
+	assert.Equal(t, "", f.back(c), "start over")
+}
+
+func TestFlowEdit(t *testing.T) {
+	f := &flow{
+		steps: []step{
+			{name: "mode"},
+			{
+				name: "server",
+				when: func(c *cfg.InstallConfig) bool {
+					return c.InstallMode == modeJoin
+				},
+			},
+			{name: "token"},
+			{name: "preseeded"},
+			{name: "confirm"},
+		},
+		skip: func(name string) bool {
+			return name == "preseeded"
+		},
+	}
+	c := &cfg.InstallConfig{}
+	c.InstallMode = modeCreate
+	f.forward(c, "mode")
+	f.forward(c, "token")
+	assert.Equal(t, []string{"mode", "token"}, f.history)
+
+	// the answers of the step are kept
+	f.edit(c, "confirm")
+	next, err := f.forward(c, "token")
+	assert.Nil(t, err)
+	assert.Equal(t, "confirm", next, "return to review")
+	assert.Equal(t, []string{"mode", "token"}, f.history)
+
+	// going back from the edited step
+	f.edit(c, "confirm")
+	assert.Equal(t, "confirm", f.back(c))
+	assert.Equal(t, []string{"mode", "token"}, f.history)
+
+	// pre-seeded steps are edited too
+	f.edit(c, "confirm")
+	next, err = f.forward(c, "preseeded")
+	assert.Nil(t, err)
+	assert.Equal(t, "confirm", next)
+
+	// the steps brought in by the new answers are asked
+	f.edit(c, "confirm")
+	c.InstallMode = modeJoin
+	next, err = f.forward(c, "mode")
+	assert.Nil(t, err)
+	assert.Equal(t, "server", next)
+	assert.Equal(t, "mode", f.back(c))
+	next, err = f.forward(c, "mode")
+	assert.Nil(t, err)
+	assert.Equal(t, "server", next)
+	next, err = f.forward(c, "server")
+	assert.Nil(t, err)
+	assert.Equal(t, "confirm", next, "the token is answered")
+	assert.Equal(t, []string{"mode", "token"}, f.history)
+	assert.Equal(t, "token", f.back(c))
+}
+
+func isJoinChecked() bool {
+	return true
+}
+
+func TestInstallFlowJoinCheck(t *testing.T) {
+	defer func() {
+		preseededPanels = map[string]bool{}
+	}()
+	c := &cfg.InstallConfig{}
+	c.InstallMode = modeJoin
+	c.K3OS.ServerURL = "https://1.2.3.4:6443"
+	c.K3OS.Token = "token"
+	preseededPanels = getPreseededPanels(c)
+	var checked bool
+	f := newInstallFlow(func() bool {
+		return checked
+	})
+
+	assert.Equal(t, tokenPanel, f.next(c, serverURLPanel), "the pre-seeded token is checked")
+	checked = true
+	assert.Equal(t, passwordPanel, f.next(c, serverURLPanel))
+
+	// the token is checked again once the management address is edited
+	preseededPanels = map[string]bool{}
+	f.edit(c, confirmPanel)
+	checked = false
+	next, err := f.forward(c, serverURLPanel)
+	assert.Nil(t, err)
+	assert.Equal(t, tokenPanel, next)
+	checked = true
+	next, err = f.forward(c, tokenPanel)
+	assert.Nil(t, err)
+	assert.Equal(t, confirmPanel, next)
+}
+
+func TestInstallFlowGetStepOf(t *testing.T) {
+	f := newInstallFlow(isJoinChecked)
+	assert.Equal(t, proxyPanel, f.getStepOf(httpsProxyPanel))
+	assert.Equal(t, passwordPanel, f.getStepOf(passwordConfirmPanel))
+	assert.Equal(t, hostnamePanel, f.getStepOf(hostnamePanel))
+}
@@ -0,0 +1,349 @@
+//go:build linux
+// +build linux
+
+package console
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/jroimartin/gocui"
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/rancher/harvester-installer/pkg/widgets"
+)
+
+// keys typed to the test console, as sent by an xterm
+const (
+	keyEnter = "\r"
+	keyEsc   = "\x1b"
+	keyUp    = "\x1bOA"
+	keyDown  = "\x1bOB"
+	keyF12   = "\x1b[24~"
+
+	// syncKey is typed after the keys of a test, its handler tells that the
+	// keys typed before are handled
+	syncKey     = "\x14"
+	syncKeyCode = gocui.KeyCtrlT
+
+	testConsoleTimeout = 10 * time.Second
+)
+
+var (
+	// termbox is a singleton which can't be initialized again once its main
+	// loop runs, the test consoles share the GUI and the pseudo terminal
+	testGuiOnce sync.Once
+	testGui     *gocui.Gui
+	testPTY     *os.File
+	testGuiErr  error
+	// testGuiDone is closed when the main loop exits with testGuiErr
+	testGuiDone = make(chan struct{})
+	testSynced  = make(chan struct{}, 1)
+)
+
+// testConsole drives the console on a pseudo terminal of a given size, keys
+// are typed to the terminal and the views are read from the main loop
+type testConsole struct {
+	*Console
+	t      *testing.T
+	cancel context.CancelFunc
+}
+
+// newTestConsole starts the install wizard on a screen of width x height with
+// the pre-seeded config, the test is skipped without pseudo terminals
+func newTestConsole(t *testing.T, width, height int, preseed *cfg.InstallConfig) *testConsole {
+	if preseed == nil {
+		preseed = &cfg.InstallConfig{}
+	}
+	return startTestConsole(t, width, height, func(tc *testConsole) gocui.ManagerFunc {
+		cfg.Config = *preseed
+		preseededPanels = getPreseededPanels(preseed)
+		return tc.layoutInstall
+	})
+}
+
+// newTestDashboard starts the dashboard of an installed node on a screen of
+// width x height
+func newTestDashboard(t *testing.T, width, height int) *testConsole {
+	return startTestConsole(t, width, height, func(tc *testConsole) gocui.ManagerFunc {
+		current = state{}
+		return tc.layoutDashboard
+	})
+}
+
+// startTestConsole replaces the views of the shared GUI with the layout of a
+// new console, like doRun does
+func startTestConsole(t *testing.T, width, height int, layout func(tc *testConsole) gocui.ManagerFunc) *testConsole {
+	testGuiOnce.Do(startTestGui)
+	if testGuiErr != nil {
+		t.Skipf("console is not available: %v", testGuiErr)
+	}
+	if err := setWindowSize(testPTY, width, height); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	tc := &testConsole{
+		Console: &Console{
+			context:  ctx,
+			Gui:      testGui,
+			elements: make(map[string]widgets.Element),
+		},
+		t:      t,
+		cancel: cancel,
+	}
+	tc.flow = newInstallFlow(func() bool {
+		return tc.serverFingerprint != ""
+	})
+	// the globals are used by the main loop, set them from there
+	tc.do(func(g *gocui.Gui) error {
+		once = sync.Once{}
+		g.SetManager(layout(tc))
+		if err := setGlobalKeyBindings(g); err != nil {
+			return err
+		}
+		return g.SetKeybinding("", syncKeyCode, gocui.ModNone, func(g *gocui.Gui, v *gocui.View) error {
+			testSynced <- struct{}{}
+			return nil
+		})
+	})
+	// the layout runs after the manager is set
+	tc.press()
+	return tc
+}
+
+func startTestGui() {
+	master, slave, err := openPTY()
+	if err != nil {
+		testGuiErr = err
+		return
+	}
+	// termbox opens the terminal named by TTY and reads its capabilities of TERM
+	for key, value := range map[string]string{"TTY": slave, "TERM": "xterm"} {
+		old, ok := os.LookupEnv(key)
+		os.Setenv(key, value)
+		if ok {
+			defer os.Setenv(key, old)
+		} else {
+			defer os.Unsetenv(key)
+		}
+	}
+	g, err := gocui.NewGui(gocui.OutputNormal)
+	if err != nil {
+		master.Close()
+		testGuiErr = err
+		return
+	}
+	testGui, testPTY = g, master
+	// the screen is drawn to the terminal, it must be read for termbox not to block
+	go io.Copy(ioutil.Discard, master)
+	// the input mode is set when the main loop starts, see doRun
+	g.InputEsc = true
+	go func() {
+		testGuiErr = g.MainLoop()
+		close(testGuiDone)
+	}()
+}
+
+// openPTY opens a pseudo terminal, it returns the master and the path of the slave
+func openPTY() (*os.File, string, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		return nil, "", err
+	}
+	var n uint32
+	if err := ioctl(master, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	var unlock int32
+	if err := ioctl(master, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		master.Close()
+		return nil, "", err
+	}
+	return master, fmt.Sprintf("/dev/pts/%d", n), nil
+}
+
+// setWindowSize resizes the pseudo terminal, termbox reads the size on every flush
+func setWindowSize(pty *os.File, width, height int) error {
+	ws := struct {
+		Row, Col, X, Y uint16
+	}{Row: uint16(height), Col: uint16(width)}
+	return ioctl(pty, syscall.TIOCSWINSZ, uintptr(unsafe.Pointer(&ws)))
+}
+
+func ioctl(f *os.File, request, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), request, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// do runs f in the main loop and waits for it
+func (tc *testConsole) do(f func(g *gocui.Gui) error) {
+	tc.t.Helper()
+	result := make(chan error, 1)
+	tc.Gui.Update(func(g *gocui.Gui) error {
+		result <- f(g)
+		return nil
+	})
+	select {
+	case err := <-result:
+		if err != nil {
+			tc.t.Fatal(err)
+		}
+	case <-testGuiDone:
+		tc.t.Fatalf("console exited: %v", testGuiErr)
+	case <-time.After(testConsoleTimeout):
+		tc.t.Fatal("timed out waiting for the console")
+	}
+}
+
+// press types the keys and text to the console and waits until they are handled
+func (tc *testConsole) press(keys ...string) {
+	tc.t.Helper()
+	for _, key := range append(keys, syncKey) {
+		if _, err := testPTY.WriteString(key); err != nil {
+			tc.t.Fatal(err)
+		}
+	}
+	select {
+	case <-testSynced:
+	case <-testGuiDone:
+		tc.t.Fatalf("console exited: %v", testGuiErr)
+	case <-time.After(testConsoleTimeout):
+		tc.t.Fatal("timed out waiting for the console")
+	}
+}
+
+// fill replaces the text of the focused input and presses Enter
+func (tc *testConsole) fill(text string) {
+	tc.t.Helper()
+	tc.do(func(g *gocui.Gui) error {
+		v := g.CurrentView()
+		if v == nil || !v.Editable {
+			return fmt.Errorf("the current view is not an input")
+		}
+		v.Clear()
+		return v.SetCursor(0, 0)
+	})
+	tc.press(text, keyEnter)
+}
+
+// choose moves the cursor of the focused options to the option of value and
+// presses Enter
+func (tc *testConsole) choose(value string) {
+	tc.t.Helper()
+	name := tc.currentPanel()
+	s, ok := tc.elements[name].(*widgets.Select)
+	if !ok {
+		tc.t.Fatalf("%s is not a select", name)
+	}
+	// the options are searched downwards, then upwards from the last one
+	key, last := keyDown, -1
+	for {
+		var selected string
+		var index int
+		tc.do(func(g *gocui.Gui) error {
+			v := g.CurrentView()
+			_, oy := v.Origin()
+			_, cy := v.Cursor()
+			index = oy + cy
+			var err error
+			selected, err = s.GetData()
+			return err
+		})
+		if selected == value {
+			break
+		}
+		if index == last {
+			if key == keyUp {
+				tc.t.Fatalf("%s has no option %q", name, value)
+			}
+			key = keyUp
+		}
+		last = index
+		tc.press(key)
+	}
+	tc.press(keyEnter)
+}
+
+// currentPanel returns the panel of the focused view
+func (tc *testConsole) currentPanel() string {
+	tc.t.Helper()
+	var name string
+	tc.do(func(g *gocui.Gui) error {
+		if v := g.CurrentView(); v != nil {
+			name = strings.TrimSuffix(strings.TrimSuffix(v.Name(), "-input"), "-options")
+		}
+		return nil
+	})
+	return name
+}
+
+// viewContent returns the text of a view, it is empty when the view is not
+// shown. Panels draw their content in an update, the content of the element
+// is returned for them.
+func (tc *testConsole) viewContent(name string) string {
+	tc.t.Helper()
+	var content string
+	tc.do(func(g *gocui.Gui) error {
+		v, err := g.View(name)
+		if err == gocui.ErrUnknownView {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if panel, ok := tc.elements[name].(*widgets.Panel); ok {
+			content = strings.TrimSpace(panel.Content)
+		} else {
+			content = strings.TrimSpace(v.Buffer())
+		}
+		return nil
+	})
+	return content
+}
+
+// waitForContent waits until the view shows content, for views updated in the
+// background
+func (tc *testConsole) waitForContent(name, content string) {
+	tc.t.Helper()
+	deadline := time.Now().Add(testConsoleTimeout)
+	for tc.viewContent(name) != content {
+		if time.Now().After(deadline) {
+			tc.t.Fatalf("timed out waiting for %s to show %q", name, content)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// size returns the size of the screen
+func (tc *testConsole) size() (int, int) {
+	tc.t.Helper()
+	var x, y int
+	tc.do(func(g *gocui.Gui) error {
+		x, y = g.Size()
+		return nil
+	})
+	return x, y
+}
+
+// close stops the console, removes its views and resets the config
+func (tc *testConsole) close() {
+	tc.t.Helper()
+	tc.cancel()
+	tc.do(func(g *gocui.Gui) error {
+		g.SetManager()
+		cfg.Config = cfg.InstallConfig{}
+		preseededPanels = map[string]bool{}
+		return nil
+	})
+}
@@ -3,12 +3,15 @@ package console
 import (
 	"fmt"
 	"net"
-	"os/exec"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/jroimartin/gocui"
+	"github.com/rancher/harvester-installer/pkg/cluster"
 	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/rancher/harvester-installer/pkg/disk"
+	"github.com/rancher/harvester-installer/pkg/preflight"
 	"github.com/rancher/harvester-installer/pkg/util"
 	"github.com/rancher/harvester-installer/pkg/widgets"
 	"github.com/rancher/k3os/pkg/config"
@@ -28,7 +31,6 @@ func (c *Console) layoutInstall(g *gocui.Gui) error {
 			validatorPanel,
 			notePanel,
 			footerPanel,
-			askCreatePanel,
 		}
 		var e widgets.Element
 		for _, name := range initElements {
@@ -40,6 +42,17 @@ func (c *Console) layoutInstall(g *gocui.Gui) error {
 				return
 			}
 		}
+		if c.remote != nil {
+			if err = c.setContentByName(remoteInfoPanel, c.remote.getInfo()); err != nil {
+				return
+			}
+		}
+		if preseededPanels[askCreatePanel] {
+			if err = c.setContentByName(footerPanel, "<Use ESC to go back to previous section>"); err != nil {
+				return
+			}
+		}
+		err = showFirstStep(c)
 	})
 	return err
 }
@@ -51,16 +64,25 @@ func setPanels(c *Console) error {
 		addNotePanel,
 		addFooterPanel,
 		addDiskPanel,
+		addDataDisksPanel,
+		addPreflightPanel,
 		addAskCreatePanel,
 		addServerURLPanel,
 		addPasswordPanels,
 		addSSHKeyPanel,
 		addNetworkPanel,
+		addNetworkMethodPanel,
+		addStaticNetworkPanels,
+		addDNSServersPanel,
+		addNTPServersPanel,
+		addHostnamePanel,
 		addTokenPanel,
-		addProxyPanel,
+		addProxyPanels,
+		addRegistryPanel,
 		addCloudInitPanel,
 		addConfirmPanel,
 		addInstallPanel,
+		addRemotePanels,
 	}
 	for _, f := range funcs {
 		if err := f(c); err != nil {
@@ -109,7 +131,38 @@ func addFooterPanel(c *Console) error {
 }
 
 func addDiskPanel(c *Console) error {
-	diskV, err := widgets.NewSelect(c.Gui, diskPanel, "", getDiskOptions)
+	var disks map[string]disk.Disk
+	maxX, maxY := c.Gui.Size()
+	diskOptionsFunc := func() ([]widgets.Option, error) {
+		scanned, err := disk.NewScanner().Scan()
+		if err != nil {
+			return nil, err
+		}
+		disks = make(map[string]disk.Disk)
+		for _, d := range scanned {
+			disks[d.ID()] = d
+		}
+		// width of the options view without the frame
+		return getDiskOptions(scanned, maxX/8*7-maxX/8-1), nil
+	}
+	diskV, err := widgets.NewSelect(c.Gui, diskPanel, "", diskOptionsFunc)
+	if err != nil {
+		return err
+	}
+	diskV.SetLocation(maxX/8, maxY/4, maxX/8*7, maxY/4*3)
+
+	diskConfirmOptionsFunc := func() ([]widgets.Option, error) {
+		return []widgets.Option{
+			{
+				Value: "no",
+				Text:  "No, choose another disk",
+			}, {
+				Value: "yes",
+				Text:  "Yes, erase all data on the disk",
+			},
+		}, nil
+	}
+	diskConfirmV, err := widgets.NewSelect(c.Gui, diskConfirmPanel, "", diskConfirmOptionsFunc)
 	if err != nil {
 		return err
 	}
@@ -119,45 +172,313 @@ func addDiskPanel(c *Console) error {
 			if err != nil {
 				return err
 			}
-			cfg.Config.K3OS.Install = &config.Install{
-				Device: device,
+			if device == "" {
+				return c.setContentByName(validatorPanel, fmt.Sprintf("No disk of at least %s found", util.FormatSize(preflight.MinDiskSize)))
 			}
-			diskV.Close()
-			if cfg.Config.InstallMode == modeCreate {
-				return showNext(c, tokenPanel)
+			if cfg.Config.K3OS.Install == nil {
+				cfg.Config.K3OS.Install = &config.Install{}
 			}
-			return showNext(c, serverURLPanel)
+			cfg.Config.K3OS.Install.Device = device
+			cfg.Config.DataDisks = removeString(cfg.Config.DataDisks, device)
+			if d := disks[device]; d.HasData() {
+				diskV.Close()
+				diskConfirmV.Content = getDiskDataWarning(d)
+				return diskConfirmV.Show()
+			}
+			return showNextStep(c, diskPanel)
 		},
 		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
-			diskV.Close()
-			return showNext(c, askCreatePanel)
+			return showPrevStep(c, diskPanel)
 		},
 	}
 	diskV.PreShow = func() error {
+		c.Gui.Cursor = false
 		return c.setContentByName(titlePanel, "Choose installation target. Device will be formatted")
 	}
 	c.AddElement(diskPanel, diskV)
+
+	diskConfirmV.PreShow = func() error {
+		c.Gui.Cursor = false
+		return c.setContentByName(titlePanel, "The selected disk is not empty")
+	}
+	diskConfirmV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			confirmed, err := diskConfirmV.GetData()
+			if err != nil {
+				return err
+			}
+			diskConfirmV.Close()
+			if confirmed == "yes" {
+				return showNextStep(c, diskPanel)
+			}
+			return diskV.Show()
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			diskConfirmV.Close()
+			return diskV.Show()
+		},
+	}
+	c.AddElement(diskConfirmPanel, diskConfirmV)
 	return nil
 }
 
-func getDiskOptions() ([]widgets.Option, error) {
-	output, err := exec.Command("/bin/sh", "-c", `lsblk -r -o NAME,SIZE,TYPE | grep -w disk|cut -d ' ' -f 1,2`).CombinedOutput()
+func addDataDisksPanel(c *Console) error {
+	var disks map[string]disk.Disk
+	maxX, maxY := c.Gui.Size()
+	dataDisksOptionsFunc := func() ([]widgets.Option, error) {
+		scanned, err := disk.NewScanner().Scan()
+		if err != nil {
+			return nil, err
+		}
+		disks = make(map[string]disk.Disk)
+		for _, d := range scanned {
+			disks[d.ID()] = d
+		}
+		var installDevice string
+		if cfg.Config.K3OS.Install != nil {
+			installDevice = cfg.Config.K3OS.Install.Device
+		}
+		return getDataDiskOptions(scanned, installDevice, cfg.Config.DataDisks, maxX/8*7-maxX/8-1), nil
+	}
+	dataDisksV, err := widgets.NewSelect(c.Gui, dataDisksPanel, "", dataDisksOptionsFunc)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	dataDisksV.SetLocation(maxX/8, maxY/4, maxX/8*7, maxY/4*3)
+
+	dataDisksConfirmOptionsFunc := func() ([]widgets.Option, error) {
+		return []widgets.Option{
+			{
+				Value: "no",
+				Text:  "No, choose other disks",
+			}, {
+				Value: "yes",
+				Text:  "Yes, erase all data on the disks",
+			},
+		}, nil
+	}
+	dataDisksConfirmV, err := widgets.NewSelect(c.Gui, dataDisksConfirmPanel, "", dataDisksConfirmOptionsFunc)
+	if err != nil {
+		return err
+	}
+	dataDisksV.PreShow = func() error {
+		c.Gui.Cursor = false
+		return c.setContentByName(titlePanel, "Optional: choose data disks for VM storage. Disks will be formatted")
+	}
+	dataDisksV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			selected, err := dataDisksV.GetData()
+			if err != nil {
+				return err
+			}
+			if selected == dataDisksDone {
+				var nonEmpty []disk.Disk
+				for _, selected := range cfg.Config.DataDisks {
+					if d := disks[selected]; d.HasData() {
+						nonEmpty = append(nonEmpty, d)
+					}
+				}
+				if len(nonEmpty) > 0 {
+					dataDisksV.Close()
+					dataDisksConfirmV.Content = getDiskDataWarning(nonEmpty...)
+					return dataDisksConfirmV.Show()
+				}
+				return showNextStep(c, dataDisksPanel)
+			}
+			_, oy := v.Origin()
+			_, cy := v.Cursor()
+			dataDisksV.Close()
+			// toggle the disk and stay on it
+			if removed := removeString(cfg.Config.DataDisks, selected); len(removed) < len(cfg.Config.DataDisks) {
+				cfg.Config.DataDisks = removed
+			} else {
+				cfg.Config.DataDisks = append(cfg.Config.DataDisks, selected)
+			}
+			if err := dataDisksV.Show(); err != nil {
+				return err
+			}
+			return dataDisksV.SetCursor(oy + cy)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return showPrevStep(c, dataDisksPanel)
+		},
+	}
+	c.AddElement(dataDisksPanel, dataDisksV)
+
+	dataDisksConfirmV.PreShow = func() error {
+		c.Gui.Cursor = false
+		return c.setContentByName(titlePanel, "The selected data disks are not empty")
+	}
+	dataDisksConfirmV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			confirmed, err := dataDisksConfirmV.GetData()
+			if err != nil {
+				return err
+			}
+			dataDisksConfirmV.Close()
+			if confirmed == "yes" {
+				return showNextStep(c, dataDisksPanel)
+			}
+			return dataDisksV.Show()
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			dataDisksConfirmV.Close()
+			return dataDisksV.Show()
+		},
+	}
+	c.AddElement(dataDisksConfirmPanel, dataDisksConfirmV)
+	return nil
+}
+
+func addPreflightPanel(c *Console) error {
+	var results []preflight.Result
+	preflightOptionsFunc := func() ([]widgets.Option, error) {
+		if !preflight.HasFailure(results) {
+			return []widgets.Option{
+				{
+					Value: "continue",
+					Text:  "Continue",
+				},
+			}, nil
+		}
+		return []widgets.Option{
+			{
+				Value: "recheck",
+				Text:  "Check again",
+			}, {
+				Value: "ignore",
+				Text:  "Ignore failures and continue (unsupported)",
+			},
+		}, nil
+	}
+	preflightV, err := widgets.NewSelect(c.Gui, preflightPanel, "", preflightOptionsFunc)
+	if err != nil {
+		return err
+	}
+	preflightV.PreShow = func() error {
+		c.Gui.Cursor = false
+		var device string
+		if cfg.Config.K3OS.Install != nil {
+			device = cfg.Config.K3OS.Install.Device
+		}
+		results = preflight.NewChecker().Run(device)
+		preflightV.Content = preflight.FormatResults(results)
+		if preflight.HasFailure(results) {
+			preflightV.Content += "\nThe host doesn't meet the minimum requirements of Harvester.\n"
+		}
+		return c.setContentByName(titlePanel, "Hardware checks")
+	}
+	preflightV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			selected, err := preflightV.GetData()
+			if err != nil {
+				return err
+			}
+			if selected == "recheck" {
+				preflightV.Close()
+				return preflightV.Show()
+			}
+			cfg.Config.SkipChecks = selected == "ignore"
+			return showNextStep(c, preflightPanel)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return showPrevStep(c, preflightPanel)
+		},
 	}
-	lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+	c.AddElement(preflightPanel, preflightV)
+	return nil
+}
+
+// getDiskOptions returns the disks which can be install targets, the installer
+// media and disks smaller than the minimum size are hidden
+func getDiskOptions(disks []disk.Disk, width int) []widgets.Option {
 	var options []widgets.Option
-	for _, line := range lines {
-		splits := strings.SplitN(line, " ", 2)
-		if len(splits) == 2 {
-			options = append(options, widgets.Option{
-				Value: "/dev/" + splits[0],
-				Text:  line,
-			})
+	for _, d := range disks {
+		if d.IsInstaller() || d.Size < preflight.MinDiskSize {
+			continue
 		}
+		options = append(options, widgets.Option{
+			Value: d.ID(),
+			Text:  getDiskText(d, width),
+		})
 	}
+	return options
+}
 
-	return options, nil
+// getDataDiskOptions returns the disks which can be data disks with a mark
+// for the selected ones, following an option to finish the selection
+func getDataDiskOptions(disks []disk.Disk, installDevice string, selected []string, width int) []widgets.Option {
+	options := []widgets.Option{
+		{
+			Value: dataDisksDone,
+			Text:  "Continue",
+		},
+	}
+	for _, d := range disks {
+		if d.IsInstaller() || d.ID() == installDevice || d.Path == installDevice {
+			continue
+		}
+		mark := "[ ] "
+		for _, s := range selected {
+			if s == d.ID() {
+				mark = "[x] "
+			}
+		}
+		options = append(options, widgets.Option{
+			Value: d.ID(),
+			Text:  mark + getDiskText(d, width-len(mark)),
+		})
+	}
+	return options
+}
+
+// getDiskText describes the disk in a line of at most width characters
+func getDiskText(d disk.Disk, width int) string {
+	text := fmt.Sprintf("%s %s %s", d.Path, util.FormatSize(d.Size), d.Type())
+	if description := d.Description(); description != "" {
+		text += " " + description
+	}
+	if d.HasData() {
+		text += " [" + strings.Join(getDiskContents(d), ", ") + "]"
+	}
+	// options must fit in a line to keep the cursor on the selected option
+	if width > 3 && len(text) > width {
+		text = text[:width-3] + "..."
+	}
+	return text
+}
+
+// getDiskContents describes the partition table and file systems of the disk
+func getDiskContents(d disk.Disk) []string {
+	var contents []string
+	if d.PartitionTable != "" {
+		contents = append(contents, d.PartitionTable)
+	}
+	for _, fs := range d.FileSystems {
+		content := fs.Type
+		if fs.Label != "" {
+			content += ":" + fs.Label
+		}
+		contents = append(contents, content)
+	}
+	return contents
+}
+
+func getDiskDataWarning(disks ...disk.Disk) string {
+	var warning string
+	for _, d := range disks {
+		warning += fmt.Sprintf("%s %s contains data:\n", d.Path, d.Description())
+		if d.PartitionTable != "" {
+			warning += fmt.Sprintf("  %s partition table\n", d.PartitionTable)
+		}
+		for _, fs := range d.FileSystems {
+			warning += fmt.Sprintf("  %s %s %s\n", fs.Device, fs.Type, fs.Label)
+		}
+	}
+	if len(disks) > 1 {
+		return warning + "All data on the disks will be lost. Continue?\n"
+	}
+	return warning + "All data on the disk will be lost. Continue?\n"
 }
 
 func addAskCreatePanel(c *Console) error {
@@ -178,6 +499,8 @@ func addAskCreatePanel(c *Console) error {
 		return err
 	}
 	askCreateV.PreShow = func() error {
+		c.Gui.Cursor = false
+		askCreateV.Selected = cfg.Config.InstallMode
 		if err := c.setContentByName(footerPanel, ""); err != nil {
 			return err
 		}
@@ -192,13 +515,16 @@ func addAskCreatePanel(c *Console) error {
 			if err != nil {
 				return err
 			}
-			askCreateV.Close()
 			if selected == modeCreate {
 				cfg.Config.InstallMode = modeCreate
 			} else {
 				cfg.Config.InstallMode = modeJoin
 			}
-			return showNext(c, diskPanel)
+			return showNextStep(c, askCreatePanel)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			// the mode is edited from review
+			return showPrevStep(c, askCreatePanel)
 		},
 	}
 	c.AddElement(askCreatePanel, askCreateV)
@@ -212,6 +538,7 @@ func addServerURLPanel(c *Console) error {
 	}
 	serverURLV.PreShow = func() error {
 		c.Gui.Cursor = true
+		serverURLV.Value = cfg.Config.K3OS.ServerURL
 		if err := c.setContentByName(titlePanel, "Configure management address"); err != nil {
 			return err
 		}
@@ -223,17 +550,18 @@ func addServerURLPanel(c *Console) error {
 			if err != nil {
 				return err
 			}
-			if serverURL == "" {
-				return c.setContentByName(validatorPanel, "Management address is required")
+			if err := cfg.ValidateServerURL(serverURL); err != nil {
+				return c.setContentByName(validatorPanel, err.Error())
+			}
+			if serverURL = getFormattedServerURL(serverURL); serverURL != cfg.Config.K3OS.ServerURL {
+				// the token is checked against the new server
+				c.serverFingerprint = ""
 			}
-			serverURLV.Close()
-			cfg.Config.K3OS.ServerURL = getFormattedServerURL(serverURL)
-			return showNext(c, tokenPanel)
+			cfg.Config.K3OS.ServerURL = serverURL
+			return showNextStep(c, serverURLPanel)
 		},
 		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
-			g.Cursor = false
-			serverURLV.Close()
-			return showNext(c, diskPanel)
+			return showPrevStep(c, serverURLPanel)
 		},
 	}
 	c.AddElement(serverURLPanel, serverURLV)
@@ -259,12 +587,7 @@ func addPasswordPanels(c *Console) error {
 			return showNext(c, passwordConfirmPanel)
 		},
 		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
-			passwordV.Close()
-			passwordConfirmV.Close()
-			if err := c.setContentByName(notePanel, ""); err != nil {
-				return err
-			}
-			return showNext(c, tokenPanel)
+			return showPrevStep(c, passwordPanel)
 		},
 	}
 	passwordV.SetLocation(maxX/4, maxY/4, maxX/4*3, maxY/4+2)
@@ -295,25 +618,18 @@ func addPasswordPanels(c *Console) error {
 			if password1 != password2 {
 				return c.setContentByName(validatorPanel, "Password mismatching")
 			}
-			if password1 == "" {
-				return c.setContentByName(validatorPanel, "Password is required")
+			if err := cfg.ValidatePassword(password1); err != nil {
+				return c.setContentByName(validatorPanel, err.Error())
 			}
-			password1V.Close()
-			passwordConfirmV.Close()
 			encrpyted, err := util.GetEncrptedPasswd(password1)
 			if err != nil {
 				return err
 			}
 			cfg.Config.K3OS.Password = encrpyted
-			return showNext(c, sshKeyPanel)
+			return showNextStep(c, passwordPanel)
 		},
 		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
-			passwordV.Close()
-			passwordConfirmV.Close()
-			if err := c.setContentByName(notePanel, ""); err != nil {
-				return err
-			}
-			return showNext(c, tokenPanel)
+			return showPrevStep(c, passwordPanel)
 		},
 	}
 	passwordConfirmV.SetLocation(maxX/4, maxY/4+3, maxX/4*3, maxY/4+5)
@@ -329,6 +645,7 @@ func addSSHKeyPanel(c *Console) error {
 	}
 	sshKeyV.PreShow = func() error {
 		c.Gui.Cursor = true
+		sshKeyV.Value = cfg.Config.SSHKeyURL
 		if err := c.setContentByName(titlePanel, "Optional: import SSH keys"); err != nil {
 			return err
 		}
@@ -340,15 +657,11 @@ func addSSHKeyPanel(c *Console) error {
 			if err != nil {
 				return err
 			}
-			if url != "" {
-				cfg.Config.SSHKeyURL = url
-			}
-			sshKeyV.Close()
-			return showNext(c, networkPanel)
+			cfg.Config.SSHKeyURL = url
+			return showNextStep(c, sshKeyPanel)
 		},
 		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
-			sshKeyV.Close()
-			return showNext(c, passwordConfirmPanel, passwordPanel)
+			return showPrevStep(c, sshKeyPanel)
 		},
 	}
 	c.AddElement(sshKeyPanel, sshKeyV)
@@ -356,13 +669,22 @@ func addSSHKeyPanel(c *Console) error {
 }
 
 func addTokenPanel(c *Console) error {
+	var warned string
 	tokenV, err := widgets.NewInput(c.Gui, tokenPanel, "Cluster token", false)
 	if err != nil {
 		return err
 	}
 	tokenV.PreShow = func() error {
 		c.Gui.Cursor = true
+		tokenV.Value = cfg.Config.K3OS.Token
 		if cfg.Config.InstallMode == modeCreate {
+			if tokenV.Value == "" {
+				token, err := generateToken()
+				if err != nil {
+					return err
+				}
+				tokenV.Value = token
+			}
 			if err := c.setContentByName(notePanel, clusterTokenNote); err != nil {
 				return err
 			}
@@ -378,17 +700,35 @@ func addTokenPanel(c *Console) error {
 			if token == "" {
 				return c.setContentByName(validatorPanel, "Cluster token is required")
 			}
+			if err := cfg.ValidateToken(cfg.Config.InstallMode, token); err != nil {
+				return c.setContentByName(validatorPanel, err.Error())
+			}
+			if cfg.Config.InstallMode == modeJoin && token != warned {
+				serverURL := cfg.Config.K3OS.ServerURL
+				var result *cluster.Result
+				return runCheck(c, func() (err error) {
+					result, err = cluster.NewChecker().Check(serverURL, token)
+					return err
+				}, func(err error) error {
+					if err != nil {
+						logrus.Warnf("failed to check joining %s: %v", serverURL, err)
+						message, warning := getJoinCheckMessage(err)
+						if !warning {
+							return c.setContentByName(validatorPanel, message)
+						}
+						warned = token
+						return c.setContentByName(validatorPanel, message+". Press Enter to use anyway")
+					}
+					c.serverFingerprint = result.Fingerprint
+					cfg.Config.K3OS.Token = token
+					return showNextStep(c, tokenPanel)
+				})
+			}
 			cfg.Config.K3OS.Token = token
-			tokenV.Close()
-			return showNext(c, passwordConfirmPanel, passwordPanel)
+			return showNextStep(c, tokenPanel)
 		},
 		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
-			tokenV.Close()
-			if cfg.Config.InstallMode == modeCreate {
-				g.Cursor = false
-				return showNext(c, diskPanel)
-			}
-			return showNext(c, serverURLPanel)
+			return showPrevStep(c, tokenPanel)
 		},
 	}
 	c.AddElement(tokenPanel, tokenV)
@@ -410,197 +750,1009 @@ func addNetworkPanel(c *Console) error {
 			if err != nil {
 				return err
 			}
-			if iface != "" {
-				cfg.Config.ExtraK3sArgs = append(cfg.Config.ExtraK3sArgs, "--flannel-iface", iface)
-			}
-			networkV.Close()
-			return showNext(c, proxyPanel)
+			cfg.Config.Network.Interface = iface
+			return showNextStep(c, networkPanel)
 		},
 		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
-			networkV.Close()
-			return showNext(c, sshKeyPanel)
+			return showPrevStep(c, networkPanel)
 		},
 	}
 	c.AddElement(networkPanel, networkV)
 	return nil
 }
 
-func getNetworkInterfaceOptions() ([]widgets.Option, error) {
-	var options = []widgets.Option{}
-	ifaces, err := net.Interfaces()
+func addNetworkMethodPanel(c *Console) error {
+	methodOptionsFunc := func() ([]widgets.Option, error) {
+		return []widgets.Option{
+			{
+				Value: networkMethodDHCP,
+				Text:  "Automatic (DHCP)",
+			}, {
+				Value: networkMethodStatic,
+				Text:  "Static IP",
+			},
+		}, nil
+	}
+	networkMethodV, err := widgets.NewSelect(c.Gui, networkMethodPanel, "", methodOptionsFunc)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	for _, i := range ifaces {
-		if i.Flags&net.FlagLoopback != 0 {
-			continue
-		}
-		addrs, err := i.Addrs()
-		if err != nil {
-			return nil, err
-		}
-		var ips []string
-		for _, addr := range addrs {
-			if ipnet, ok := addr.(*net.IPNet); ok {
-				if ipnet.IP.To4() != nil {
-					ips = append(ips, ipnet.String())
-				}
+	networkMethodV.PreShow = func() error {
+		c.Gui.Cursor = false
+		return c.setContentByName(titlePanel, "Choose IPv4 addressing of the management network")
+	}
+	networkMethodV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			method, err := networkMethodV.GetData()
+			if err != nil {
+				return err
 			}
-		}
-		option := widgets.Option{
-			Value: i.Name,
-			Text:  i.Name,
-		}
-		if len(ips) > 0 {
-			option.Text = fmt.Sprintf("%s (%s)", i.Name, strings.Join(ips, ","))
-		}
-		options = append(options, option)
+			cfg.Config.Network.Method = method
+			return showNextStep(c, networkMethodPanel)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return showPrevStep(c, networkMethodPanel)
+		},
 	}
-	return options, nil
+	c.AddElement(networkMethodPanel, networkMethodV)
+	return nil
 }
 
-func addProxyPanel(c *Console) error {
-	proxyV, err := widgets.NewInput(c.Gui, proxyPanel, "Proxy address", false)
+func addStaticNetworkPanels(c *Console) error {
+	maxX, maxY := c.Gui.Size()
+	addressV, err := widgets.NewInput(c.Gui, addressPanel, "IPv4 address (CIDR)", false)
+	if err != nil {
+		return err
+	}
+	gatewayV, err := widgets.NewInput(c.Gui, gatewayPanel, "Gateway", false)
 	if err != nil {
 		return err
 	}
-	proxyV.PreShow = func() error {
+	addressV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			return showNext(c, gatewayPanel)
+		},
+		gocui.KeyArrowDown: func(g *gocui.Gui, v *gocui.View) error {
+			return showNext(c, gatewayPanel)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return showPrevStep(c, addressPanel)
+		},
+	}
+	addressV.SetLocation(maxX/4, maxY/4, maxX/4*3, maxY/4+2)
+	c.AddElement(addressPanel, addressV)
+
+	gatewayV.PreShow = func() error {
 		c.Gui.Cursor = true
-		if err := c.setContentByName(titlePanel, "Optional: configure proxy"); err != nil {
-			return err
-		}
-		return c.setContentByName(notePanel, proxyNote)
+		// the gateway is shown before the address
+		addressV.Value = cfg.Config.Network.IP
+		gatewayV.Value = cfg.Config.Network.Gateway
+		c.setContentByName(notePanel, "")
+		return c.setContentByName(titlePanel, "Configure static IPv4 address of the management network")
 	}
-	proxyV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+	gatewayV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyArrowUp: func(g *gocui.Gui, v *gocui.View) error {
+			return showNext(c, addressPanel)
+		},
 		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
-			proxy, err := proxyV.GetData()
+			address, err := addressV.GetData()
 			if err != nil {
 				return err
 			}
-			if proxy != "" {
-				if cfg.Config.K3OS.Environment == nil {
-					cfg.Config.K3OS.Environment = make(map[string]string)
-				}
-				cfg.Config.K3OS.Environment["http_proxy"] = proxy
-				cfg.Config.K3OS.Environment["https_proxy"] = proxy
-			}
-			proxyV.Close()
-			noteV, err := c.GetElement(notePanel)
+			gateway, err := gatewayV.GetData()
 			if err != nil {
 				return err
 			}
-			noteV.Close()
-			return showNext(c, cloudInitPanel)
+			cfg.Config.Network.IP = address
+			cfg.Config.Network.Gateway = gateway
+			return showNextStep(c, addressPanel)
 		},
 		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
-			proxyV.Close()
-			return showNext(c, networkPanel)
+			return showPrevStep(c, addressPanel)
 		},
 	}
-	c.AddElement(proxyPanel, proxyV)
+	gatewayV.SetLocation(maxX/4, maxY/4+3, maxX/4*3, maxY/4+5)
+	c.AddElement(gatewayPanel, gatewayV)
 	return nil
 }
 
-func addCloudInitPanel(c *Console) error {
-	cloudInitV, err := widgets.NewInput(c.Gui, cloudInitPanel, "HTTP URL", false)
+func addDNSServersPanel(c *Console) error {
+	dnsServersV, err := widgets.NewInput(c.Gui, dnsServersPanel, "DNS servers", false)
 	if err != nil {
 		return err
 	}
-	cloudInitV.PreShow = func() error {
-		return c.setContentByName(titlePanel, "Optional: configure cloud-init")
+	// servers failing the reachability check are accepted when entered twice
+	var warned string
+	dnsServersV.PreShow = func() error {
+		c.Gui.Cursor = true
+		dnsServersV.Value = strings.Join(cfg.Config.K3OS.DNSNameservers, ",")
+		title := "Optional: configure DNS servers"
+		if cfg.Config.Network.Method == networkMethodStatic {
+			title = "Configure DNS servers"
+		}
+		if err := c.setContentByName(titlePanel, title); err != nil {
+			return err
+		}
+		return c.setContentByName(notePanel, dnsServersNote)
 	}
-	cloudInitV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+	dnsServersV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
 		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
-			configURL, err := cloudInitV.GetData()
-			if err != nil {
-				return err
-			}
-			confirmV, err := c.GetElement(confirmPanel)
+			data, err := dnsServersV.GetData()
 			if err != nil {
 				return err
 			}
-			cfg.Config.K3OS.Install.ConfigURL = configURL
-			cloudInitV.Close()
-			installBytes, err := config.PrintInstall(cfg.Config.CloudConfig)
+			servers, err := parseDNSServers(data)
 			if err != nil {
-				return err
+				return c.setContentByName(validatorPanel, err.Error())
 			}
-			options := fmt.Sprintf("install mode: %v\n", cfg.Config.InstallMode)
-			if proxy, ok := cfg.Config.K3OS.Environment["http_proxy"]; ok {
-				options += fmt.Sprintf("proxy address: %v\n", proxy)
+			static := cfg.Config.Network.Method == networkMethodStatic
+			if static && len(servers) == 0 {
+				return c.setContentByName(validatorPanel, "DNS servers are required for static IP")
 			}
-			options += string(installBytes)
-			logrus.Debug("cfm cfg: ", fmt.Sprintf("%+v", cfg.Config.K3OS.Install))
-			if cfg.Config.K3OS.Install != nil && !cfg.Config.K3OS.Install.Silent {
-				confirmV.SetContent(options +
-					"\nYour disk will be formatted and Harvester will be installed with \nthe above configuration. Continue?\n")
+			// the static address is not configured in the installer, skip checking then
+			if !static && data != warned {
+				return runCheck(c, func() error {
+					return checkServers(servers, util.CheckDNSServer)
+				}, func(err error) error {
+					if err != nil {
+						warned = data
+						return c.setContentByName(validatorPanel, err.Error()+". Press Enter to use anyway")
+					}
+					cfg.Config.K3OS.DNSNameservers = servers
+					return showNextStep(c, dnsServersPanel)
+				})
 			}
-			g.Cursor = false
-			return showNext(c, confirmPanel)
+			cfg.Config.K3OS.DNSNameservers = servers
+			return showNextStep(c, dnsServersPanel)
 		},
 		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
-			cloudInitV.Close()
-			return showNext(c, proxyPanel)
+			return showPrevStep(c, dnsServersPanel)
 		},
 	}
-	c.AddElement(cloudInitPanel, cloudInitV)
+	c.AddElement(dnsServersPanel, dnsServersV)
 	return nil
 }
 
-func addConfirmPanel(c *Console) error {
-	askOptionsFunc := func() ([]widgets.Option, error) {
-		return []widgets.Option{
-			{
-				Value: "yes",
-				Text:  "Yes",
-			}, {
-				Value: "no",
-				Text:  "No",
-			},
-		}, nil
-	}
-	confirmV, err := widgets.NewSelect(c.Gui, confirmPanel, "", askOptionsFunc)
+func addNTPServersPanel(c *Console) error {
+	ntpServersV, err := widgets.NewInput(c.Gui, ntpServersPanel, "NTP servers", false)
 	if err != nil {
 		return err
 	}
-	confirmV.PreShow = func() error {
-		return c.setContentByName(titlePanel, "Confirm installation options")
-	}
+	// servers failing the reachability check are accepted when entered twice
+	var warned string
+	ntpServersV.PreShow = func() error {
+		c.Gui.Cursor = true
+		ntpServersV.Value = strings.Join(cfg.Config.K3OS.NTPServers, ",")
+		if err := c.setContentByName(titlePanel, "Optional: configure NTP servers"); err != nil {
+			return err
+		}
+		return c.setContentByName(notePanel, ntpServersNote)
+	}
+	ntpServersV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			data, err := ntpServersV.GetData()
+			if err != nil {
+				return err
+			}
+			servers, err := parseNTPServers(data)
+			if err != nil {
+				return c.setContentByName(validatorPanel, err.Error())
+			}
+			if data != warned {
+				return runCheck(c, func() error {
+					return checkServers(servers, util.CheckNTPServer)
+				}, func(err error) error {
+					if err != nil {
+						warned = data
+						return c.setContentByName(validatorPanel, err.Error()+". Press Enter to use anyway")
+					}
+					cfg.Config.K3OS.NTPServers = servers
+					return showNextStep(c, ntpServersPanel)
+				})
+			}
+			cfg.Config.K3OS.NTPServers = servers
+			return showNextStep(c, ntpServersPanel)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return showPrevStep(c, ntpServersPanel)
+		},
+	}
+	c.AddElement(ntpServersPanel, ntpServersV)
+	return nil
+}
+
+func addHostnamePanel(c *Console) error {
+	hostnameV, err := widgets.NewInput(c.Gui, hostnamePanel, "Hostname", false)
+	if err != nil {
+		return err
+	}
+	var (
+		options []string
+		current int
+		// a hostname that couldn't be checked against the cluster is accepted when entered twice
+		warned string
+	)
+	hostnameV.PreShow = func() error {
+		c.Gui.Cursor = true
+		options = getHostnameOptions(cfg.Config.Network.Interface)
+		current = 0
+		hostnameV.Value = cfg.Config.Hostname
+		if hostnameV.Value == "" && len(options) > 0 {
+			hostnameV.Value = options[0]
+		}
+		if err := c.setContentByName(titlePanel, "Configure hostname"); err != nil {
+			return err
+		}
+		return c.setContentByName(notePanel, hostnameNote)
+	}
+	hostnameV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyTab: func(g *gocui.Gui, v *gocui.View) error {
+			if len(options) == 0 {
+				return nil
+			}
+			current = (current + 1) % len(options)
+			return hostnameV.SetData(options[current])
+		},
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			data, err := hostnameV.GetData()
+			if err != nil {
+				return err
+			}
+			hostname, err := renderHostname(strings.TrimSpace(data), cfg.Config.Network.Interface)
+			if err != nil {
+				return c.setContentByName(validatorPanel, fmt.Sprintf("Failed to render hostname: %v", err))
+			}
+			if err := cfg.ValidateHostname(hostname); err != nil {
+				return c.setContentByName(validatorPanel, err.Error())
+			}
+			if cfg.Config.InstallMode == modeJoin && hostname != warned {
+				// the cluster is checked with a copy, the config is only
+				// changed on the main loop
+				joined := cfg.Config
+				var message string
+				var warning bool
+				return runCheck(c, func() error {
+					message, warning = checkClusterHostname(&joined, hostname)
+					return nil
+				}, func(error) error {
+					if message != "" {
+						if !warning {
+							return c.setContentByName(validatorPanel, message)
+						}
+						warned = hostname
+						return c.setContentByName(validatorPanel, message+". Press Enter to use anyway")
+					}
+					cfg.Config.Hostname = hostname
+					return showNextStep(c, hostnamePanel)
+				})
+			}
+			cfg.Config.Hostname = hostname
+			return showNextStep(c, hostnamePanel)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return showPrevStep(c, hostnamePanel)
+		},
+	}
+	c.AddElement(hostnamePanel, hostnameV)
+	return nil
+}
+
+func getNetworkInterfaceOptions() ([]widgets.Option, error) {
+	var options = []widgets.Option{}
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, i := range ifaces {
+		if i.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := i.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		var ips []string
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok {
+				if ipnet.IP.To4() != nil {
+					ips = append(ips, ipnet.String())
+				}
+			}
+		}
+		option := widgets.Option{
+			Value: i.Name,
+			Text:  i.Name,
+		}
+		if len(ips) > 0 {
+			option.Text = fmt.Sprintf("%s (%s)", i.Name, strings.Join(ips, ","))
+		}
+		options = append(options, option)
+	}
+	return options, nil
+}
+
+func addProxyPanels(c *Console) error {
+	maxX, maxY := c.Gui.Size()
+	proxyV, err := widgets.NewInput(c.Gui, proxyPanel, "HTTP proxy", false)
+	if err != nil {
+		return err
+	}
+	httpsProxyV, err := widgets.NewInput(c.Gui, httpsProxyPanel, "HTTPS proxy", false)
+	if err != nil {
+		return err
+	}
+	proxyV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			return showNext(c, httpsProxyPanel)
+		},
+		gocui.KeyArrowDown: func(g *gocui.Gui, v *gocui.View) error {
+			return showNext(c, httpsProxyPanel)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return showPrevStep(c, proxyPanel)
+		},
+	}
+	proxyV.SetLocation(maxX/4, maxY/4, maxX/4*3, maxY/4+2)
+	c.AddElement(proxyPanel, proxyV)
+
+	httpsProxyV.PreShow = func() error {
+		c.Gui.Cursor = true
+		// credentials are entered separately, so that the password is masked
+		httpProxy, httpsProxy, _ := getProxies(&cfg.Config)
+		proxyV.Value = setProxyUser(httpProxy, "", "")
+		httpsProxyV.Value = setProxyUser(httpsProxy, "", "")
+		c.setContentByName(notePanel, "")
+		return c.setContentByName(titlePanel, "Optional: configure proxy, the HTTPS proxy defaults to the HTTP proxy")
+	}
+	httpsProxyV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyArrowUp: func(g *gocui.Gui, v *gocui.View) error {
+			return showNext(c, proxyPanel)
+		},
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			httpProxy, err := proxyV.GetData()
+			if err != nil {
+				return err
+			}
+			httpsProxy, err := httpsProxyV.GetData()
+			if err != nil {
+				return err
+			}
+			if err := setProxyAddresses(&cfg.Config, httpProxy, httpsProxy); err != nil {
+				return c.setContentByName(validatorPanel, err.Error())
+			}
+			return showNextStep(c, proxyPanel)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return showPrevStep(c, proxyPanel)
+		},
+	}
+	httpsProxyV.SetLocation(maxX/4, maxY/4+3, maxX/4*3, maxY/4+5)
+	c.AddElement(httpsProxyPanel, httpsProxyV)
+
+	if err := addProxyUserPanels(c); err != nil {
+		return err
+	}
+	return addNoProxyPanel(c)
+}
+
+func addProxyUserPanels(c *Console) error {
+	maxX, maxY := c.Gui.Size()
+	proxyUserV, err := widgets.NewInput(c.Gui, proxyUserPanel, "Proxy username", false)
+	if err != nil {
+		return err
+	}
+	proxyPasswordV, err := widgets.NewInput(c.Gui, proxyPasswordPanel, "Proxy password", true)
+	if err != nil {
+		return err
+	}
+	proxyUserV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			return showNext(c, proxyPasswordPanel)
+		},
+		gocui.KeyArrowDown: func(g *gocui.Gui, v *gocui.View) error {
+			return showNext(c, proxyPasswordPanel)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return showPrevStep(c, proxyUserPanel)
+		},
+	}
+	proxyUserV.SetLocation(maxX/4, maxY/4, maxX/4*3, maxY/4+2)
+	c.AddElement(proxyUserPanel, proxyUserV)
+
+	proxyPasswordV.PreShow = func() error {
+		c.Gui.Cursor = true
+		httpProxy, httpsProxy, _ := getProxies(&cfg.Config)
+		if httpProxy == "" {
+			httpProxy = httpsProxy
+		}
+		proxyUserV.Value, proxyPasswordV.Value = getProxyUser(httpProxy)
+		c.setContentByName(notePanel, "")
+		return c.setContentByName(titlePanel, "Optional: configure proxy credentials")
+	}
+	proxyPasswordV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyArrowUp: func(g *gocui.Gui, v *gocui.View) error {
+			return showNext(c, proxyUserPanel)
+		},
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			username, err := proxyUserV.GetData()
+			if err != nil {
+				return err
+			}
+			password, err := proxyPasswordV.GetData()
+			if err != nil {
+				return err
+			}
+			if username == "" && password != "" {
+				return c.setContentByName(validatorPanel, "Proxy username is required with a password")
+			}
+			httpProxy, httpsProxy, noProxy := getProxies(&cfg.Config)
+			setProxies(&cfg.Config, setProxyUser(httpProxy, username, password), setProxyUser(httpsProxy, username, password), noProxy)
+			return showNextStep(c, proxyUserPanel)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return showPrevStep(c, proxyUserPanel)
+		},
+	}
+	proxyPasswordV.SetLocation(maxX/4, maxY/4+3, maxX/4*3, maxY/4+5)
+	c.AddElement(proxyPasswordPanel, proxyPasswordV)
+	return nil
+}
+
+func addNoProxyPanel(c *Console) error {
+	noProxyV, err := widgets.NewInput(c.Gui, noProxyPanel, "No proxy", false)
+	if err != nil {
+		return err
+	}
+	// proxies failing the connectivity check are accepted when confirmed
+	var warned string
+	noProxyV.PreShow = func() error {
+		c.Gui.Cursor = true
+		_, _, noProxy := getProxies(&cfg.Config)
+		if noProxy == "" {
+			noProxy = getNoProxy(&cfg.Config)
+		}
+		noProxyV.Value = noProxy
+		if err := c.setContentByName(titlePanel, "Configure addresses which bypass the proxy"); err != nil {
+			return err
+		}
+		return c.setContentByName(notePanel, noProxyNote)
+	}
+	noProxyV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			noProxy, err := noProxyV.GetData()
+			if err != nil {
+				return err
+			}
+			noProxy = strings.TrimSpace(noProxy)
+			if err := cfg.ValidateNoProxy(noProxy); err != nil {
+				return c.setContentByName(validatorPanel, err.Error())
+			}
+			httpProxy, httpsProxy, _ := getProxies(&cfg.Config)
+			if checked := httpProxy + " " + httpsProxy; checked != warned {
+				return runCheck(c, func() error {
+					return checkProxies(httpProxy, httpsProxy)
+				}, func(err error) error {
+					if err != nil {
+						warned = checked
+						return c.setContentByName(validatorPanel, err.Error()+". Press Enter to use anyway")
+					}
+					setProxies(&cfg.Config, httpProxy, httpsProxy, noProxy)
+					return showNextStep(c, noProxyPanel)
+				})
+			}
+			setProxies(&cfg.Config, httpProxy, httpsProxy, noProxy)
+			return showNextStep(c, noProxyPanel)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return showPrevStep(c, noProxyPanel)
+		},
+	}
+	c.AddElement(noProxyPanel, noProxyV)
+	return nil
+}
+
+func addRegistryPanel(c *Console) error {
+	registryV, err := widgets.NewInput(c.Gui, registryPanel, "Mirror endpoint", false)
+	if err != nil {
+		return err
+	}
+	registryV.PreShow = func() error {
+		c.Gui.Cursor = true
+		registryV.Value = ""
+		if r := cfg.Config.K3OS.Registries; r != nil && len(r.Mirrors[mirroredRegistry].Endpoints) > 0 {
+			registryV.Value = r.Mirrors[mirroredRegistry].Endpoints[0]
+		}
+		if err := c.setContentByName(titlePanel, "Optional: configure registry mirror"); err != nil {
+			return err
+		}
+		return c.setContentByName(notePanel, registryNote)
+	}
+	registryV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			endpoint, err := registryV.GetData()
+			if err != nil {
+				return err
+			}
+			setRegistryMirror(&cfg.Config, mirroredRegistry, strings.TrimSpace(endpoint))
+			return showNextStep(c, registryPanel)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return showPrevStep(c, registryPanel)
+		},
+	}
+	c.AddElement(registryPanel, registryV)
+	return nil
+}
+
+func addCloudInitPanel(c *Console) error {
+	cloudInitV, err := widgets.NewInput(c.Gui, cloudInitPanel, "HTTP URL", false)
+	if err != nil {
+		return err
+	}
+	cloudInitV.PreShow = func() error {
+		c.Gui.Cursor = true
+		if cfg.Config.K3OS.Install != nil {
+			cloudInitV.Value = cfg.Config.K3OS.Install.ConfigURL
+		}
+		return c.setContentByName(titlePanel, "Optional: configure cloud-init")
+	}
+	cloudInitV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			configURL, err := cloudInitV.GetData()
+			if err != nil {
+				return err
+			}
+			if cfg.Config.K3OS.Install == nil {
+				cfg.Config.K3OS.Install = &config.Install{}
+			}
+			cfg.Config.K3OS.Install.ConfigURL = configURL
+			return showNextStep(c, cloudInitPanel)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return showPrevStep(c, cloudInitPanel)
+		},
+	}
+	c.AddElement(cloudInitPanel, cloudInitV)
+	return nil
+}
+
+func addConfirmPanel(c *Console) error {
+	maxX, maxY := c.Gui.Size()
+	actions := []widgets.Option{
+		{
+			Value: "yes",
+			Text:  "Yes",
+		}, {
+			Value: "no",
+			Text:  "No",
+		}, {
+			Value: "save",
+			Text:  "Save answers",
+		},
+	}
+	askOptionsFunc := func() ([]widgets.Option, error) {
+		return getReviewOptions(&cfg.Config, actions, maxX/8*7-maxX/8-1), nil
+	}
+	confirmV, err := widgets.NewSelect(c.Gui, confirmPanel, "", askOptionsFunc)
+	if err != nil {
+		return err
+	}
+	confirmV.SetLocation(maxX/8, maxY/4, maxX/8*7, maxY/4*3)
+	confirmV.PreShow = func() error {
+		c.Gui.Cursor = false
+		var options string
+		if cfg.Config.InstallMode == modeJoin && c.serverFingerprint != "" {
+			options += fmt.Sprintf("management CA: %v\n", c.serverFingerprint)
+		}
+		if cfg.Config.InstallMode == modeCreate {
+			for _, addon := range cfg.Config.Addons {
+				options += fmt.Sprintf("addon: %v\n", describeAddon(addon))
+			}
+		}
+		logrus.Debug("cfm cfg: ", fmt.Sprintf("%+v", cfg.Config.K3OS.Install))
+		if cfg.Config.K3OS.Install != nil && !cfg.Config.K3OS.Install.Silent {
+			confirmV.Content = options +
+				"Your disk will be formatted and Harvester will be installed with the \nconfiguration below, select a setting to change it. Continue?\n"
+		}
+		return c.setContentByName(titlePanel, "Confirm installation options")
+	}
 	confirmV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
 		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
 			confirmed, err := confirmV.GetData()
 			if err != nil {
 				return err
 			}
-			if confirmed == "no" {
+			// the cursor is put back on the option when review is shown again
+			confirmV.Selected = confirmed
+			switch confirmed {
+			case "save":
+				confirmV.Close()
+				return showNext(c, saveAnswersPanel)
+			case "no":
 				confirmV.Close()
 				c.setContentByName(titlePanel, "")
 				go util.SleepAndReboot()
 				return c.setContentByName(notePanel, "Installation halted. Rebooting system in 5 seconds")
+			case "yes":
+				// answers are left incomplete when a step brought in by an
+				// edit is left by going back
+				if err := cfg.Config.Validate(); err != nil {
+					if errs, ok := err.(cfg.ValidationErrors); ok {
+						return c.setContentByName(validatorPanel, errs[0].Message)
+					}
+					return c.setContentByName(validatorPanel, err.Error())
+				}
+				if !c.remote.claimLocal() {
+					return c.setContentByName(validatorPanel, "A remote session is in control of the installation")
+				}
+				confirmV.Close()
+				if err := c.setContentByName(notePanel, ""); err != nil {
+					return err
+				}
+				return showNext(c, installProgressPanel, installPanel)
 			}
-			confirmV.Close()
-			customizeConfig()
-			return showNext(c, installPanel)
+			// the other options are the settings, which are changed in their step
+			return showEditStep(c, confirmPanel, confirmed)
 		},
 		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
-			confirmV.Close()
-			return showNext(c, cloudInitPanel)
+			return showPrevStep(c, confirmPanel)
 		},
 	}
 	c.AddElement(confirmPanel, confirmV)
+
+	saveAnswersV, err := widgets.NewInput(c.Gui, saveAnswersPanel, "Save to", false)
+	if err != nil {
+		return err
+	}
+	saveAnswersV.PreShow = func() error {
+		c.Gui.Cursor = true
+		if saveAnswersV.Value == "" {
+			saveAnswersV.Value = getDefaultAnswersPath()
+		}
+		if err := c.setContentByName(titlePanel, "Save answers as an install config"); err != nil {
+			return err
+		}
+		return c.setContentByName(notePanel, saveAnswersNote)
+	}
+	closeSaveAnswers := func(note string) error {
+		saveAnswersV.Close()
+		if err := showNext(c, confirmPanel); err != nil {
+			return err
+		}
+		return c.setContentByName(notePanel, note)
+	}
+	saveAnswersV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			path, err := saveAnswersV.GetData()
+			if err != nil {
+				return err
+			}
+			if path == "" {
+				return c.setContentByName(validatorPanel, "Path is required")
+			}
+			saveAnswersV.Value = path
+			data, err := getAnswers(&cfg.Config)
+			if err != nil {
+				return err
+			}
+			saved, err := saveAnswers(data, path)
+			if err != nil {
+				logrus.Errorf("failed to save answers: %v", err)
+				return c.setContentByName(validatorPanel, fmt.Sprintf("Failed to save answers: %v", err))
+			}
+			return closeSaveAnswers("Answers saved to " + saved)
+		},
+		gocui.KeyEsc: func(g *gocui.Gui, v *gocui.View) error {
+			return closeSaveAnswers("")
+		},
+	}
+	c.AddElement(saveAnswersPanel, saveAnswersV)
 	return nil
 }
 
 func addInstallPanel(c *Console) error {
 	maxX, maxY := c.Gui.Size()
+	progressV := widgets.NewProgressBar(c.Gui, installProgressPanel)
+	progressV.Title = " Installing Harvester "
+	progressV.Frame = true
+	progressV.SetLocation(maxX/8, maxY/8, maxX/8*7, maxY/8+3)
+	c.AddElement(installProgressPanel, progressV)
+
+	log := &installLog{}
+	failedV, err := widgets.NewSelect(c.Gui, installFailedPanel, "", func() ([]widgets.Option, error) {
+		return []widgets.Option{
+			{Value: installRetry, Text: "Retry"},
+			{Value: installEdit, Text: "Edit configuration"},
+			{Value: installShell, Text: "Drop to shell"},
+			{Value: installSaveLog, Text: "Save log to " + installLogFile},
+		}, nil
+	})
+	if err != nil {
+		return err
+	}
+	failedV.SetLocation(maxX/8, maxY/8+4, maxX/8*7, maxY/8*7)
+	failedV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			selected, err := failedV.GetData()
+			if err != nil {
+				return err
+			}
+			switch selected {
+			case installRetry:
+				failedV.Close()
+				c.setContentByName(notePanel, "")
+				c.setContentByName(titlePanel, "")
+				return showNext(c, installPanel)
+			case installEdit:
+				failedV.Close()
+				progressV.Close()
+				c.setContentByName(notePanel, "")
+				return showFirstStep(c)
+			case installShell:
+				return gocui.ErrQuit
+			case installSaveLog:
+				if err := log.save(installLogFile); err != nil {
+					return c.setContentByName(notePanel, fmt.Sprintf("Failed to save log: %v", err))
+				}
+				return c.setContentByName(notePanel, "Log saved to "+installLogFile)
+			}
+			return nil
+		},
+	}
+	c.AddElement(installFailedPanel, failedV)
+
+	powerOff := func() bool {
+		return cfg.Config.K3OS.Install != nil && cfg.Config.K3OS.Install.PowerOff
+	}
+	doneV, err := widgets.NewSelect(c.Gui, installDonePanel, "", func() ([]widgets.Option, error) {
+		if powerOff() {
+			return []widgets.Option{{Value: "poweroff", Text: "Power off now"}}, nil
+		}
+		return []widgets.Option{{Value: "reboot", Text: "Reboot now"}}, nil
+	})
+	if err != nil {
+		return err
+	}
+	doneV.SetLocation(maxX/8, maxY/8+4, maxX/8*7, maxY/8*7)
+	doneV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			go rebootAfterInstall(powerOff())
+			return nil
+		},
+	}
+	c.AddElement(installDonePanel, doneV)
+
 	installV := widgets.NewPanel(c.Gui, installPanel)
 	installV.PreShow = func() error {
-		go doInstall(c.Gui)
-		return c.setContentByName(footerPanel, "")
+		var (
+			lock     sync.Mutex
+			progress = installProgress{Stage: -1}
+			start    = time.Now()
+			done     = make(chan struct{})
+		)
+		render := func() {
+			lock.Lock()
+			defer lock.Unlock()
+			progressV.SetProgress(progress.Description(), progress.Percent, "Elapsed "+formatElapsed(time.Since(start)))
+		}
+		render()
+		log.reset()
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					render()
+				}
+			}
+		}()
+		go func() {
+			defer close(done)
+			printer := func(message string) {
+				log.add(message)
+				printToInstallPanel(c.Gui, message)
+			}
+			// install a customized copy so that the entered config is kept for a retry
+			installConfig, err := copyConfig(&cfg.Config)
+			if err == nil {
+				err = customizeConfig(installConfig)
+			}
+			if err == nil {
+				err = doInstall(installConfig, printer, func(p installProgress) {
+					lock.Lock()
+					progress = p
+					lock.Unlock()
+					render()
+				})
+			}
+			if err == nil {
+				summary := getInstallSummary(installConfig, getManagementAddress(installConfig))
+				printer(summary)
+				time.AfterFunc(rebootTimeout, func() {
+					rebootAfterInstall(powerOff())
+				})
+				c.Gui.Update(func(g *gocui.Gui) error {
+					return showInstallDone(c, doneV, summary)
+				})
+				return
+			}
+			logrus.Errorf("installation failed: %v", err)
+			log.add(err.Error())
+			lock.Lock()
+			failed := progress
+			lock.Unlock()
+			c.Gui.Update(func(g *gocui.Gui) error {
+				return showInstallFailure(c, failedV, failed, err, log.tail(installLogLines))
+			})
+		}()
+		return c.setContentByName(footerPanel, "<Use Up/Down and PgUp/PgDn to scroll the log>")
+	}
+	installV.Title = " Log "
+	installV.SetLocation(maxX/8, maxY/8+4, maxX/8*7, maxY/8*7)
+	installV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyArrowUp: func(g *gocui.Gui, v *gocui.View) error {
+			return scrollView(v, -1)
+		},
+		gocui.KeyArrowDown: func(g *gocui.Gui, v *gocui.View) error {
+			return scrollView(v, 1)
+		},
+		gocui.KeyPgup: func(g *gocui.Gui, v *gocui.View) error {
+			_, sy := v.Size()
+			return scrollView(v, -sy)
+		},
+		gocui.KeyPgdn: func(g *gocui.Gui, v *gocui.View) error {
+			_, sy := v.Size()
+			return scrollView(v, sy)
+		},
 	}
-	installV.Title = " Installing Harvester "
-	installV.SetLocation(maxX/8, maxY/8, maxX/8*7, maxY/8*7)
 	c.AddElement(installPanel, installV)
 	installV.Frame = true
 	return nil
 }
+
+func addRemotePanels(c *Console) error {
+	maxX, maxY := c.Gui.Size()
+	remoteInfoV := widgets.NewPanel(c.Gui, remoteInfoPanel)
+	remoteInfoV.SetLocation(0, 0, maxX, 3)
+	remoteInfoV.Focus = false
+	c.AddElement(remoteInfoPanel, remoteInfoV)
+
+	remoteV := widgets.NewPanel(c.Gui, remotePanel)
+	remoteV.Title = " Remote session "
+	remoteV.Frame = true
+	remoteV.Wrap = true
+	remoteV.SetLocation(maxX/8, maxY/4, maxX/8*7, maxY/8*7)
+	remoteV.KeyBindings = map[gocui.Key]func(*gocui.Gui, *gocui.View) error{
+		gocui.KeyEnter: func(g *gocui.Gui, v *gocui.View) error {
+			return takeOverRemoteSession(c)
+		},
+	}
+	c.AddElement(remotePanel, remoteV)
+	return nil
+}
+
+// showRemoteSession covers the wizard with the status of the installation once
+// the remote session is in control, the wizard is used again only when taken
+// over, see takeOverRemoteSession
+func showRemoteSession(c *Console) error {
+	status, lines := c.remote.getSession(installLogLines)
+	if status.Status == remoteIdle {
+		return nil
+	}
+	if _, err := c.Gui.View(remotePanel); err == gocui.ErrUnknownView {
+		c.Gui.Cursor = false
+		for _, name := range []string{validatorPanel, notePanel, footerPanel} {
+			if err := c.setContentByName(name, ""); err != nil {
+				return err
+			}
+		}
+		if err := c.setContentByName(titlePanel, "Remote installation"); err != nil {
+			return err
+		}
+	}
+	// the token is renewed when the installation fails
+	if err := c.setContentByName(remoteInfoPanel, c.remote.getInfo()); err != nil {
+		return err
+	}
+	return c.setContentByName(remotePanel, getRemoteContent(status, lines))
+}
+
+// takeOverRemoteSession starts the wizard over on the console unless the
+// remote session started the installation, the answers are kept
+func takeOverRemoteSession(c *Console) error {
+	if !c.remote.claimLocal() {
+		return nil
+	}
+	for name, e := range c.elements {
+		switch name {
+		case titlePanel, validatorPanel, notePanel, footerPanel, remoteInfoPanel:
+			continue
+		}
+		if _, err := c.Gui.View(name); err == gocui.ErrUnknownView {
+			continue
+		}
+		if err := e.Close(); err != nil {
+			return err
+		}
+	}
+	if err := c.setContentByName(remoteInfoPanel, c.remote.getInfo()); err != nil {
+		return err
+	}
+	if err := c.setContentByName(footerPanel, "<Use ESC to go back to previous section>"); err != nil {
+		return err
+	}
+	return showFirstStep(c)
+}
+
+// showInstallFailure replaces the log with the failing stage, the error and
+// the last log lines followed by the options to recover
+func showInstallFailure(c *Console, failedV *widgets.Select, progress installProgress, installErr error, lines []string) error {
+	installV, err := c.GetElement(installPanel)
+	if err != nil {
+		return err
+	}
+	if err := installV.Close(); err != nil {
+		return err
+	}
+	failedV.Content = getInstallFailureContent(progress, installErr, lines)
+	if err := c.setContentByName(titlePanel, "Installation failed"); err != nil {
+		return err
+	}
+	if err := c.setContentByName(footerPanel, ""); err != nil {
+		return err
+	}
+	return failedV.Show()
+}
+
+// showInstallDone replaces the log with the summary of the installation
+func showInstallDone(c *Console, doneV *widgets.Select, summary string) error {
+	installV, err := c.GetElement(installPanel)
+	if err != nil {
+		return err
+	}
+	if err := installV.Close(); err != nil {
+		return err
+	}
+	doneV.Content = summary + "\n\n"
+	if err := c.setContentByName(titlePanel, "Harvester is installed"); err != nil {
+		return err
+	}
+	if err := c.setContentByName(footerPanel, ""); err != nil {
+		return err
+	}
+	if err := doneV.Show(); err != nil {
+		return err
+	}
+	return c.setContentByName(notePanel, fmt.Sprintf("The system restarts automatically in %d seconds", int(rebootTimeout.Seconds())))
+}
+
+func getInstallFailureContent(progress installProgress, err error, lines []string) string {
+	content := fmt.Sprintf("Installation failed at %s\n%v\n", progress.Description(), err)
+	if len(lines) > 0 {
+		content += "\nLast log lines:\n" + strings.Join(lines, "\n") + "\n"
+	}
+	return content
+}
+
+// scrollView scrolls the content of v by delta lines within the buffer
+func scrollView(v *gocui.View, delta int) error {
+	_, sy := v.Size()
+	ox, oy := v.Origin()
+	oy += delta
+	if max := len(v.BufferLines()) - sy; oy > max {
+		oy = max
+	}
+	if oy < 0 {
+		oy = 0
+	}
+	return v.SetOrigin(ox, oy)
+}
+
+// formatElapsed formats a duration as mm:ss
+func formatElapsed(d time.Duration) string {
+	seconds := int(d.Seconds())
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
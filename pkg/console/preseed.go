@@ -0,0 +1,88 @@
+package console
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/imdario/mergo"
+	"github.com/pkg/errors"
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/rancher/k3os/pkg/config"
+)
+
+var (
+	// preseededPanels are the wizard panels answered by the pre-seeded config
+	preseededPanels = map[string]bool{}
+)
+
+// loadPreseededConfig loads partial answers from the ISO's k3os/system/config.yaml
+// and from configURL. Values from configURL take precedence.
+func loadPreseededConfig(configURL string) error {
+	preseed := &cfg.InstallConfig{}
+	data, err := ioutil.ReadFile(config.SystemConfig)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	} else if err == nil {
+		if preseed, err = cfg.ToInstallConfig(data); err != nil {
+			return errors.Wrapf(err, "failed to parse %s", config.SystemConfig)
+		}
+	}
+
+	if configURL != "" {
+		remoteConfig, err := getRemoteInstallConfig(configURL)
+		if err != nil {
+			return errors.Wrapf(err, "failed to fetch install config from %s", configURL)
+		}
+		if err := mergo.Merge(preseed, remoteConfig, mergo.WithOverride); err != nil {
+			return err
+		}
+	}
+
+	// the token of saved answers is asked unless it is filled in
+	if preseed.K3OS.Token == cfg.TokenPlaceholder {
+		preseed.K3OS.Token = ""
+	}
+	if preseed.K3OS.ServerURL != "" {
+		preseed.K3OS.ServerURL = getFormattedServerURL(preseed.K3OS.ServerURL)
+	}
+	if err := encryptPassword(preseed); err != nil {
+		return err
+	}
+	preseededPanels = getPreseededPanels(preseed)
+	cfg.Config = *preseed
+	return nil
+}
+
+func getPreseededPanels(c *cfg.InstallConfig) map[string]bool {
+	_, _, noProxy := getProxies(c)
+	return map[string]bool{
+		askCreatePanel:     c.InstallMode == modeCreate || c.InstallMode == modeJoin,
+		diskPanel:          c.K3OS.Install != nil && c.K3OS.Install.Device != "",
+		serverURLPanel:     c.K3OS.ServerURL != "",
+		tokenPanel:         c.K3OS.Token != "",
+		passwordPanel:      c.K3OS.Password != "",
+		sshKeyPanel:        c.SSHKeyURL != "",
+		networkPanel:       c.Network.Interface != "" || hasFlannelIface(c.ExtraK3sArgs),
+		networkMethodPanel: c.Network.Method == networkMethodDHCP || c.Network.Method == networkMethodStatic,
+		addressPanel:       c.Network.IP != "",
+		dnsServersPanel:    len(c.K3OS.DNSNameservers) > 0,
+		ntpServersPanel:    len(c.K3OS.NTPServers) > 0,
+		hostnamePanel:      c.Hostname != "",
+		preflightPanel:     c.SkipChecks,
+		dataDisksPanel:     len(c.DataDisks) > 0,
+		proxyPanel:         hasProxy(c),
+		proxyUserPanel:     hasProxy(c),
+		noProxyPanel:       noProxy != "",
+		registryPanel:      c.K3OS.Registries != nil && len(c.K3OS.Registries.Mirrors) > 0,
+		cloudInitPanel:     c.K3OS.Install != nil && c.K3OS.Install.ConfigURL != "",
+	}
+}
+
+func hasFlannelIface(args []string) bool {
+	for _, arg := range args {
+		if arg == "--flannel-iface" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,140 @@
+package console
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// prefixes of the progress events printed by the install script
+	stageEventPrefix    = "::stage "
+	progressEventPrefix = "::progress "
+)
+
+// installStage is a step of the installation. Weight is the share of the
+// stage in the overall progress.
+type installStage struct {
+	Name        string
+	Description string
+	Weight      int
+}
+
+var installStages = []installStage{
+	{Name: "partition", Description: "Partitioning disk", Weight: 5},
+	{Name: "format", Description: "Formatting partitions", Weight: 5},
+	{Name: "copy", Description: "Copying files", Weight: 20},
+	{Name: "preload", Description: "Loading container images", Weight: 55},
+	{Name: "grub", Description: "Installing bootloader", Weight: 5},
+	{Name: "finalize", Description: "Finalizing installation", Weight: 10},
+}
+
+// installProgress is the progress of the installation, Stage is the index of
+// the current stage in installStages or -1 before the first stage
+type installProgress struct {
+	Stage        int
+	StagePercent int
+	Percent      int
+}
+
+// Description returns the current stage in the form of "Stage 1/6: Partitioning disk"
+func (p installProgress) Description() string {
+	if p.Stage < 0 {
+		return "Preparing installation"
+	}
+	return fmt.Sprintf("Stage %d/%d: %s", p.Stage+1, len(installStages), installStages[p.Stage].Description)
+}
+
+// progressTracker follows the progress events in the output of the install script
+type progressTracker struct {
+	progress installProgress
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{
+		progress: installProgress{Stage: -1},
+	}
+}
+
+// parse returns the updated progress and true if line is a progress event
+func (t *progressTracker) parse(line string) (installProgress, bool) {
+	switch {
+	case strings.HasPrefix(line, stageEventPrefix):
+		name := strings.TrimSpace(strings.TrimPrefix(line, stageEventPrefix))
+		for i, stage := range installStages {
+			if stage.Name == name {
+				t.progress.Stage = i
+				t.progress.StagePercent = 0
+			}
+		}
+	case strings.HasPrefix(line, progressEventPrefix):
+		percent, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, progressEventPrefix)))
+		if err != nil {
+			return t.progress, false
+		}
+		if percent < 0 {
+			percent = 0
+		} else if percent > 100 {
+			percent = 100
+		}
+		t.progress.StagePercent = percent
+	default:
+		return t.progress, false
+	}
+	t.progress.Percent = getOverallPercent(t.progress.Stage, t.progress.StagePercent)
+	return t.progress, true
+}
+
+func getOverallPercent(stage, stagePercent int) int {
+	var total, done int
+	for i, s := range installStages {
+		total += s.Weight
+		if i < stage {
+			done += s.Weight
+		} else if i == stage {
+			done += s.Weight * stagePercent / 100
+		}
+	}
+	return done * 100 / total
+}
+
+// installLog keeps the output of the install script to show its tail when the
+// installation fails and to save it to a file
+type installLog struct {
+	lock  sync.Mutex
+	lines []string
+}
+
+func (l *installLog) add(line string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines = append(l.lines, line)
+}
+
+func (l *installLog) reset() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.lines = nil
+}
+
+// tail returns the last n lines
+func (l *installLog) tail(n int) []string {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if len(l.lines) <= n {
+		return append([]string(nil), l.lines...)
+	}
+	return append([]string(nil), l.lines[len(l.lines)-n:]...)
+}
+
+func (l *installLog) save(path string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	var content string
+	for _, line := range l.lines {
+		content += line + "\n"
+	}
+	return ioutil.WriteFile(path, []byte(content), 0600)
+}
@@ -0,0 +1,225 @@
+package console
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/rancher/harvester-installer/pkg/util"
+	"github.com/sirupsen/logrus"
+)
+
+// getProxies returns the HTTP and HTTPS proxies of the config and the addresses
+// which are not reached through them
+func getProxies(c *cfg.InstallConfig) (httpProxy, httpsProxy, noProxy string) {
+	get := func(key string) string {
+		if value := c.K3OS.Environment[key]; value != "" {
+			return value
+		}
+		return c.K3OS.Environment[strings.ToUpper(key)]
+	}
+	return get("http_proxy"), get("https_proxy"), get("no_proxy")
+}
+
+// setProxies sets both the lower and upper case proxy variables of the
+// environment, as programs read either of them
+func setProxies(c *cfg.InstallConfig, httpProxy, httpsProxy, noProxy string) {
+	if c.K3OS.Environment == nil {
+		c.K3OS.Environment = make(map[string]string)
+	}
+	set := func(key, value string) {
+		for _, k := range []string{key, strings.ToUpper(key)} {
+			if value == "" {
+				delete(c.K3OS.Environment, k)
+			} else {
+				c.K3OS.Environment[k] = value
+			}
+		}
+	}
+	set("http_proxy", httpProxy)
+	set("https_proxy", httpsProxy)
+	set("no_proxy", noProxy)
+}
+
+func hasProxy(c *cfg.InstallConfig) bool {
+	httpProxy, httpsProxy, _ := getProxies(c)
+	return httpProxy != "" || httpsProxy != ""
+}
+
+// completeProxies fills in the addresses which are not reached through the
+// proxies if they are not configured
+func completeProxies(c *cfg.InstallConfig) {
+	httpProxy, httpsProxy, noProxy := getProxies(c)
+	if httpProxy == "" && httpsProxy == "" {
+		return
+	}
+	if noProxy == "" {
+		noProxy = getNoProxy(c)
+	}
+	setProxies(c, httpProxy, httpsProxy, noProxy)
+}
+
+// setProxyAddresses sets the proxies entered apart from their credentials, the
+// HTTPS proxy defaults to the HTTP proxy. The credentials entered before are
+// kept unless they are given in the addresses.
+func setProxyAddresses(c *cfg.InstallConfig, httpProxy, httpsProxy string) error {
+	httpProxy, httpsProxy = strings.TrimSpace(httpProxy), strings.TrimSpace(httpsProxy)
+	if httpsProxy == "" {
+		httpsProxy = httpProxy
+	}
+	for _, proxy := range []string{httpProxy, httpsProxy} {
+		if proxy == "" {
+			continue
+		}
+		if err := cfg.ValidateProxyURL(proxy); err != nil {
+			return err
+		}
+	}
+	oldHTTPProxy, oldHTTPSProxy, noProxy := getProxies(c)
+	if oldHTTPProxy == "" {
+		oldHTTPProxy = oldHTTPSProxy
+	}
+	oldUsername, oldPassword := getProxyUser(oldHTTPProxy)
+	if username, _ := getProxyUser(httpProxy); username == "" {
+		httpProxy = setProxyUser(httpProxy, oldUsername, oldPassword)
+	}
+	if username, _ := getProxyUser(httpsProxy); username == "" {
+		httpsProxy = setProxyUser(httpsProxy, oldUsername, oldPassword)
+	}
+	if httpProxy == "" && httpsProxy == "" {
+		noProxy = ""
+	}
+	setProxies(c, httpProxy, httpsProxy, noProxy)
+	return nil
+}
+
+// getProxyUser returns the credentials of a proxy URL
+func getProxyUser(proxy string) (username, password string) {
+	u, err := url.Parse(proxy)
+	if err != nil || u.User == nil {
+		return "", ""
+	}
+	password, _ = u.User.Password()
+	return u.User.Username(), password
+}
+
+// setProxyUser returns the proxy URL with the credentials, which are removed
+// when username is empty
+func setProxyUser(proxy, username, password string) string {
+	u, err := url.Parse(proxy)
+	if err != nil || proxy == "" {
+		return proxy
+	}
+	switch {
+	case username == "":
+		u.User = nil
+	case password == "":
+		u.User = url.User(username)
+	default:
+		u.User = url.UserPassword(username, password)
+	}
+	return u.String()
+}
+
+// getNoProxy returns the addresses which should not be reached through the
+// proxies: the local host, the cluster and service networks of k3s, the node
+// network and the management server to join
+func getNoProxy(c *cfg.InstallConfig) string {
+	clusterCIDR := getK3sArg(c.ExtraK3sArgs, "--cluster-cidr")
+	if clusterCIDR == "" {
+		clusterCIDR = defaultClusterCIDR
+	}
+	serviceCIDR := getK3sArg(c.ExtraK3sArgs, "--service-cidr")
+	if serviceCIDR == "" {
+		serviceCIDR = defaultServiceCIDR
+	}
+	entries := []string{"localhost", "127.0.0.1", "0.0.0.0", clusterCIDR, serviceCIDR, ".svc", ".cluster.local"}
+	entries = append(entries, getNodeNetworks(c)...)
+	if c.InstallMode == modeJoin && c.K3OS.ServerURL != "" {
+		if u, err := url.Parse(getFormattedServerURL(c.K3OS.ServerURL)); err == nil && u.Hostname() != "" {
+			entries = append(entries, u.Hostname())
+		}
+	}
+
+	seen := map[string]bool{}
+	var result []string
+	for _, entry := range entries {
+		if !seen[entry] {
+			seen[entry] = true
+			result = append(result, entry)
+		}
+	}
+	return strings.Join(result, ",")
+}
+
+// getNodeNetworks returns the IPv4 networks of the management interface in CIDR
+// notation
+func getNodeNetworks(c *cfg.InstallConfig) []string {
+	if c.Network.Method == networkMethodStatic {
+		if _, ipNet, err := net.ParseCIDR(c.Network.IP); err == nil {
+			return []string{ipNet.String()}
+		}
+		return nil
+	}
+	if c.Network.Interface == "" {
+		return nil
+	}
+	iface, err := net.InterfaceByName(c.Network.Interface)
+	if err != nil {
+		logrus.Warnf("failed to get interface %q: %v", c.Network.Interface, err)
+		return nil
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		logrus.Warnf("failed to get addresses of %q: %v", c.Network.Interface, err)
+		return nil
+	}
+	var networks []string
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+			networks = append(networks, (&net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask}).String())
+		}
+	}
+	return networks
+}
+
+// getK3sArg returns the value of a k3s flag given as "--flag=value" or
+// "--flag value"
+func getK3sArg(args []string, name string) string {
+	for i, arg := range args {
+		if strings.HasPrefix(arg, name+"=") {
+			return strings.TrimPrefix(arg, name+"=")
+		}
+		if arg == name && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// checkProxies requests the proxy check host through the proxies and returns an
+// error listing the proxies which fail
+func checkProxies(httpProxy, httpsProxy string) error {
+	var failed []string
+	for _, check := range []struct {
+		proxy  string
+		target string
+	}{
+		{proxy: httpProxy, target: "http://" + proxyCheckHost + "/"},
+		{proxy: httpsProxy, target: "https://" + proxyCheckHost + "/"},
+	} {
+		if check.proxy == "" {
+			continue
+		}
+		if err := util.CheckProxy(check.proxy, check.target); err != nil {
+			logrus.Warnf("failed to reach %s through %s: %v", check.target, cfg.MaskPassword(check.proxy), err)
+			failed = append(failed, check.target)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Unable to reach %s through the proxy", strings.Join(failed, ", "))
+	}
+	return nil
+}
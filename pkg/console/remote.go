@@ -0,0 +1,707 @@
+package console
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jroimartin/gocui"
+	"github.com/rancher/harvester-installer/pkg/cluster"
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/rancher/harvester-installer/pkg/disk"
+	"github.com/rancher/harvester-installer/pkg/preflight"
+	"github.com/sirupsen/logrus"
+)
+
+// statuses of the installation driven by the remote API
+const (
+	remoteIdle       = "idle"
+	remoteConfigured = "configured"
+	remoteInstalling = "installing"
+	remoteSucceeded  = "succeeded"
+	remoteFailed     = "failed"
+)
+
+// types of the events streamed to the remote clients
+const (
+	remoteStatusEvent   = "status"
+	remoteProgressEvent = "progress"
+	remoteLogEvent      = "log"
+)
+
+// remoteProgress is the progress of the installation as reported to the
+// remote clients
+type remoteProgress struct {
+	Stage       int    `json:"stage"`
+	Description string `json:"description"`
+	Percent     int    `json:"percent"`
+}
+
+// remoteStatus is the state of the installation driven by the remote API
+type remoteStatus struct {
+	Status   string         `json:"status"`
+	Progress remoteProgress `json:"progress"`
+	// Error is the reason of a failed installation
+	Error string `json:"error,omitempty"`
+}
+
+// remoteEvent is a change of the installation, streamed to the remote clients
+// as a line of JSON
+type remoteEvent struct {
+	Type     string          `json:"type"`
+	Status   string          `json:"status,omitempty"`
+	Progress *remoteProgress `json:"progress,omitempty"`
+	Message  string          `json:"message,omitempty"`
+}
+
+// remoteError is the body of the responses to failed requests, Details are
+// the messages of the invalid fields of a config
+type remoteError struct {
+	Error   string   `json:"error"`
+	Details []string `json:"details,omitempty"`
+}
+
+// remoteInventory is the hardware of the host, which the remote clients write
+// the install config for
+type remoteInventory struct {
+	Disks      []remoteDisk      `json:"disks"`
+	Interfaces []remoteInterface `json:"interfaces"`
+	// Checks are the hardware checks without the disk size, which is
+	// checked for the disk of the config when installing
+	Checks []remoteCheck `json:"checks"`
+}
+
+type remoteDisk struct {
+	Name           string             `json:"name"`
+	Path           string             `json:"path"`
+	StablePath     string             `json:"stablePath,omitempty"`
+	Vendor         string             `json:"vendor,omitempty"`
+	Model          string             `json:"model,omitempty"`
+	Serial         string             `json:"serial,omitempty"`
+	Size           uint64             `json:"size"`
+	Rotational     bool               `json:"rotational"`
+	Removable      bool               `json:"removable"`
+	PartitionTable string             `json:"partitionTable,omitempty"`
+	FileSystems    []remoteFileSystem `json:"fileSystems,omitempty"`
+	// Installer is the media the installer runs from
+	Installer bool `json:"installer"`
+}
+
+type remoteFileSystem struct {
+	Device string `json:"device"`
+	Type   string `json:"type"`
+	Label  string `json:"label,omitempty"`
+}
+
+type remoteInterface struct {
+	Name      string   `json:"name"`
+	MAC       string   `json:"mac,omitempty"`
+	Up        bool     `json:"up"`
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+type remoteCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// remoteConnKey is the key of the connection of a request in its context
+type remoteConnKey struct{}
+
+// remoteAPI lets a remote client read the inventory of the host, submit an
+// install config, start the installation and follow its progress. Requests are
+// authorized by a token shown on the console, which expires once the
+// installation starts: its progress can still be followed with it, a failed
+// installation is retried with a new token. The API is enabled by
+// harvester.install.remote_api on the kernel cmdline.
+type remoteAPI struct {
+	mux *http.ServeMux
+	// host, port and fingerprint tell where the API is served, the host is
+	// unknown without network
+	host        string
+	port        int
+	fingerprint string
+	// the inventory is read by scanner and checker, which are configurable
+	// for testing like install
+	scanner *disk.Scanner
+	checker *preflight.Checker
+	// install installs with a copy of the submitted config
+	install func(c *cfg.InstallConfig, printer func(string), reporter func(installProgress)) error
+	// onChange is called when the status changes or an event is added
+	onChange func()
+
+	lock   sync.Mutex
+	token  string
+	status string
+	// local tells the installation is driven on the console
+	local    bool
+	config   *cfg.InstallConfig
+	progress installProgress
+	err      string
+	events   []remoteEvent
+	// updated is closed when an event is added
+	updated chan struct{}
+}
+
+func newRemoteAPI(token string) *remoteAPI {
+	a := &remoteAPI{
+		token:    token,
+		mux:      http.NewServeMux(),
+		scanner:  disk.NewScanner(),
+		checker:  preflight.NewChecker(),
+		install:  installRemotely,
+		onChange: func() {},
+		status:   remoteIdle,
+		progress: installProgress{Stage: -1},
+		updated:  make(chan struct{}),
+	}
+	a.mux.HandleFunc("/v1/inventory", a.getInventory)
+	a.mux.HandleFunc("/v1/config", a.putConfig)
+	a.mux.HandleFunc("/v1/install", a.startInstall)
+	a.mux.HandleFunc("/v1/status", a.getStatus)
+	a.mux.HandleFunc("/v1/events", a.streamEvents)
+	return a
+}
+
+// serveRemoteAPI serves the remote API over TLS on address in the background,
+// the console shows where to reach it, the fingerprint of its self-signed
+// certificate and the token
+func (c *Console) serveRemoteAPI(address string) error {
+	token, err := generateRemoteToken()
+	if err != nil {
+		return err
+	}
+	host, _, _ := net.SplitHostPort(address)
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = getManagementAddress(&cfg.Config)
+	}
+	cert, fingerprint, err := generateRemoteCertificate(host)
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	c.remote = newRemoteAPI(token)
+	c.remote.host = host
+	c.remote.port = listener.Addr().(*net.TCPAddr).Port
+	c.remote.fingerprint = fingerprint
+	c.remote.onChange = func() {
+		c.Gui.Update(func(g *gocui.Gui) error {
+			return showRemoteSession(c)
+		})
+	}
+	server := &http.Server{
+		Handler:      c.remote,
+		TLSConfig:    &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+		ReadTimeout:  remoteReadTimeout,
+		WriteTimeout: remoteWriteTimeout,
+		IdleTimeout:  remoteIdleTimeout,
+		// the events are streamed longer than the write timeout, see
+		// streamEvents
+		ConnContext: func(ctx context.Context, conn net.Conn) context.Context {
+			return context.WithValue(ctx, remoteConnKey{}, conn)
+		},
+	}
+	logrus.Infof("serving the remote API on %s, certificate %s", listener.Addr(), fingerprint)
+	go func() {
+		if err := server.ServeTLS(listener, "", ""); err != nil {
+			logrus.Errorf("failed to serve the remote API: %v", err)
+		}
+	}()
+	return nil
+}
+
+// getInfo tells where the remote API is reached and its current token
+func (a *remoteAPI) getInfo() string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return getRemoteInfo(a.host, a.port, a.token, a.fingerprint)
+}
+
+// getRemoteInfo tells where the remote API is reached, its token and the
+// fingerprint of its certificate, the host is unknown without network
+func getRemoteInfo(host string, port int, token, fingerprint string) string {
+	info := fmt.Sprintf("Remote installation API on port %d, token: %s", port, token)
+	if host != "" {
+		info = fmt.Sprintf("Remote installation API: https://%s, token: %s", net.JoinHostPort(host, strconv.Itoa(port)), token)
+	}
+	return info + "\nCertificate fingerprint: " + fingerprint
+}
+
+// generateRemoteCertificate returns the self-signed certificate of the remote
+// API for host and its fingerprint, which the clients check it by
+func generateRemoteCertificate(host string) (tls.Certificate, string, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "harvester-installer"},
+		NotBefore:             now.Add(-remoteCertificateValidity),
+		NotAfter:              now.Add(remoteCertificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	} else if host != "" {
+		template.DNSNames = []string{host}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	fingerprint, err := cluster.GetFingerprint(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	if err != nil {
+		return tls.Certificate{}, "", err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, fingerprint, nil
+}
+
+// generateRemoteToken returns a token of the remote API, which expires once
+// the installation starts
+func generateRemoteToken() (string, error) {
+	return generateRandomString(remoteTokenLength)
+}
+
+// ServeHTTP serves the requests bearing the token in a Bearer authorization,
+// once the installation is started only its progress is served
+func (a *remoteAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.lock.Lock()
+	token := a.token
+	started := a.status == remoteInstalling || a.status == remoteSucceeded
+	a.lock.Unlock()
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(token)) != 1 {
+		logrus.Warnf("unauthorized remote API request from %s", r.RemoteAddr)
+		writeRemoteError(w, http.StatusUnauthorized, "The token shown on the console is required")
+		return
+	}
+	if started && r.URL.Path != "/v1/status" && r.URL.Path != "/v1/events" {
+		logrus.Warnf("remote API request from %s with an expired token", r.RemoteAddr)
+		writeRemoteError(w, http.StatusUnauthorized, "The token expired when the installation started")
+		return
+	}
+	a.mux.ServeHTTP(w, r)
+}
+
+func (a *remoteAPI) getInventory(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	disks, err := a.scanner.Scan()
+	if err != nil {
+		writeRemoteError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to scan disks: %v", err))
+		return
+	}
+	interfaces, err := getRemoteInterfaces()
+	if err != nil {
+		writeRemoteError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to list network interfaces: %v", err))
+		return
+	}
+	inventory := remoteInventory{
+		Disks:      []remoteDisk{},
+		Interfaces: interfaces,
+		Checks:     []remoteCheck{},
+	}
+	for _, d := range disks {
+		rd := remoteDisk{
+			Name:           d.Name,
+			Path:           d.Path,
+			StablePath:     d.StablePath,
+			Vendor:         d.Vendor,
+			Model:          d.Model,
+			Serial:         d.Serial,
+			Size:           d.Size,
+			Rotational:     d.Rotational,
+			Removable:      d.Removable,
+			PartitionTable: d.PartitionTable,
+			Installer:      d.IsInstaller(),
+		}
+		for _, fs := range d.FileSystems {
+			rd.FileSystems = append(rd.FileSystems, remoteFileSystem{Device: fs.Device, Type: fs.Type, Label: fs.Label})
+		}
+		inventory.Disks = append(inventory.Disks, rd)
+	}
+	for _, result := range a.checker.Run("") {
+		inventory.Checks = append(inventory.Checks, remoteCheck{Name: result.Name, Status: result.Status, Message: result.Message})
+	}
+	writeRemoteJSON(w, http.StatusOK, inventory)
+}
+
+// putConfig takes the install config in the YAML or JSON form of the config
+// files, the remote session is in control of the installation from then on
+func (a *remoteAPI) putConfig(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPut) {
+		return
+	}
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, remoteConfigMaxSize))
+	if err != nil {
+		writeRemoteError(w, http.StatusBadRequest, fmt.Sprintf("Failed to read the config: %v", err))
+		return
+	}
+	c, err := cfg.ToInstallConfig(data)
+	if err != nil {
+		writeRemoteError(w, http.StatusBadRequest, fmt.Sprintf("Failed to parse the config: %v", err))
+		return
+	}
+	if err := c.Validate(); err != nil {
+		var details []string
+		if errs, ok := err.(cfg.ValidationErrors); ok {
+			for _, e := range errs {
+				details = append(details, e.Error())
+			}
+		}
+		writeRemoteError(w, http.StatusUnprocessableEntity, "Invalid install config", details...)
+		return
+	}
+
+	a.lock.Lock()
+	if message := a.getConflict(); message != "" {
+		a.lock.Unlock()
+		writeRemoteError(w, http.StatusConflict, message)
+		return
+	}
+	a.config = c
+	a.status = remoteConfigured
+	// the events of a failed installation are dropped with its config
+	a.progress = installProgress{Stage: -1}
+	a.err = ""
+	a.events = nil
+	status := a.getStatusLocked()
+	a.lock.Unlock()
+
+	logrus.Infof("install config submitted by the remote session at %s", r.RemoteAddr)
+	a.onChange()
+	writeRemoteJSON(w, http.StatusOK, status)
+}
+
+// startInstall installs with the submitted config in the background, a failed
+// installation is started again
+func (a *remoteAPI) startInstall(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	a.lock.Lock()
+	if message := a.getConflict(); message != "" {
+		a.lock.Unlock()
+		writeRemoteError(w, http.StatusConflict, message)
+		return
+	}
+	if a.config == nil {
+		a.lock.Unlock()
+		writeRemoteError(w, http.StatusConflict, "An install config is required")
+		return
+	}
+	// install a copy so that the submitted config is kept for a retry
+	c, err := copyConfig(a.config)
+	if err != nil {
+		a.lock.Unlock()
+		writeRemoteError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	a.status = remoteInstalling
+	a.progress = installProgress{Stage: -1}
+	a.err = ""
+	a.events = nil
+	a.addEventLocked(remoteEvent{Type: remoteStatusEvent, Status: remoteInstalling})
+	status := a.getStatusLocked()
+	a.lock.Unlock()
+
+	logrus.Infof("installation started by the remote session at %s", r.RemoteAddr)
+	a.onChange()
+	go a.runInstall(c)
+	writeRemoteJSON(w, http.StatusAccepted, status)
+}
+
+func (a *remoteAPI) runInstall(c *cfg.InstallConfig) {
+	printer := func(message string) {
+		logrus.Info(message)
+		a.lock.Lock()
+		a.addEventLocked(remoteEvent{Type: remoteLogEvent, Message: message})
+		a.lock.Unlock()
+		a.onChange()
+	}
+	reporter := func(progress installProgress) {
+		a.lock.Lock()
+		a.progress = progress
+		p := getRemoteProgress(progress)
+		a.addEventLocked(remoteEvent{Type: remoteProgressEvent, Progress: &p})
+		a.lock.Unlock()
+		a.onChange()
+	}
+	err := a.install(c, printer, reporter)
+
+	var token string
+	if err != nil {
+		// the installation is retried with a new token, the expired one
+		// is kept when it can't be generated
+		var tokenErr error
+		if token, tokenErr = generateRemoteToken(); tokenErr != nil {
+			logrus.Errorf("failed to generate a token of the remote API: %v", tokenErr)
+		}
+	}
+
+	a.lock.Lock()
+	if err != nil {
+		logrus.Errorf("installation failed: %v", err)
+		a.status = remoteFailed
+		if token != "" {
+			a.token = token
+		}
+		a.err = err.Error()
+		a.addEventLocked(remoteEvent{Type: remoteStatusEvent, Status: remoteFailed, Message: a.err})
+	} else {
+		a.status = remoteSucceeded
+		a.addEventLocked(remoteEvent{Type: remoteStatusEvent, Status: remoteSucceeded})
+	}
+	a.lock.Unlock()
+	a.onChange()
+}
+
+func (a *remoteAPI) getStatus(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	a.lock.Lock()
+	status := a.getStatusLocked()
+	a.lock.Unlock()
+	writeRemoteJSON(w, http.StatusOK, status)
+}
+
+// streamEvents writes the events of the current installation as lines of JSON,
+// from its start until it ends. The installation takes longer than the write
+// timeout of the server, which is extended for every write instead.
+func (a *remoteAPI) streamEvents(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	conn, _ := r.Context().Value(remoteConnKey{}).(net.Conn)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+	encoder := json.NewEncoder(w)
+	var sent int
+	for {
+		a.lock.Lock()
+		events := a.events[sent:]
+		ended := a.status == remoteSucceeded || a.status == remoteFailed
+		updated := a.updated
+		a.lock.Unlock()
+
+		if conn != nil {
+			if err := conn.SetWriteDeadline(time.Now().Add(remoteWriteTimeout)); err != nil {
+				return
+			}
+		}
+		for _, event := range events {
+			if err := encoder.Encode(event); err != nil {
+				return
+			}
+		}
+		sent += len(events)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		if ended {
+			return
+		}
+		select {
+		case <-updated:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// claimLocal tells the installation is driven on the console, the remote
+// session can't take control then. The console takes over from a remote
+// session which didn't start the installation or failed it, its config is
+// dropped. It returns false while the remote session installs or once it
+// installed.
+func (a *remoteAPI) claimLocal() bool {
+	if a == nil {
+		return true
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	switch a.status {
+	case remoteInstalling, remoteSucceeded:
+		return false
+	case remoteConfigured, remoteFailed:
+		logrus.Info("the installation is taken over on the console from the remote session")
+		a.status = remoteIdle
+		a.config = nil
+		a.progress = installProgress{Stage: -1}
+		a.err = ""
+		a.events = nil
+	}
+	a.local = true
+	return true
+}
+
+// getSession returns the status and the last log lines of the installation
+func (a *remoteAPI) getSession(lines int) (remoteStatus, []string) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	var logs []string
+	for i := len(a.events) - 1; i >= 0 && len(logs) < lines; i-- {
+		if a.events[i].Type == remoteLogEvent {
+			logs = append([]string{a.events[i].Message}, logs...)
+		}
+	}
+	return a.getStatusLocked(), logs
+}
+
+func (a *remoteAPI) getConflict() string {
+	switch {
+	case a.local:
+		return "The installation is driven on the console"
+	case a.status == remoteInstalling:
+		return "The installation is in progress"
+	case a.status == remoteSucceeded:
+		return "Harvester is installed"
+	}
+	return ""
+}
+
+func (a *remoteAPI) getStatusLocked() remoteStatus {
+	return remoteStatus{
+		Status:   a.status,
+		Progress: getRemoteProgress(a.progress),
+		Error:    a.err,
+	}
+}
+
+func (a *remoteAPI) addEventLocked(event remoteEvent) {
+	a.events = append(a.events, event)
+	close(a.updated)
+	a.updated = make(chan struct{})
+}
+
+// installRemotely installs with a config submitted to the remote API the way
+// the automatic installation does, the host reboots after a while when it is
+// installed
+func installRemotely(c *cfg.InstallConfig, printer func(string), reporter func(installProgress)) error {
+	if err := prepareInstallConfig(c, printer); err != nil {
+		return err
+	}
+	printer(fmt.Sprintf("Installing Harvester in %s mode to %s", c.InstallMode, c.K3OS.Install.Device))
+	if err := doInstall(c, printer, reporter); err != nil {
+		return err
+	}
+	printer(getInstallSummary(c, getManagementAddress(c)))
+	printer(fmt.Sprintf("The system restarts in %d seconds", int(rebootTimeout.Seconds())))
+	time.AfterFunc(rebootTimeout, func() {
+		rebootAfterInstall(c.K3OS.Install.PowerOff)
+	})
+	return nil
+}
+
+func getRemoteProgress(progress installProgress) remoteProgress {
+	return remoteProgress{
+		Stage:       progress.Stage + 1,
+		Description: progress.Description(),
+		Percent:     progress.Percent,
+	}
+}
+
+func getRemoteInterfaces() ([]remoteInterface, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	result := []remoteInterface{}
+	for _, i := range ifaces {
+		if i.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		ri := remoteInterface{
+			Name: i.Name,
+			MAC:  i.HardwareAddr.String(),
+			Up:   i.Flags&net.FlagUp != 0,
+		}
+		addrs, err := i.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ri.Addresses = append(ri.Addresses, addr.String())
+		}
+		result = append(result, ri)
+	}
+	return result, nil
+}
+
+// getRemoteContent tells the status of the installation driven by the remote
+// session on the console
+func getRemoteContent(status remoteStatus, lines []string) string {
+	content := "A remote session is in control of the installation.\n\n"
+	switch status.Status {
+	case remoteConfigured:
+		content += "Install config submitted, waiting for the installation to start\n"
+	case remoteInstalling:
+		content += fmt.Sprintf("%s (%d%%)\n", status.Progress.Description, status.Progress.Percent)
+	case remoteSucceeded:
+		content += "Installation completed\n"
+	case remoteFailed:
+		content += fmt.Sprintf("Installation failed at %s\n%s\n", status.Progress.Description, status.Error)
+		content += "The installation can be retried with the new token shown above\n"
+	}
+	if status.Status == remoteConfigured || status.Status == remoteFailed {
+		content += "Press Enter to install on the console instead\n"
+	}
+	if len(lines) > 0 {
+		content += "\n" + strings.Join(lines, "\n") + "\n"
+	}
+	return content
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeRemoteError(w, http.StatusMethodNotAllowed, fmt.Sprintf("Method %s is not allowed", r.Method))
+	return false
+}
+
+func writeRemoteJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logrus.Warnf("failed to write remote API response: %v", err)
+	}
+}
+
+func writeRemoteError(w http.ResponseWriter, code int, message string, details ...string) {
+	writeRemoteJSON(w, code, remoteError{Error: message, Details: details})
+}
@@ -0,0 +1,313 @@
+package console
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rancher/harvester-installer/pkg/cluster"
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/rancher/harvester-installer/pkg/disk"
+	"github.com/rancher/harvester-installer/pkg/preflight"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testRemoteToken  = "remote-token"
+	testRemoteConfig = `install_mode: create
+k3os:
+  password: rancher
+  install:
+    device: /dev/sda
+`
+)
+
+// newTestRemoteAPI serves a remote API reading the host from root, the
+// installation is done by install
+func newTestRemoteAPI(root string, install func(c *cfg.InstallConfig, printer func(string), reporter func(installProgress)) error) (*remoteAPI, *httptest.Server) {
+	a := newRemoteAPI(testRemoteToken)
+	a.scanner = &disk.Scanner{
+		SysRoot:  filepath.Join(root, "sys"),
+		DevRoot:  filepath.Join(root, "dev"),
+		UdevRoot: filepath.Join(root, "udev"),
+	}
+	a.checker = &preflight.Checker{
+		ProcRoot: filepath.Join(root, "proc"),
+		SysRoot:  filepath.Join(root, "sys"),
+		DevRoot:  filepath.Join(root, "dev"),
+	}
+	a.install = install
+	return a, httptest.NewServer(a)
+}
+
+func doRemoteRequest(t *testing.T, server *httptest.Server, method, path, token, body string) (int, string) {
+	req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+	assert.Nil(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if !assert.Nil(t, err) {
+		return 0, ""
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	assert.Nil(t, err)
+	return resp.StatusCode, string(data)
+}
+
+func TestRemoteAPIAuthorization(t *testing.T) {
+	_, server := newTestRemoteAPI("", nil)
+	defer server.Close()
+
+	code, body := doRemoteRequest(t, server, http.MethodGet, "/v1/status", "", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.JSONEq(t, `{"error": "The token shown on the console is required"}`, body)
+	code, _ = doRemoteRequest(t, server, http.MethodGet, "/v1/status", "wrong", "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/status", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", testRemoteToken)
+	resp, err := http.DefaultClient.Do(req)
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode, "the Bearer prefix is required")
+	}
+
+	code, body = doRemoteRequest(t, server, http.MethodGet, "/v1/status", testRemoteToken, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.JSONEq(t, `{"status": "idle", "progress": {"stage": 0, "description": "Preparing installation", "percent": 0}}`, body)
+	code, _ = doRemoteRequest(t, server, http.MethodPost, "/v1/status", testRemoteToken, "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+}
+
+func TestRemoteAPIInventory(t *testing.T) {
+	root, err := ioutil.TempDir("", "remote")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	for path, content := range map[string]string{
+		"sys/block/sda/size":         fmt.Sprintf("%d\n", 200<<30/512),
+		"sys/block/sda/device/model": "QEMU HARDDISK\n",
+		"dev/sda":                    "",
+		"proc/cpuinfo":               "processor\t: 0\nflags\t\t: fpu vmx\n\n",
+		"proc/meminfo":               "MemTotal:       1024 kB\n",
+	} {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(filepath.Join(root, path)), 0755))
+		assert.Nil(t, ioutil.WriteFile(filepath.Join(root, path), []byte(content), 0644))
+	}
+	_, server := newTestRemoteAPI(root, nil)
+	defer server.Close()
+
+	code, body := doRemoteRequest(t, server, http.MethodGet, "/v1/inventory", testRemoteToken, "")
+	assert.Equal(t, http.StatusOK, code)
+	var inventory remoteInventory
+	assert.Nil(t, json.Unmarshal([]byte(body), &inventory))
+	assert.Equal(t, []remoteDisk{
+		{
+			Name:  "sda",
+			Path:  filepath.Join(root, "dev", "sda"),
+			Model: "QEMU HARDDISK",
+			Size:  200 << 30,
+		},
+	}, inventory.Disks)
+	assert.NotNil(t, inventory.Interfaces)
+	var checks []string
+	for _, check := range inventory.Checks {
+		checks = append(checks, check.Name+": "+check.Status)
+	}
+	assert.Contains(t, checks, "CPU count: FAIL")
+	assert.Contains(t, checks, "Memory: FAIL")
+}
+
+func TestRemoteAPIInstall(t *testing.T) {
+	release := make(chan error)
+	var installed *cfg.InstallConfig
+	a, server := newTestRemoteAPI("", func(c *cfg.InstallConfig, printer func(string), reporter func(installProgress)) error {
+		installed = c
+		printer("Formatting /dev/sda")
+		reporter(installProgress{Stage: 1, StagePercent: 50, Percent: 7})
+		return <-release
+	})
+	defer server.Close()
+	changes := make(chan struct{}, 100)
+	a.onChange = func() {
+		changes <- struct{}{}
+	}
+
+	code, _ := doRemoteRequest(t, server, http.MethodPost, "/v1/install", testRemoteToken, "")
+	assert.Equal(t, http.StatusConflict, code, "a config is required")
+	code, body := doRemoteRequest(t, server, http.MethodPut, "/v1/config", testRemoteToken, "install_mode: join\n")
+	assert.Equal(t, http.StatusUnprocessableEntity, code)
+	assert.Contains(t, body, `"error":"Invalid install config"`)
+	assert.Contains(t, body, "k3os.token: Cluster token is required")
+	assert.Empty(t, changes, "an invalid config doesn't take control")
+
+	code, body = doRemoteRequest(t, server, http.MethodPut, "/v1/config", testRemoteToken, testRemoteConfig)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"status":"configured"`)
+	assert.Len(t, changes, 1)
+
+	// the events are streamed from the start of the installation to its end
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/events", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer "+testRemoteToken)
+	resp, err := http.DefaultClient.Do(req)
+	assert.Nil(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	events := bufio.NewScanner(resp.Body)
+
+	code, body = doRemoteRequest(t, server, http.MethodPost, "/v1/install", testRemoteToken, "")
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.Contains(t, body, `"status":"installing"`)
+	for _, expected := range []string{
+		`{"type":"status","status":"installing"}`,
+		`{"type":"log","message":"Formatting /dev/sda"}`,
+		`{"type":"progress","progress":{"stage":2,"description":"Stage 2/6: Formatting partitions","percent":7}}`,
+	} {
+		assert.True(t, events.Scan())
+		assert.JSONEq(t, expected, events.Text())
+	}
+	code, body = doRemoteRequest(t, server, http.MethodPut, "/v1/config", testRemoteToken, testRemoteConfig)
+	assert.Equal(t, http.StatusUnauthorized, code, "the config can't be changed while installing")
+	assert.JSONEq(t, `{"error": "The token expired when the installation started"}`, body)
+	code, _ = doRemoteRequest(t, server, http.MethodGet, "/v1/status", testRemoteToken, "")
+	assert.Equal(t, http.StatusOK, code, "the installation is followed with the expired token")
+	assert.False(t, a.claimLocal(), "the remote session is installing")
+	status, lines := a.getSession(installLogLines)
+	assert.Equal(t, []string{"Formatting /dev/sda"}, lines)
+	assert.Equal(t, "A remote session is in control of the installation.\n\n"+
+		"Stage 2/6: Formatting partitions (7%)\n\n"+
+		"Formatting /dev/sda\n", getRemoteContent(status, lines))
+
+	release <- errors.New("failed to format /dev/sda")
+	assert.True(t, events.Scan())
+	assert.JSONEq(t, `{"type":"status","status":"failed","message":"failed to format /dev/sda"}`, events.Text())
+	assert.False(t, events.Scan(), "the stream ends with the installation")
+	assert.Equal(t, "/dev/sda", installed.K3OS.Install.Device)
+	assert.Equal(t, "rancher", installed.K3OS.Password, "the password is encrypted by the installation")
+
+	// the installation is retried with the submitted config and a new token
+	code, _ = doRemoteRequest(t, server, http.MethodPost, "/v1/install", testRemoteToken, "")
+	assert.Equal(t, http.StatusUnauthorized, code)
+	a.lock.Lock()
+	token := a.token
+	a.lock.Unlock()
+	assert.NotEqual(t, testRemoteToken, token)
+	assert.Len(t, token, remoteTokenLength)
+	code, _ = doRemoteRequest(t, server, http.MethodPost, "/v1/install", token, "")
+	assert.Equal(t, http.StatusAccepted, code)
+	release <- nil
+	code, body = doRemoteRequest(t, server, http.MethodGet, "/v1/events", token, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.True(t, strings.HasSuffix(body, `{"type":"status","status":"succeeded"}`+"\n"))
+	code, _ = doRemoteRequest(t, server, http.MethodPost, "/v1/install", token, "")
+	assert.Equal(t, http.StatusUnauthorized, code, "Harvester is installed")
+}
+
+func TestRemoteAPILocalInstall(t *testing.T) {
+	a, server := newTestRemoteAPI("", nil)
+	defer server.Close()
+
+	assert.True(t, a.claimLocal())
+	code, body := doRemoteRequest(t, server, http.MethodPut, "/v1/config", testRemoteToken, testRemoteConfig)
+	assert.Equal(t, http.StatusConflict, code)
+	assert.JSONEq(t, `{"error": "The installation is driven on the console"}`, body)
+
+	var disabled *remoteAPI
+	assert.True(t, disabled.claimLocal(), "the console drives the installation without the remote API")
+}
+
+func TestRemoteAPITakeOver(t *testing.T) {
+	a, server := newTestRemoteAPI("", func(c *cfg.InstallConfig, printer func(string), reporter func(installProgress)) error {
+		return errors.New("failed to format /dev/sda")
+	})
+	defer server.Close()
+
+	// the console takes over from the submitted config
+	code, _ := doRemoteRequest(t, server, http.MethodPut, "/v1/config", testRemoteToken, testRemoteConfig)
+	assert.Equal(t, http.StatusOK, code)
+	status, _ := a.getSession(installLogLines)
+	assert.Contains(t, getRemoteContent(status, nil), "Press Enter to install on the console instead")
+	assert.True(t, a.claimLocal())
+	code, body := doRemoteRequest(t, server, http.MethodGet, "/v1/status", testRemoteToken, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, `"status":"idle"`)
+	code, _ = doRemoteRequest(t, server, http.MethodPost, "/v1/install", testRemoteToken, "")
+	assert.Equal(t, http.StatusConflict, code, "the submitted config is dropped")
+
+	// and from a failed installation
+	a, server = newTestRemoteAPI("", a.install)
+	defer server.Close()
+	code, _ = doRemoteRequest(t, server, http.MethodPut, "/v1/config", testRemoteToken, testRemoteConfig)
+	assert.Equal(t, http.StatusOK, code)
+	code, _ = doRemoteRequest(t, server, http.MethodPost, "/v1/install", testRemoteToken, "")
+	assert.Equal(t, http.StatusAccepted, code)
+	assert.Eventually(t, func() bool {
+		status, _ := a.getSession(installLogLines)
+		return status.Status == remoteFailed
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, a.claimLocal())
+	a.lock.Lock()
+	token := a.token
+	a.lock.Unlock()
+	code, body = doRemoteRequest(t, server, http.MethodPost, "/v1/install", token, "")
+	assert.Equal(t, http.StatusConflict, code)
+	assert.JSONEq(t, `{"error": "The installation is driven on the console"}`, body)
+}
+
+func TestGetRemoteInfo(t *testing.T) {
+	assert.Equal(t, "Remote installation API: https://10.0.0.5:8080, token: abc\nCertificate fingerprint: SHA256:AB:CD",
+		getRemoteInfo("10.0.0.5", 8080, "abc", "SHA256:AB:CD"))
+	assert.Equal(t, "Remote installation API on port 8080, token: abc\nCertificate fingerprint: SHA256:AB:CD",
+		getRemoteInfo("", 8080, "abc", "SHA256:AB:CD"))
+}
+
+func TestGenerateRemoteCertificate(t *testing.T) {
+	cert, fingerprint, err := generateRemoteCertificate("127.0.0.1")
+	assert.Nil(t, err)
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	assert.Nil(t, err)
+	assert.Equal(t, "127.0.0.1", parsed.IPAddresses[0].String())
+	assert.True(t, strings.HasPrefix(fingerprint, "SHA256:"))
+
+	// the clients check the self-signed certificate by its fingerprint
+	a := newRemoteAPI(testRemoteToken)
+	server := httptest.NewUnstartedServer(a)
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	defer server.Close()
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			served, err := cluster.GetFingerprint(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: rawCerts[0]}))
+			if err != nil {
+				return err
+			}
+			if served != fingerprint {
+				return errors.New("unexpected certificate " + served)
+			}
+			return nil
+		},
+	}}}
+	req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/status", nil)
+	assert.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer "+testRemoteToken)
+	resp, err := client.Do(req)
+	if assert.Nil(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+}
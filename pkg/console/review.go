@@ -0,0 +1,125 @@
+package console
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/rancher/harvester-installer/pkg/widgets"
+)
+
+const (
+	// secrets are not shown on the review screen
+	maskedValue = "********"
+	noneValue   = "none"
+)
+
+// reviewRow is a setting on the review screen, selecting it shows the step
+// which asks the setting
+type reviewRow struct {
+	step  string
+	label string
+	value string
+}
+
+// getReviewRows lists the settings of the config in the order of the steps
+// asking them
+func getReviewRows(c *cfg.InstallConfig) []reviewRow {
+	orNone := func(value string) string {
+		if value == "" {
+			return noneValue
+		}
+		return value
+	}
+	masked := func(value string) string {
+		if value == "" {
+			return noneValue
+		}
+		return maskedValue
+	}
+
+	rows := []reviewRow{
+		{step: askCreatePanel, label: "Install mode", value: c.InstallMode},
+	}
+	if c.InstallMode == modeJoin {
+		rows = append(rows, reviewRow{step: serverURLPanel, label: "Management address", value: orNone(c.K3OS.ServerURL)})
+	}
+	var device, configURL string
+	if c.K3OS.Install != nil {
+		device = c.K3OS.Install.Device
+		configURL = c.K3OS.Install.ConfigURL
+	}
+	if device != "" {
+		device = describeDevice(device)
+	}
+	var dataDisks []string
+	for _, d := range c.DataDisks {
+		dataDisks = append(dataDisks, describeDevice(d))
+	}
+	checks := "passed"
+	if c.SkipChecks {
+		checks = "failures ignored"
+	}
+	rows = append(rows,
+		reviewRow{step: preflightPanel, label: "Hardware checks", value: checks},
+		reviewRow{step: diskPanel, label: "Installation target", value: orNone(device)},
+		reviewRow{step: dataDisksPanel, label: "Data disks", value: orNone(strings.Join(dataDisks, ", "))},
+		reviewRow{step: tokenPanel, label: "Cluster token", value: masked(c.K3OS.Token)},
+		reviewRow{step: passwordPanel, label: "Password", value: masked(c.K3OS.Password)},
+		reviewRow{step: sshKeyPanel, label: "SSH keys", value: orNone(c.SSHKeyURL)},
+		reviewRow{step: networkPanel, label: "Management NIC", value: orNone(c.Network.Interface)},
+	)
+	if c.Network.Method == networkMethodStatic {
+		rows = append(rows,
+			reviewRow{step: networkMethodPanel, label: "IPv4 method", value: "Static"},
+			reviewRow{step: addressPanel, label: "IPv4 address", value: fmt.Sprintf("%s via %s", c.Network.IP, c.Network.Gateway)},
+		)
+	} else {
+		rows = append(rows, reviewRow{step: networkMethodPanel, label: "IPv4 method", value: "DHCP"})
+	}
+	rows = append(rows,
+		reviewRow{step: dnsServersPanel, label: "DNS servers", value: orNone(strings.Join(c.K3OS.DNSNameservers, ", "))},
+		reviewRow{step: ntpServersPanel, label: "NTP servers", value: orNone(strings.Join(c.K3OS.NTPServers, ", "))},
+		reviewRow{step: hostnamePanel, label: "Hostname", value: orNone(c.Hostname)},
+	)
+	if httpProxy, httpsProxy, noProxy := getProxies(c); httpProxy != "" || httpsProxy != "" {
+		rows = append(rows,
+			reviewRow{step: proxyPanel, label: "HTTP proxy", value: orNone(cfg.MaskPassword(httpProxy))},
+			reviewRow{step: httpsProxyPanel, label: "HTTPS proxy", value: orNone(cfg.MaskPassword(httpsProxy))},
+			reviewRow{step: noProxyPanel, label: "No proxy", value: orNone(noProxy)},
+		)
+	} else {
+		rows = append(rows, reviewRow{step: proxyPanel, label: "Proxy", value: noneValue})
+	}
+	var mirrors []string
+	if r := c.K3OS.Registries; r != nil {
+		for name, mirror := range r.Mirrors {
+			mirrors = append(mirrors, fmt.Sprintf("%s -> %s", name, strings.Join(mirror.Endpoints, ", ")))
+		}
+		sort.Strings(mirrors)
+	}
+	rows = append(rows,
+		reviewRow{step: registryPanel, label: "Registry mirrors", value: orNone(strings.Join(mirrors, "; "))},
+		reviewRow{step: cloudInitPanel, label: "Cloud-init URL", value: orNone(configURL)},
+	)
+	return rows
+}
+
+// getReviewOptions returns the rows of the config as options of at most width
+// characters, which are shown after the actions
+func getReviewOptions(c *cfg.InstallConfig, actions []widgets.Option, width int) []widgets.Option {
+	options := append([]widgets.Option{}, actions...)
+	for _, row := range getReviewRows(c) {
+		text := fmt.Sprintf("%-21s%s", row.label+":", row.value)
+		// options must fit in a line to keep the cursor on the selected option
+		if width > 3 && len(text) > width {
+			text = text[:width-3] + "..."
+		}
+		options = append(options, widgets.Option{
+			Value: row.step,
+			Text:  text,
+		})
+	}
+	return options
+}
@@ -0,0 +1,928 @@
+package console
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rancher/harvester-installer/pkg/cluster"
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/rancher/harvester-installer/pkg/disk"
+	"github.com/rancher/harvester-installer/pkg/preflight"
+	"github.com/rancher/harvester-installer/pkg/util"
+	"github.com/rancher/harvester-installer/pkg/widgets"
+	"github.com/rancher/k3os/pkg/config"
+	"github.com/rancher/k3os/pkg/questions"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// answers of the text console to go back to the previous step and to clear
+	// an optional value
+	textBack  = "<"
+	textClear = "-"
+
+	textHelp = "Press Enter to keep the value in brackets, enter " + textClear +
+		" to clear an optional value or " + textBack + " to go back to the previous step."
+	textRemoteAPIWarning = "Warning: the remote installation API is not served in text mode, " +
+		"the installation is driven on this terminal only."
+)
+
+var (
+	// errTextBack is returned by the questions of a step to go back
+	errTextBack = errors.New("back to the previous step")
+	// serial consoles draw the panels poorly or not at all
+	serialTTYPrefixes = []string{"ttyS", "ttyAMA", "ttyUSB", "hvc"}
+)
+
+// prompter asks the questions of the text console
+type prompter interface {
+	// prompt asks for a line, def is the answer of an empty line
+	prompt(text, def string) (string, error)
+	// promptPassword asks for a line which is not echoed
+	promptPassword(text string) (string, error)
+	// promptOptions asks for one of the options, it returns the index of the
+	// answer. def is the index of the default answer.
+	promptOptions(text string, def int, options ...string) (int, error)
+	// promptBool asks a yes or no question
+	promptBool(text string, def bool) (bool, error)
+	print(text string)
+}
+
+// termPrompter asks the questions on the terminal of the standard input
+type termPrompter struct{}
+
+func (termPrompter) prompt(text, def string) (string, error) {
+	return questions.PromptOptional(text, def)
+}
+
+func (termPrompter) promptPassword(text string) (string, error) {
+	if err := stty("-echo"); err != nil {
+		logrus.Warnf("failed to disable echo: %v", err)
+	} else {
+		defer func() {
+			stty("echo")
+			questions.PrintlnToTerm()
+		}()
+	}
+	return questions.PromptOptional(text, "")
+}
+
+func (termPrompter) promptOptions(text string, def int, options ...string) (int, error) {
+	return questions.PromptFormattedOptions(text, def, options...)
+}
+
+func (termPrompter) promptBool(text string, def bool) (bool, error) {
+	return questions.PromptBool(text, def)
+}
+
+func (termPrompter) print(text string) {
+	questions.PrintToTerm(text)
+}
+
+func stty(args ...string) error {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
+
+// isTextTerminal tells whether the console runs on a terminal which can't draw
+// the panels, i.e. a dumb terminal or a serial console
+func isTextTerminal() bool {
+	if term := os.Getenv("TERM"); term == "" || term == "dumb" {
+		return true
+	}
+	// the panels are drawn on the terminal of TTY, see termbox
+	name := filepath.Base(os.Getenv("TTY"))
+	for _, prefix := range serialTTYPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// runTextInstall runs the install wizard on the terminal of TTY like the panels
+// do, or on the standard input and output. The remote API enabled on the
+// cmdline is not served, which is told on the terminal.
+func runTextInstall(remoteAPI bool) error {
+	if tty := os.Getenv("TTY"); tty != "" {
+		f, err := os.OpenFile(tty, os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		os.Stdin, os.Stdout = f, f
+	}
+	t := newTextConsole(termPrompter{})
+	if remoteAPI {
+		// the questions hold the terminal, a remote session couldn't take
+		// control from them
+		t.print(textRemoteAPIWarning + "\n")
+	}
+	return t.run()
+}
+
+// textConsole asks the steps of the install wizard one after another on a line
+// terminal, it has the same flow and validation as the panels
+type textConsole struct {
+	prompter
+	flow *flow
+	// serverFingerprint is the fingerprint of the CA of the cluster to join
+	serverFingerprint string
+}
+
+func newTextConsole(p prompter) *textConsole {
+	t := &textConsole{prompter: p}
+	t.flow = newInstallFlow(func() bool {
+		return t.serverFingerprint != ""
+	})
+	return t
+}
+
+// run asks the steps until the installation is confirmed, then installs
+func (t *textConsole) run() error {
+	t.print("Harvester installer\n" + textHelp + "\n")
+	for {
+		install, err := t.runWizard()
+		if err != nil || !install {
+			return err
+		}
+		if err := t.install(); err == nil {
+			return nil
+		}
+		retry := true
+		for retry {
+			selected, err := t.selectOption("Installation failed", []widgets.Option{
+				{Value: installRetry, Text: "Retry"},
+				{Value: installEdit, Text: "Edit configuration"},
+				{Value: installShell, Text: "Drop to shell"},
+				{Value: installSaveLog, Text: "Save log to " + installLogFile},
+			}, "", false)
+			if err != nil {
+				return err
+			}
+			switch selected {
+			case installRetry:
+				if err := t.install(); err == nil {
+					return nil
+				}
+			case installEdit:
+				retry = false
+			case installShell:
+				return nil
+			case installSaveLog:
+				if err := installLogs.save(installLogFile); err != nil {
+					t.print(fmt.Sprintf("Failed to save log: %v\n", err))
+				} else {
+					t.print("Log saved to " + installLogFile + "\n")
+				}
+			}
+		}
+	}
+}
+
+// runWizard asks the steps from the first one, it returns whether the
+// installation is confirmed on review
+func (t *textConsole) runWizard() (bool, error) {
+	t.flow.reset()
+	current := t.flow.next(&cfg.Config, "")
+	if current == "" {
+		current = confirmPanel
+	}
+	for current != "" {
+		if current == confirmPanel {
+			edit, install, err := t.review()
+			if err == errTextBack {
+				if prev := t.flow.back(&cfg.Config); prev != "" {
+					current = prev
+				}
+				continue
+			}
+			if err != nil || edit == "" {
+				return install, err
+			}
+			t.flow.edit(&cfg.Config, confirmPanel)
+			current = edit
+			continue
+		}
+		if err := t.ask(current); err == errTextBack {
+			if prev := t.flow.back(&cfg.Config); prev != "" {
+				current = prev
+			}
+			continue
+		} else if err != nil {
+			return false, err
+		}
+		next, err := t.flow.forward(&cfg.Config, current)
+		if err != nil {
+			t.print(err.Error() + "\n")
+			continue
+		}
+		current = next
+	}
+	return false, nil
+}
+
+// ask asks the questions of a step and sets the answers to the config
+func (t *textConsole) ask(step string) error {
+	c := &cfg.Config
+	switch step {
+	case askCreatePanel:
+		t.title("Choose installation mode")
+		mode, err := t.choose("", []widgets.Option{
+			{Value: modeCreate, Text: "Create a new Harvester cluster"},
+			{Value: modeJoin, Text: "Join an existing Harvester cluster"},
+		}, c.InstallMode)
+		if err != nil {
+			return err
+		}
+		c.InstallMode = mode
+	case diskPanel:
+		return t.askDisk()
+	case dataDisksPanel:
+		return t.askDataDisks()
+	case preflightPanel:
+		return t.askPreflight()
+	case serverURLPanel:
+		t.title("Configure management address")
+		t.print(serverURLNote + "\n")
+		for {
+			serverURL, err := t.input("Management address", c.K3OS.ServerURL, false)
+			if err != nil {
+				return err
+			}
+			if err := cfg.ValidateServerURL(serverURL); err != nil {
+				t.print(err.Error() + "\n")
+				continue
+			}
+			if serverURL = getFormattedServerURL(serverURL); serverURL != c.K3OS.ServerURL {
+				// the token is checked against the new server
+				t.serverFingerprint = ""
+			}
+			c.K3OS.ServerURL = serverURL
+			return nil
+		}
+	case tokenPanel:
+		return t.askToken()
+	case passwordPanel:
+		t.title("Configure the password to access the node")
+		for {
+			password, err := t.password("Password")
+			if err != nil {
+				return err
+			}
+			if err := cfg.ValidatePassword(password); err != nil {
+				t.print(err.Error() + "\n")
+				continue
+			}
+			confirmed, err := t.password("Confirm password")
+			if err != nil {
+				return err
+			}
+			if password != confirmed {
+				t.print("Password mismatching\n")
+				continue
+			}
+			encrypted, err := util.GetEncrptedPasswd(password)
+			if err != nil {
+				return err
+			}
+			c.K3OS.Password = encrypted
+			return nil
+		}
+	case sshKeyPanel:
+		t.title("Optional: import SSH keys")
+		t.print(sshKeyNote + "\n")
+		url, err := t.input("HTTP URL", c.SSHKeyURL, true)
+		if err != nil {
+			return err
+		}
+		c.SSHKeyURL = url
+	case networkPanel:
+		t.title("Select interface for the management network")
+		options, err := getNetworkInterfaceOptions()
+		if err != nil {
+			return err
+		}
+		iface, err := t.choose("", options, c.Network.Interface)
+		if err != nil {
+			return err
+		}
+		c.Network.Interface = iface
+	case networkMethodPanel:
+		t.title("Choose IPv4 addressing of the management network")
+		method, err := t.choose("", []widgets.Option{
+			{Value: networkMethodDHCP, Text: "Automatic (DHCP)"},
+			{Value: networkMethodStatic, Text: "Static IP"},
+		}, c.Network.Method)
+		if err != nil {
+			return err
+		}
+		c.Network.Method = method
+	case addressPanel:
+		t.title("Configure static IPv4 address of the management network")
+		address, err := t.input("IPv4 address (CIDR)", c.Network.IP, false)
+		if err != nil {
+			return err
+		}
+		gateway, err := t.input("Gateway", c.Network.Gateway, false)
+		if err != nil {
+			return err
+		}
+		c.Network.IP, c.Network.Gateway = address, gateway
+	case dnsServersPanel:
+		return t.askDNSServers()
+	case ntpServersPanel:
+		t.title("Optional: configure NTP servers")
+		t.print(ntpServersNote + "\n")
+		for {
+			data, err := t.input("NTP servers", strings.Join(c.K3OS.NTPServers, ","), true)
+			if err != nil {
+				return err
+			}
+			servers, err := parseNTPServers(data)
+			if err != nil {
+				t.print(err.Error() + "\n")
+				continue
+			}
+			if err := checkServers(servers, util.CheckNTPServer); err != nil {
+				if ok, err := t.useAnyway(err.Error()); err != nil {
+					return err
+				} else if !ok {
+					continue
+				}
+			}
+			c.K3OS.NTPServers = servers
+			return nil
+		}
+	case hostnamePanel:
+		return t.askHostname()
+	case proxyPanel:
+		t.title("Optional: configure proxy, the HTTPS proxy defaults to the HTTP proxy")
+		for {
+			// credentials are asked separately, so that the password is not echoed
+			httpProxy, httpsProxy, _ := getProxies(c)
+			httpProxy, err := t.input("HTTP proxy", setProxyUser(httpProxy, "", ""), true)
+			if err != nil {
+				return err
+			}
+			httpsProxy, err = t.input("HTTPS proxy", setProxyUser(httpsProxy, "", ""), true)
+			if err != nil {
+				return err
+			}
+			if err := setProxyAddresses(c, httpProxy, httpsProxy); err != nil {
+				t.print(err.Error() + "\n")
+				continue
+			}
+			return nil
+		}
+	case proxyUserPanel:
+		t.title("Optional: configure proxy credentials")
+		httpProxy, httpsProxy, noProxy := getProxies(c)
+		oldProxy := httpProxy
+		if oldProxy == "" {
+			oldProxy = httpsProxy
+		}
+		oldUsername, oldPassword := getProxyUser(oldProxy)
+		username, err := t.input("Proxy username", oldUsername, true)
+		if err != nil {
+			return err
+		}
+		// the credentials are removed without a username
+		var password string
+		if username != "" {
+			if password, err = t.password("Proxy password (Enter to keep it)"); err != nil {
+				return err
+			}
+			if password == "" {
+				password = oldPassword
+			}
+		}
+		setProxies(c, setProxyUser(httpProxy, username, password), setProxyUser(httpsProxy, username, password), noProxy)
+	case noProxyPanel:
+		t.title("Configure addresses which bypass the proxy")
+		t.print(noProxyNote + "\n")
+		for {
+			httpProxy, httpsProxy, noProxy := getProxies(c)
+			if noProxy == "" {
+				noProxy = getNoProxy(c)
+			}
+			noProxy, err := t.input("No proxy", noProxy, false)
+			if err != nil {
+				return err
+			}
+			if err := cfg.ValidateNoProxy(noProxy); err != nil {
+				t.print(err.Error() + "\n")
+				continue
+			}
+			if err := checkProxies(httpProxy, httpsProxy); err != nil {
+				if ok, err := t.useAnyway(err.Error()); err != nil {
+					return err
+				} else if !ok {
+					continue
+				}
+			}
+			setProxies(c, httpProxy, httpsProxy, noProxy)
+			return nil
+		}
+	case registryPanel:
+		t.title("Optional: configure registry mirror")
+		t.print(registryNote + "\n")
+		var endpoint string
+		if r := c.K3OS.Registries; r != nil && len(r.Mirrors[mirroredRegistry].Endpoints) > 0 {
+			endpoint = r.Mirrors[mirroredRegistry].Endpoints[0]
+		}
+		endpoint, err := t.input("Mirror endpoint", endpoint, true)
+		if err != nil {
+			return err
+		}
+		setRegistryMirror(c, mirroredRegistry, endpoint)
+	case cloudInitPanel:
+		t.title("Optional: configure cloud-init")
+		if c.K3OS.Install == nil {
+			c.K3OS.Install = &config.Install{}
+		}
+		configURL, err := t.input("HTTP URL", c.K3OS.Install.ConfigURL, true)
+		if err != nil {
+			return err
+		}
+		c.K3OS.Install.ConfigURL = configURL
+	default:
+		return fmt.Errorf("unknown step %s", step)
+	}
+	return nil
+}
+
+func (t *textConsole) askDisk() error {
+	t.title("Choose installation target. Device will be formatted")
+	c := &cfg.Config
+	for {
+		scanned, err := disk.NewScanner().Scan()
+		if err != nil {
+			return err
+		}
+		disks := make(map[string]disk.Disk)
+		for _, d := range scanned {
+			disks[d.ID()] = d
+		}
+		options := getDiskOptions(scanned, 0)
+		if len(options) == 0 {
+			t.print(fmt.Sprintf("No disk of at least %s found\n", util.FormatSize(preflight.MinDiskSize)))
+			if _, err := t.input("Press Enter to scan again", "", true); err != nil {
+				return err
+			}
+			continue
+		}
+		var current string
+		if c.K3OS.Install != nil {
+			current = c.K3OS.Install.Device
+		}
+		device, err := t.choose("", options, current)
+		if err != nil {
+			return err
+		}
+		if d := disks[device]; d.HasData() {
+			t.print(getDiskDataWarning(d))
+			confirmed, err := t.promptBool("Erase all data on the disk?", false)
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				continue
+			}
+		}
+		if c.K3OS.Install == nil {
+			c.K3OS.Install = &config.Install{}
+		}
+		c.K3OS.Install.Device = device
+		c.DataDisks = removeString(c.DataDisks, device)
+		return nil
+	}
+}
+
+func (t *textConsole) askDataDisks() error {
+	t.title("Optional: choose data disks for VM storage. Disks will be formatted")
+	c := &cfg.Config
+	scanned, err := disk.NewScanner().Scan()
+	if err != nil {
+		return err
+	}
+	var installDevice string
+	if c.K3OS.Install != nil {
+		installDevice = c.K3OS.Install.Device
+	}
+	// the first option finishes the selection in the panel
+	options := getDataDiskOptions(scanned, installDevice, c.DataDisks, 0)[1:]
+	if len(options) == 0 {
+		t.print("No other disk found\n")
+		c.DataDisks = nil
+		return nil
+	}
+	var selected []string
+	for i, option := range options {
+		t.print(fmt.Sprintf("%d. %s\n", i+1, option.Text))
+		for _, d := range c.DataDisks {
+			if d == option.Value {
+				selected = append(selected, strconv.Itoa(i+1))
+			}
+		}
+	}
+	for {
+		data, err := t.input("Data disks (comma separated numbers)", strings.Join(selected, ","), true)
+		if err != nil {
+			return err
+		}
+		disks, err := parseDiskNumbers(data, options)
+		if err != nil {
+			t.print(err.Error() + "\n")
+			continue
+		}
+		var nonEmpty []disk.Disk
+		for _, d := range scanned {
+			for _, selected := range disks {
+				if d.ID() == selected && d.HasData() {
+					nonEmpty = append(nonEmpty, d)
+				}
+			}
+		}
+		if len(nonEmpty) > 0 {
+			t.print(getDiskDataWarning(nonEmpty...))
+			confirmed, err := t.promptBool("Erase all data on the disks?", false)
+			if err != nil {
+				return err
+			}
+			if !confirmed {
+				continue
+			}
+		}
+		c.DataDisks = disks
+		return nil
+	}
+}
+
+func (t *textConsole) askPreflight() error {
+	t.title("Hardware checks")
+	c := &cfg.Config
+	for {
+		var device string
+		if c.K3OS.Install != nil {
+			device = c.K3OS.Install.Device
+		}
+		results := preflight.NewChecker().Run(device)
+		t.print(preflight.FormatResults(results) + "\n")
+		if !preflight.HasFailure(results) {
+			c.SkipChecks = false
+			return nil
+		}
+		t.print("The host doesn't meet the minimum requirements of Harvester.\n")
+		selected, err := t.choose("", []widgets.Option{
+			{Value: "recheck", Text: "Check again"},
+			{Value: "ignore", Text: "Ignore failures and continue (unsupported)"},
+		}, "")
+		if err != nil {
+			return err
+		}
+		if selected == "ignore" {
+			c.SkipChecks = true
+			return nil
+		}
+	}
+}
+
+func (t *textConsole) askToken() error {
+	t.title("Configure cluster token")
+	c := &cfg.Config
+	token := c.K3OS.Token
+	if c.InstallMode == modeCreate {
+		t.print(clusterTokenNote + "\n")
+		if token == "" {
+			generated, err := generateToken()
+			if err != nil {
+				return err
+			}
+			token = generated
+		}
+	}
+	for {
+		answer, err := t.input("Cluster token", token, false)
+		if err != nil {
+			return err
+		}
+		token = answer
+		if token == "" {
+			t.print("Cluster token is required\n")
+			continue
+		}
+		if err := cfg.ValidateToken(c.InstallMode, token); err != nil {
+			t.print(err.Error() + "\n")
+			continue
+		}
+		if c.InstallMode == modeJoin {
+			result, err := cluster.NewChecker().Check(c.K3OS.ServerURL, token)
+			if err != nil {
+				logrus.Warnf("failed to check joining %s: %v", c.K3OS.ServerURL, err)
+				message, warning := getJoinCheckMessage(err)
+				if !warning {
+					t.print(message + "\n")
+					continue
+				}
+				if ok, err := t.useAnyway(message); err != nil {
+					return err
+				} else if !ok {
+					continue
+				}
+			} else {
+				t.serverFingerprint = result.Fingerprint
+			}
+		}
+		c.K3OS.Token = token
+		return nil
+	}
+}
+
+func (t *textConsole) askDNSServers() error {
+	c := &cfg.Config
+	static := c.Network.Method == networkMethodStatic
+	if static {
+		t.title("Configure DNS servers")
+	} else {
+		t.title("Optional: configure DNS servers")
+	}
+	t.print(dnsServersNote + "\n")
+	for {
+		data, err := t.input("DNS servers", strings.Join(c.K3OS.DNSNameservers, ","), !static)
+		if err != nil {
+			return err
+		}
+		servers, err := parseDNSServers(data)
+		if err != nil {
+			t.print(err.Error() + "\n")
+			continue
+		}
+		if static && len(servers) == 0 {
+			t.print("DNS servers are required for static IP\n")
+			continue
+		}
+		// the static address is not configured in the installer, skip checking then
+		if !static {
+			if err := checkServers(servers, util.CheckDNSServer); err != nil {
+				if ok, err := t.useAnyway(err.Error()); err != nil {
+					return err
+				} else if !ok {
+					continue
+				}
+			}
+		}
+		c.K3OS.DNSNameservers = servers
+		return nil
+	}
+}
+
+func (t *textConsole) askHostname() error {
+	t.title("Configure hostname")
+	c := &cfg.Config
+	options := getHostnameOptions(c.Network.Interface)
+	if len(options) > 0 {
+		t.print("Suggestions: " + strings.Join(options, ", ") + "\n")
+	}
+	t.print("Note: {mac} and {serial} are replaced with the MAC address and serial number\n")
+	hostname := c.Hostname
+	if hostname == "" && len(options) > 0 {
+		hostname = options[0]
+	}
+	for {
+		data, err := t.input("Hostname", hostname, false)
+		if err != nil {
+			return err
+		}
+		hostname = data
+		rendered, err := renderHostname(data, c.Network.Interface)
+		if err != nil {
+			t.print(fmt.Sprintf("Failed to render hostname: %v\n", err))
+			continue
+		}
+		if err := cfg.ValidateHostname(rendered); err != nil {
+			t.print(err.Error() + "\n")
+			continue
+		}
+		if c.InstallMode == modeJoin {
+			if message, warning := checkClusterHostname(c, rendered); message != "" {
+				if !warning {
+					t.print(message + "\n")
+					continue
+				}
+				if ok, err := t.useAnyway(message); err != nil {
+					return err
+				} else if !ok {
+					continue
+				}
+			}
+		}
+		c.Hostname = rendered
+		return nil
+	}
+}
+
+// parseDiskNumbers returns the disks of a comma separated list of option numbers
+func parseDiskNumbers(data string, options []widgets.Option) ([]string, error) {
+	var disks []string
+	for _, item := range splitList(data) {
+		n, err := strconv.Atoi(item)
+		if err != nil || n < 1 || n > len(options) {
+			return nil, fmt.Errorf("Select numbers between 1 and %d", len(options))
+		}
+		disks = append(disks, options[n-1].Value)
+	}
+	return disks, nil
+}
+
+// review lists the answers, it returns the step of the answer to change or
+// whether the installation is confirmed. errTextBack is returned to go back
+// to the previous step.
+func (t *textConsole) review() (string, bool, error) {
+	c := &cfg.Config
+	for {
+		t.title("Confirm installation options")
+		rows := getReviewRows(c)
+		for i, row := range rows {
+			t.print(fmt.Sprintf("%2d. %-21s%s\n", i+1, row.label+":", row.value))
+		}
+		if c.InstallMode == modeJoin && t.serverFingerprint != "" {
+			t.print(fmt.Sprintf("management CA: %v\n", t.serverFingerprint))
+		}
+		if c.InstallMode == modeCreate {
+			for _, addon := range c.Addons {
+				t.print(fmt.Sprintf("addon: %v\n", describeAddon(addon)))
+			}
+		}
+		t.print("Your disk will be formatted and Harvester will be installed with the configuration above.\n")
+		selected, err := t.choose("", []widgets.Option{
+			{Value: "yes", Text: "Install"},
+			{Value: "edit", Text: "Change a setting"},
+			{Value: "save", Text: "Save answers"},
+			{Value: "no", Text: "Cancel and reboot"},
+		}, "")
+		if err != nil {
+			return "", false, err
+		}
+		switch selected {
+		case "yes":
+			// answers are left incomplete when a step brought in by an edit is
+			// left by going back
+			if err := c.Validate(); err != nil {
+				if errs, ok := err.(cfg.ValidationErrors); ok {
+					t.print(errs[0].Message + "\n")
+				} else {
+					t.print(err.Error() + "\n")
+				}
+				continue
+			}
+			return "", true, nil
+		case "edit":
+			n, err := t.input(fmt.Sprintf("Setting to change (1-%d)", len(rows)), "", true)
+			if err != nil && err != errTextBack {
+				return "", false, err
+			}
+			if i, err := strconv.Atoi(n); err == nil && i >= 1 && i <= len(rows) {
+				return t.flow.getStepOf(rows[i-1].step), false, nil
+			}
+		case "save":
+			t.print(saveAnswersNote + "\n")
+			path, err := t.input("Save to", getDefaultAnswersPath(), false)
+			if err == errTextBack {
+				continue
+			} else if err != nil {
+				return "", false, err
+			}
+			data, err := getAnswers(c)
+			if err != nil {
+				return "", false, err
+			}
+			saved, err := saveAnswers(data, path)
+			if err != nil {
+				logrus.Errorf("failed to save answers: %v", err)
+				t.print(fmt.Sprintf("Failed to save answers: %v\n", err))
+				continue
+			}
+			t.print("Answers saved to " + saved + "\n")
+		case "no":
+			t.print("Installation halted. Rebooting system in 5 seconds\n")
+			return "", false, util.SleepAndReboot()
+		}
+	}
+}
+
+// installLogs are the messages of the last installation of the text console
+var installLogs = &installLog{}
+
+// install installs with the confirmed config, the progress and the messages
+// are printed. It reboots once installed.
+func (t *textConsole) install() error {
+	installLogs.reset()
+	printer := func(message string) {
+		installLogs.add(message)
+		t.print(message + "\n")
+	}
+	current := installProgress{Stage: -1}
+	// install a customized copy so that the entered config is kept for a retry
+	installConfig, err := copyConfig(&cfg.Config)
+	if err == nil {
+		err = customizeConfig(installConfig)
+	}
+	if err == nil {
+		err = doInstall(installConfig, printer, func(progress installProgress) {
+			if progress.Stage != current.Stage {
+				t.print(progress.Description() + "\n")
+			}
+			current = progress
+		})
+	}
+	if err != nil {
+		logrus.Errorf("installation failed: %v", err)
+		installLogs.add(err.Error())
+		t.print(getInstallFailureContent(current, err, installLogs.tail(installLogLines)))
+		return err
+	}
+	printer(getInstallSummary(installConfig, getManagementAddress(installConfig)))
+	printer(fmt.Sprintf("The system restarts in %d seconds", int(rebootTimeout.Seconds())))
+	time.Sleep(rebootTimeout)
+	return rebootAfterInstall(installConfig.K3OS.Install != nil && installConfig.K3OS.Install.PowerOff)
+}
+
+func (t *textConsole) title(title string) {
+	t.print("\n" + title + "\n")
+}
+
+// input asks for a line, the current value is kept by an empty line. Optional
+// values are cleared by textClear.
+func (t *textConsole) input(label, value string, optional bool) (string, error) {
+	text := label + ": "
+	if value != "" {
+		text = fmt.Sprintf("%s [%s]: ", label, value)
+	}
+	answer, err := t.prompt(text, value)
+	if err != nil {
+		return "", err
+	}
+	answer = strings.TrimSpace(answer)
+	switch {
+	case answer == textBack:
+		return "", errTextBack
+	case answer == textClear && optional:
+		return "", nil
+	}
+	return answer, nil
+}
+
+// password asks for a line which is not echoed
+func (t *textConsole) password(label string) (string, error) {
+	answer, err := t.promptPassword(label + ": ")
+	if err != nil {
+		return "", err
+	}
+	if answer == textBack {
+		return "", errTextBack
+	}
+	return answer, nil
+}
+
+// choose asks for one of the options of a step, it returns the value of the
+// answer. An option to go back is added unless the step is the first one.
+func (t *textConsole) choose(text string, options []widgets.Option, selected string) (string, error) {
+	return t.selectOption(text, options, selected, len(t.flow.history) > 0)
+}
+
+// selectOption asks for one of the options, the cursor is on selected
+func (t *textConsole) selectOption(text string, options []widgets.Option, selected string, back bool) (string, error) {
+	def := 0
+	var texts []string
+	for i, option := range options {
+		if option.Value == selected {
+			def = i
+		}
+		texts = append(texts, option.Text)
+	}
+	if back {
+		texts = append(texts, "Go back")
+	}
+	i, err := t.promptOptions(text, def, texts...)
+	if err != nil {
+		return "", err
+	}
+	if i >= len(options) {
+		return "", errTextBack
+	}
+	return options[i].Value, nil
+}
+
+// useAnyway asks whether to accept an answer failing a check
+func (t *textConsole) useAnyway(message string) (bool, error) {
+	t.print(message + "\n")
+	return t.promptBool("Use anyway?", false)
+}
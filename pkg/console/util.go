@@ -3,23 +3,62 @@ package console
 import (
 	"bufio"
 	"bytes"
+	crand "crypto/rand"
+	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/big"
+	"net"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/imdario/mergo"
 	"github.com/jroimartin/gocui"
+	"github.com/pkg/errors"
+	"github.com/rancher/harvester-installer/pkg/cluster"
 	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/rancher/harvester-installer/pkg/disk"
+	"github.com/rancher/harvester-installer/pkg/util"
 	"github.com/rancher/k3os/pkg/config"
+	"github.com/sirupsen/logrus"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
 	"k8s.io/apimachinery/pkg/util/rand"
 )
 
+var (
+	// default hostnames offered by the hostname panel, the first one is used
+	// when no hostname is configured
+	hostnameTemplates = []string{"harvester-{mac}", "harvester-{serial}"}
+	// placeholder values of vendors that didn't fill in the serial number
+	invalidSerials = []string{"", "0", "none", "default-string", "not-specified", "system-serial-number", "to-be-filled-by-o-e-m"}
+	// default values of the Harvester chart, the values of the install config
+	// are merged over them
+	harvesterChartValues = map[string]interface{}{
+		"minio.persistence.storageClass":                "longhorn",
+		"containers.apiserver.image.imagePullPolicy":    "IfNotPresent",
+		"harvester-network-controller.image.pullPolicy": "IfNotPresent",
+		"service.harvester.type":                        "LoadBalancer",
+		"containers.apiserver.authMode":                 "localUser",
+		"multus.enabled":                                true,
+		"longhorn.enabled":                              true,
+		// the disks of each node are chosen by its label, see getLonghornDiskLabel
+		"longhorn.defaultSettings.createDefaultDiskLabeledNodes": true,
+	}
+	// commands installing the installation target and formatting the data
+	// disks, they are replaced for testing
+	installScript = "/usr/libexec/k3os/install"
+	mkfsCommand   = "mkfs.ext4"
+)
+
 func getSSHKeysFromURL(url string) ([]string, error) {
 	client := http.Client{
 		Timeout: 15 * time.Second,
@@ -39,14 +78,15 @@ func getSSHKeysFromURL(url string) ([]string, error) {
 	return strings.Split(body, "\n"), nil
 }
 
+// getFormattedServerURL completes the management address to the URL of the
+// Kubernetes API, the default port 6443 is added if the address has no port
 func getFormattedServerURL(addr string) string {
-	if !strings.HasPrefix(addr, "https://") {
-		addr = "https://" + addr
-	}
-	if !strings.HasSuffix(addr, ":6443") {
-		addr = addr + ":6443"
+	host := strings.TrimSuffix(strings.TrimPrefix(addr, "https://"), "/")
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		// JoinHostPort adds the brackets of IPv6 addresses
+		host = net.JoinHostPort(strings.Trim(host, "[]"), "6443")
 	}
-	return addr
+	return "https://" + host
 }
 
 func getServerURLFromEnvData(data []byte) (string, error) {
@@ -86,62 +126,148 @@ func showNext(c *Console, names ...string) error {
 	return nil
 }
 
-func customizeConfig() {
+func encryptPassword(c *cfg.InstallConfig) error {
+	if c.K3OS.Password == "" || strings.HasPrefix(c.K3OS.Password, "$") {
+		return nil
+	}
+	encrypted, err := util.GetEncrptedPasswd(c.K3OS.Password)
+	if err != nil {
+		return err
+	}
+	c.K3OS.Password = encrypted
+	return nil
+}
+
+func customizeConfig(c *cfg.InstallConfig) error {
 	//common configs for both server and agent
-	cfg.Config.K3OS.DNSNameservers = []string{"8.8.8.8"}
-	cfg.Config.K3OS.NTPServers = []string{"ntp.ubuntu.com"}
-	cfg.Config.K3OS.Modules = []string{"kvm", "vhost_net"}
-	cfg.Config.Hostname = "harvester-" + rand.String(5)
+	c.K3OS.Modules = []string{"kvm", "vhost_net"}
+	if c.Hostname == "" {
+		c.Hostname = hostnameTemplates[0]
+	}
+	hostname, err := renderHostname(c.Hostname, c.Network.Interface)
+	if err != nil {
+		logrus.Warnf("failed to render hostname %q: %v", c.Hostname, err)
+		hostname = "harvester-" + rand.String(5)
+	}
+	c.Hostname = hostname
 
-	if cfg.Config.SSHKeyURL != "" {
-		cfg.Config.Runcmd = append(cfg.Config.Runcmd, fmt.Sprintf(`keys=$(curl -sfL --connect-timeout 30 %q) && echo "$keys">>%s`, cfg.Config.SSHKeyURL, authorizedFile))
+	if c.SSHKeyURL != "" {
+		c.Runcmd = append(c.Runcmd, fmt.Sprintf(`keys=$(curl -sfL --connect-timeout 30 %q) && echo "$keys">>%s`, c.SSHKeyURL, authorizedFile))
 	}
 
-	if cfg.Config.InstallMode == modeJoin {
-		cfg.Config.K3OS.K3sArgs = append([]string{"agent"}, cfg.Config.ExtraK3sArgs...)
-		return
+	if iface := c.Network.Interface; iface != "" && !hasFlannelIface(c.ExtraK3sArgs) {
+		c.ExtraK3sArgs = append(c.ExtraK3sArgs, "--flannel-iface", iface)
+	}
+	if c.Network.Method == networkMethodStatic {
+		var mac string
+		if iface, err := net.InterfaceByName(c.Network.Interface); err != nil {
+			logrus.Warnf("failed to get hardware address of %q: %v", c.Network.Interface, err)
+		} else {
+			mac = iface.HardwareAddr.String()
+		}
+		c.WriteFiles = append(c.WriteFiles, config.File{
+			Owner:              "root",
+			Path:               connmanConfigFile,
+			RawFilePermissions: "0644",
+			Content:            getConnmanConfigContent(c.Network, mac, c.K3OS.DNSNameservers),
+		})
 	}
 
-	var harvesterChartValues = map[string]string{
-		"minio.persistence.storageClass":                "longhorn",
-		"containers.apiserver.image.imagePullPolicy":    "IfNotPresent",
-		"harvester-network-controller.image.pullPolicy": "IfNotPresent",
-		"service.harvester.type":                        "LoadBalancer",
-		"containers.apiserver.authMode":                 "localUser",
-		"multus.enabled":                                "true",
-		"longhorn.enabled":                              "true",
+	c.Bootcmd = append(c.Bootcmd, getDataDiskBootCmds(len(c.DataDisks))...)
+	c.ExtraK3sArgs = append(c.ExtraK3sArgs, "--node-label", getLonghornDiskLabel(len(c.DataDisks)))
+	if len(c.DataDisks) > 0 {
+		annotateCmd, err := getDataDiskAnnotateCmd(len(c.DataDisks))
+		if err != nil {
+			return err
+		}
+		c.Runcmd = append(c.Runcmd, annotateCmd)
 	}
+	completeProxies(c)
 
-	cfg.Config.WriteFiles = []config.File{
-		{
+	if c.InstallMode == modeJoin {
+		c.K3OS.K3sArgs = append([]string{"agent"}, c.ExtraK3sArgs...)
+		return nil
+	}
+
+	if err := checkBundledChart(c.Chart, isoMountPoint); err != nil {
+		return err
+	}
+	manifest, err := getHarvesterManifestContent(c.Chart, len(c.DataDisks))
+	if err != nil {
+		return err
+	}
+	c.WriteFiles = append(c.WriteFiles, config.File{
+		Owner:              "root",
+		Path:               harvesterManifestFile,
+		RawFilePermissions: "0600",
+		Content:            manifest,
+	})
+	for i, addon := range c.Addons {
+		content, err := getAddonManifest(addon, isoMountPoint)
+		if err != nil {
+			return err
+		}
+		name := addon.Name
+		if name == "" {
+			name = defaultAddonName
+		}
+		c.WriteFiles = append(c.WriteFiles, config.File{
 			Owner:              "root",
-			Path:               "/var/lib/rancher/k3s/server/manifests/harvester.yaml",
+			Path:               fmt.Sprintf(addonManifestFile, i+1, name),
 			RawFilePermissions: "0600",
-			Content:            getHarvesterManifestContent(harvesterChartValues),
-		},
+			Content:            string(content),
+		})
 	}
-	cfg.Config.K3OS.K3sArgs = append([]string{
+	c.K3OS.K3sArgs = append([]string{
 		"server",
 		"--disable",
 		"local-storage",
 		"--node-label",
 		"svccontroller.k3s.cattle.io/enablelb=true",
-	}, cfg.Config.ExtraK3sArgs...)
+	}, c.ExtraK3sArgs...)
+	return nil
 }
 
-func doInstall(g *gocui.Gui) error {
+// doInstall runs the install script, log lines are passed to printer and the
+// progress events of the script to reporter
+func doInstall(c *cfg.InstallConfig, printer func(string), reporter func(installProgress)) error {
 	var (
 		err      error
 		tempFile *os.File
 	)
 
-	if cfg.Config.K3OS.Install.ConfigURL != "" {
-		remoteConfig, err := getRemoteCloudConfig(cfg.Config.K3OS.Install.ConfigURL)
+	if c.K3OS.Install.ConfigURL != "" {
+		remoteConfig, err := getRemoteCloudConfig(c.K3OS.Install.ConfigURL)
+		if err != nil {
+			printer(err.Error())
+		} else if err := mergeCloudConfig(&c.CloudConfig, remoteConfig); err != nil {
+			printer(err.Error())
+		}
+	}
+	if len(c.K3OS.DNSNameservers) == 0 {
+		c.K3OS.DNSNameservers = []string{defaultDNSServer}
+	}
+	if len(c.K3OS.NTPServers) == 0 {
+		c.K3OS.NTPServers = []string{defaultNTPServer}
+	}
+
+	// device names might have changed since the devices were chosen, e.g. when a
+	// USB stick is plugged in, so resolve the stable paths right before formatting
+	installDevice, err := resolveDevice(c.K3OS.Install.Device)
+	if err != nil {
+		return err
+	}
+	printer(fmt.Sprintf("Installation target %s is %s", c.K3OS.Install.Device, installDevice))
+	var dataDisks []string
+	for _, d := range c.DataDisks {
+		dataDisk, err := resolveDevice(d)
 		if err != nil {
-			printToInstallPanel(g, err.Error())
-		} else if err := mergo.Merge(&cfg.Config.CloudConfig, remoteConfig, mergo.WithAppendSlice); err != nil {
-			printToInstallPanel(g, err.Error())
+			return err
 		}
+		if dataDisk == installDevice {
+			return fmt.Errorf("data disk %s is the installation target %s", d, installDevice)
+		}
+		dataDisks = append(dataDisks, dataDisk)
 	}
 
 	tempFile, err = ioutil.TempFile("/tmp", "k3os.XXXXXXXX")
@@ -149,15 +275,18 @@ func doInstall(g *gocui.Gui) error {
 		return err
 	}
 	defer tempFile.Close()
-	cfg.Config.K3OS.Install.ConfigURL = tempFile.Name()
+	c.K3OS.Install.ConfigURL = tempFile.Name()
 
-	ev, err := config.ToEnv(cfg.Config.CloudConfig)
+	ev, err := config.ToEnv(c.CloudConfig)
 	if err != nil {
 		return err
 	}
+	// the install script derives partition names from the kernel name of the device,
+	// and it doesn't reboot when interactive so that the result can be shown
+	ev = append(ev, "K3OS_INSTALL_DEVICE="+installDevice, "INTERACTIVE=true")
 	if tempFile != nil {
-		cfg.Config.K3OS.Install = nil
-		bytes, err := yaml.Marshal(&cfg.Config.CloudConfig)
+		c.K3OS.Install = nil
+		bytes, err := yaml.Marshal(&c.CloudConfig)
 		if err != nil {
 			return err
 		}
@@ -169,8 +298,15 @@ func doInstall(g *gocui.Gui) error {
 		}
 		defer os.Remove(tempFile.Name())
 	}
-	cmd := exec.Command("/usr/libexec/k3os/install")
-	cmd.Env = append(os.Environ(), ev...)
+	return runInstallScript(ev, dataDisks, printer, reporter)
+}
+
+// runInstallScript installs the installation target with the install script,
+// the data disks are formatted once it succeeded so that a failed installation
+// and its retries leave them alone
+func runInstallScript(env []string, dataDisks []string, printer func(string), reporter func(installProgress)) error {
+	cmd := exec.Command(installScript)
+	cmd.Env = append(os.Environ(), env...)
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		return err
@@ -183,17 +319,76 @@ func doInstall(g *gocui.Gui) error {
 		return err
 	}
 
-	scanner := bufio.NewScanner(stdout)
-	scanner.Split(bufio.ScanLines)
-	for scanner.Scan() {
-		printToInstallPanel(g, scanner.Text())
+	// read both pipes at once, the script blocks if either of them fills up
+	lines := make(chan string)
+	var wg sync.WaitGroup
+	for _, r := range []io.Reader{stdout, stderr} {
+		wg.Add(1)
+		go func(r io.Reader) {
+			defer wg.Done()
+			readLines(r, lines)
+		}(r)
+	}
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+
+	tracker := newProgressTracker()
+	for line := range lines {
+		if progress, ok := tracker.parse(line); ok {
+			reporter(progress)
+			continue
+		}
+		printer(line)
 	}
-	scanner = bufio.NewScanner(stderr)
-	scanner.Split(bufio.ScanLines)
+	if err := cmd.Wait(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return errors.Errorf("install script exited with code %d", exitErr.ExitCode())
+		}
+		return err
+	}
+	return formatDataDisks(dataDisks, printer)
+}
+
+// readLines sends the lines read from r to lines. The rest of the input is
+// discarded when a line can't be read so that the writer never blocks.
+func readLines(r io.Reader, lines chan<- string) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 	for scanner.Scan() {
-		printToInstallPanel(g, scanner.Text())
+		lines <- scanner.Text()
+	}
+	if err := scanner.Err(); err != nil {
+		lines <- fmt.Sprintf("failed to read install output: %v", err)
+		io.Copy(ioutil.Discard, r)
 	}
-	return nil
+}
+
+// copyConfig returns a deep copy of c, the installation customizes the copy
+// so that it can be retried with the config entered by the user
+func copyConfig(c *cfg.InstallConfig) (*cfg.InstallConfig, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	result := &cfg.InstallConfig{}
+	if err := json.Unmarshal(b, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// mergeCloudConfig merges the remote cloud-config into dst. Lists are appended,
+// except for DNS and NTP servers which are replaced by the remote ones.
+func mergeCloudConfig(dst *config.CloudConfig, remote *config.CloudConfig) error {
+	if len(remote.K3OS.DNSNameservers) > 0 {
+		dst.K3OS.DNSNameservers = nil
+	}
+	if len(remote.K3OS.NTPServers) > 0 {
+		dst.K3OS.NTPServers = nil
+	}
+	return mergo.Merge(dst, remote, mergo.WithAppendSlice)
 }
 
 func printToInstallPanel(g *gocui.Gui, message string) {
@@ -202,19 +397,37 @@ func printToInstallPanel(g *gocui.Gui, message string) {
 		if err != nil {
 			return err
 		}
+		// keep following the log unless it's scrolled up
+		_, sy := v.Size()
+		_, oy := v.Origin()
+		following := len(v.BufferLines()) <= oy+sy+1
 		fmt.Fprintln(v, message)
 
-		lines := len(v.BufferLines())
-		_, sy := v.Size()
-		if lines > sy {
-			ox, oy := v.Origin()
-			v.SetOrigin(ox, oy+1)
+		if lines := len(v.BufferLines()); following && lines > sy {
+			ox, _ := v.Origin()
+			return v.SetOrigin(ox, lines-sy)
 		}
 		return nil
 	})
 }
 
 func getRemoteCloudConfig(configURL string) (*config.CloudConfig, error) {
+	b, err := getRemoteConfig(configURL)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.ToCloudConfig(b)
+}
+
+func getRemoteInstallConfig(configURL string) (*cfg.InstallConfig, error) {
+	b, err := getRemoteConfig(configURL)
+	if err != nil {
+		return nil, err
+	}
+	return cfg.ToInstallConfig(b)
+}
+
+func getRemoteConfig(configURL string) ([]byte, error) {
 	client := http.Client{
 		Timeout: 15 * time.Second,
 	}
@@ -222,6 +435,7 @@ func getRemoteCloudConfig(configURL string) (*config.CloudConfig, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 	b, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
@@ -229,29 +443,531 @@ func getRemoteCloudConfig(configURL string) (*config.CloudConfig, error) {
 	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
 		return nil, fmt.Errorf("got %d status code from %s, body: %s", resp.StatusCode, configURL, string(b))
 	}
-	return cfg.ToCloudConfig(b)
+	return b, nil
 }
 
-func getHarvesterManifestContent(values map[string]string) string {
-	base := `apiVersion: v1
-kind: Namespace
-metadata:
-  name: harvester-system
----
-apiVersion: helm.cattle.io/v1
-kind: HelmChart
-metadata:
-  name: harvester
-  namespace: kube-system
-spec:
-  chart: https://%{KUBERNETES_API}%/static/charts/harvester-0.1.0.tgz
-  targetNamespace: harvester-system
-  set:
-`
+// getAddonManifest returns the manifest of an addon from its source, a path is
+// read relative to isoRoot. The manifest is checked to be Kubernetes YAML.
+func getAddonManifest(addon cfg.Addon, isoRoot string) ([]byte, error) {
+	var (
+		content []byte
+		err     error
+	)
+	switch {
+	case addon.URL != "":
+		content, err = getRemoteConfig(addon.URL)
+	case addon.Path != "":
+		content, err = ioutil.ReadFile(filepath.Join(isoRoot, filepath.Clean("/"+addon.Path)))
+	default:
+		content = []byte(addon.Content)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get addon %s", addon.Name)
+	}
+	if err := cfg.ValidateManifest(content); err != nil {
+		return nil, errors.Wrapf(err, "invalid manifest of addon %s", addon.Name)
+	}
+	return content, nil
+}
+
+// describeAddon returns the name and the source of an addon
+func describeAddon(addon cfg.Addon) string {
+	name := addon.Name
+	if name == "" {
+		name = defaultAddonName
+	}
+	switch {
+	case addon.URL != "":
+		return fmt.Sprintf("%s (%s)", name, addon.URL)
+	case addon.Path != "":
+		return fmt.Sprintf("%s (%s on the installer media)", name, addon.Path)
+	}
+	return fmt.Sprintf("%s (inline)", name)
+}
+
+// parseDNSServers parses a comma separated list of DNS server addresses
+func parseDNSServers(data string) ([]string, error) {
+	servers := splitList(data)
+	if err := cfg.ValidateDNSServers(servers); err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+// parseNTPServers parses a comma separated list of NTP server addresses or domain names
+func parseNTPServers(data string) ([]string, error) {
+	servers := splitList(data)
+	if err := cfg.ValidateNTPServers(servers); err != nil {
+		return nil, err
+	}
+	return servers, nil
+}
+
+func splitList(data string) []string {
+	var items []string
+	for _, item := range strings.Split(data, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// checkServers returns an error listing the servers for which check fails
+func checkServers(servers []string, check func(string) error) error {
+	var unreachable []string
+	for _, server := range servers {
+		if err := check(server); err != nil {
+			logrus.Warnf("failed to reach %s: %v", server, err)
+			unreachable = append(unreachable, server)
+		}
+	}
+	if len(unreachable) > 0 {
+		return fmt.Errorf("No response from %s", strings.Join(unreachable, ", "))
+	}
+	return nil
+}
+
+// generateToken returns a random cluster token of letters and digits
+func generateToken() (string, error) {
+	for {
+		token, err := generateRandomString(generatedTokenLength)
+		if err != nil {
+			return "", err
+		}
+		// rarely a token lacks either letters or digits
+		if cfg.ValidateToken(cfg.ModeCreate, token) == nil {
+			return token, nil
+		}
+	}
+}
+
+// generateRandomString returns a random string of letters and digits
+func generateRandomString(length int) (string, error) {
+	const chars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	b := make([]byte, length)
+	for i := range b {
+		n, err := crand.Int(crand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = chars[n.Int64()]
+	}
+	return string(b), nil
+}
+
+// getManagementAddress returns the address of the management interface, the
+// configured one with static network or the current one otherwise
+func getManagementAddress(c *cfg.InstallConfig) string {
+	if c.Network.Method == networkMethodStatic {
+		if ip, _, err := net.ParseCIDR(c.Network.IP); err == nil {
+			return ip.String()
+		}
+	}
+	if iface, err := net.InterfaceByName(c.Network.Interface); err == nil {
+		if addrs, err := iface.Addrs(); err == nil {
+			for _, addr := range addrs {
+				if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() != nil {
+					return ipNet.IP.String()
+				}
+			}
+		}
+	}
+	if ip, err := utilnet.ChooseHostInterface(); err == nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// getJoinSummary tells how to add nodes to the cluster at address
+func getJoinSummary(address, token string) string {
+	if address == "" {
+		address = "<unavailable>"
+	}
+	return fmt.Sprintf("Management URL: https://%s:8443\n"+
+		"Cluster token: %s\n\n"+
+		"To add a node, install Harvester in join mode with management address\n%s and the cluster token above.", address, token, address)
+}
+
+// getInstallSummary returns the message shown when the installation succeeded
+func getInstallSummary(c *cfg.InstallConfig, address string) string {
+	summary := "Installation completed"
+	if c.InstallMode == modeCreate {
+		summary += "\n\n" + getJoinSummary(address, c.K3OS.Token)
+	}
+	return summary
+}
+
+// rebootAfterInstall reboots or powers off the host as configured
+func rebootAfterInstall(powerOff bool) error {
+	if data, err := ioutil.ReadFile("/proc/cmdline"); err == nil && strings.Contains(string(data), "k3os.install.power_off=true") {
+		powerOff = true
+	}
+	if powerOff {
+		return exec.Command("poweroff", "-f").Run()
+	}
+	return exec.Command("reboot", "-f").Run()
+}
+
+// renderHostname replaces {mac} and {serial} in the hostname template with the
+// hardware address of iface and the product serial number of the machine
+func renderHostname(template string, iface string) (string, error) {
+	values := map[string]func() (string, error){
+		"{mac}": func() (string, error) {
+			return getHardwareAddr(iface)
+		},
+		"{serial}": func() (string, error) {
+			return getProductSerial(dmiProductSerialFile)
+		},
+	}
+	hostname := template
+	for placeholder, getValue := range values {
+		if !strings.Contains(hostname, placeholder) {
+			continue
+		}
+		value, err := getValue()
+		if err != nil {
+			return "", err
+		}
+		hostname = strings.Replace(hostname, placeholder, value, -1)
+	}
+	return hostname, nil
+}
+
+// getHardwareAddr returns the hardware address of iface without separators,
+// the first interface with a hardware address is used if iface is empty
+func getHardwareAddr(iface string) (string, error) {
+	var mac net.HardwareAddr
+	if iface != "" {
+		i, err := net.InterfaceByName(iface)
+		if err != nil {
+			return "", err
+		}
+		mac = i.HardwareAddr
+	} else {
+		ifaces, err := net.Interfaces()
+		if err != nil {
+			return "", err
+		}
+		for _, i := range ifaces {
+			if i.Flags&net.FlagLoopback == 0 && len(i.HardwareAddr) > 0 {
+				mac = i.HardwareAddr
+				break
+			}
+		}
+	}
+	if len(mac) == 0 {
+		return "", fmt.Errorf("no hardware address found for interface %q", iface)
+	}
+	return strings.Replace(mac.String(), ":", "", -1), nil
+}
+
+// getProductSerial reads the product serial number from the DMI file and
+// sanitizes it for use in a hostname
+func getProductSerial(file string) (string, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	serial := sanitizeHostname(string(data))
+	for _, invalid := range invalidSerials {
+		if serial == invalid {
+			return "", fmt.Errorf("no serial number found in %s", file)
+		}
+	}
+	return serial, nil
+}
+
+// sanitizeHostname lowercases s and replaces characters not allowed in a
+// hostname with '-'
+func sanitizeHostname(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('-')
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+// getHostnameOptions returns the rendered hostname templates, the ones that
+// can't be rendered on this machine are skipped
+func getHostnameOptions(iface string) []string {
+	var options []string
+	for _, template := range hostnameTemplates {
+		hostname, err := renderHostname(template, iface)
+		if err != nil {
+			logrus.Debugf("skip hostname template %q: %v", template, err)
+			continue
+		}
+		options = append(options, hostname)
+	}
+	return options
+}
+
+// getJoinCheckMessage returns the message shown for an error of checking the
+// join target, warnings are errors the user may choose to ignore
+func getJoinCheckMessage(err error) (string, bool) {
+	var (
+		unreachableErr *cluster.UnreachableError
+		skewErr        *cluster.ClockSkewError
+	)
+	switch {
+	case errors.As(err, &unreachableErr):
+		return fmt.Sprintf("Unable to reach the management address %s", unreachableErr.URL), true
+	case errors.As(err, &skewErr):
+		return fmt.Sprintf("The clock of this host differs from the server by %s", skewErr.Skew.Round(time.Second)), true
+	case err == cluster.ErrCAMismatch:
+		return "The CA of the server doesn't match the cluster token", false
+	case err == cluster.ErrInvalidToken:
+		return "The cluster token is rejected by the server", false
+	}
+	return fmt.Sprintf("Failed to check the management address: %v", err), false
+}
+
+// checkClusterHostname checks the hostname is not used by another node of the
+// cluster to join, it returns the message shown when it is. The message is a
+// warning when the nodes couldn't be listed.
+func checkClusterHostname(c *cfg.InstallConfig, hostname string) (string, bool) {
+	names, err := cluster.NewChecker().GetNodeNames(c.K3OS.ServerURL, c.K3OS.Token)
+	if err != nil {
+		logrus.Warnf("failed to list cluster nodes: %v", err)
+		return "Unable to check the hostname against cluster nodes", true
+	}
+	for _, name := range names {
+		if name == hostname {
+			return fmt.Sprintf("Hostname %q is used by another node of the cluster", hostname), false
+		}
+	}
+	return "", false
+}
+
+// removeString returns a copy of slice without s
+func removeString(slice []string, s string) []string {
+	var result []string
+	for _, item := range slice {
+		if item != s {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// resolveDevice returns the kernel path of the disk a stable path points to. It
+// fails if the path doesn't point to a whole disk or points to the installer media.
+func resolveDevice(path string) (string, error) {
+	d, err := disk.NewScanner().Resolve(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve device %s", path)
+	}
+	if d.IsInstaller() {
+		return "", fmt.Errorf("device %s is the installer media", path)
+	}
+	return d.Path, nil
+}
+
+// describeDevice shows the stable path of a device with the disk it currently points to
+func describeDevice(path string) string {
+	d, err := disk.NewScanner().Resolve(path)
+	if err != nil || d.Path == path {
+		return path
+	}
+	return fmt.Sprintf("%s (%s)", path, d.Path)
+}
+
+func getDataDiskLabel(index int) string {
+	return fmt.Sprintf("%s%d", dataDiskLabelPrefix, index)
+}
+
+func getDataDiskMountPath(index int) string {
+	if index == 0 {
+		return longhornDataPath
+	}
+	return fmt.Sprintf("%s/%s", extraDisksDir, strings.ToLower(getDataDiskLabel(index)))
+}
+
+// getDataDiskBootCmds returns the commands mounting the data disks by label on
+// every boot, device names might change between boots
+func getDataDiskBootCmds(count int) []string {
+	var cmds []string
+	for i := 0; i < count; i++ {
+		path := getDataDiskMountPath(i)
+		cmds = append(cmds, fmt.Sprintf(`mkdir -p %[1]s && (mountpoint -q %[1]s || mount "$(blkid -L %[2]s)" %[1]s)`, path, getDataDiskLabel(i)))
+	}
+	return cmds
+}
+
+// getLonghornDiskLabel returns the node label telling Longhorn where to create
+// the default disks of the node, at the default data path without data disks,
+// otherwise on the data disks listed by the annotation of getDataDiskAnnotateCmd
+func getLonghornDiskLabel(count int) string {
+	if count == 0 {
+		return longhornDefaultDiskLabel + "=true"
+	}
+	return longhornDefaultDiskLabel + "=config"
+}
+
+// getDataDiskAnnotateCmd returns the command registering the data disks with
+// Longhorn by annotating the node, it waits in the background for the node to
+// be registered by k3s. The credentials of the kubelet allow to annotate the
+// node on both servers and agents.
+func getDataDiskAnnotateCmd(count int) (string, error) {
+	var disks []map[string]interface{}
+	for i := 0; i < count; i++ {
+		disks = append(disks, map[string]interface{}{
+			"path":            getDataDiskMountPath(i),
+			"allowScheduling": true,
+		})
+	}
+	disksConfig, err := json.Marshal(disks)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`(until k3s kubectl --kubeconfig %s annotate --overwrite node "$(hostname)" %s='%s'; do sleep 5; done) >/dev/null 2>&1 &`,
+		kubeletKubeconfigFile, longhornDefaultDisksAnnotation, disksConfig), nil
+}
+
+// formatDataDisks creates an ext4 file system labeled by the index on each data disk
+func formatDataDisks(dataDisks []string, printer func(string)) error {
+	for i, d := range dataDisks {
+		printer(fmt.Sprintf("Formatting data disk %s", d))
+		output, err := exec.Command(mkfsCommand, "-F", "-L", getDataDiskLabel(i), d).CombinedOutput()
+		if err != nil {
+			return errors.Wrapf(err, "failed to format %s: %s", d, output)
+		}
+	}
+	return nil
+}
+
+// setRegistryMirror sets the endpoint of the mirror of a registry, the mirror is
+// removed when endpoint is empty
+func setRegistryMirror(c *cfg.InstallConfig, name, endpoint string) {
+	if endpoint == "" {
+		if r := c.K3OS.Registries; r != nil {
+			delete(r.Mirrors, name)
+			if len(r.Mirrors) == 0 && len(r.Configs) == 0 {
+				c.K3OS.Registries = nil
+			}
+		}
+		return
+	}
+	if c.K3OS.Registries == nil {
+		c.K3OS.Registries = &config.Registries{}
+	}
+	if c.K3OS.Registries.Mirrors == nil {
+		c.K3OS.Registries.Mirrors = make(map[string]config.Mirror)
+	}
+	c.K3OS.Registries.Mirrors[name] = config.Mirror{Endpoints: []string{endpoint}}
+}
+
+// getRegistryMirrorsContent lists the registry mirrors for the confirm panel
+func getRegistryMirrorsContent(r *config.Registries) string {
+	if r == nil {
+		return ""
+	}
+	var names []string
+	for name := range r.Mirrors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var content string
+	for _, name := range names {
+		content += fmt.Sprintf("registry mirror: %s -> %s\n", name, strings.Join(r.Mirrors[name].Endpoints, ", "))
+	}
+	return content
+}
+
+// getConnmanConfigContent renders a connman service config with the static
+// address of the management interface
+func getConnmanConfigContent(network cfg.Network, mac string, nameservers []string) string {
 	var buffer = bytes.Buffer{}
-	buffer.WriteString(base)
-	for k, v := range values {
-		buffer.WriteString(fmt.Sprintf("    %s: %q\n", k, v))
+	buffer.WriteString("[service_harvester_management]\n")
+	buffer.WriteString("Type=ethernet\n")
+	if mac != "" {
+		buffer.WriteString(fmt.Sprintf("MAC=%s\n", mac))
+	}
+	if ip, ipNet, err := net.ParseCIDR(network.IP); err == nil {
+		ones, _ := ipNet.Mask.Size()
+		buffer.WriteString(fmt.Sprintf("IPv4=%s/%d/%s\n", ip.String(), ones, network.Gateway))
+	}
+	if len(nameservers) > 0 {
+		buffer.WriteString(fmt.Sprintf("Nameservers=%s\n", strings.Join(nameservers, ",")))
 	}
 	return buffer.String()
 }
+
+// getHarvesterManifestContent renders the HelmChart of Harvester with the chart
+// values merged over the default ones. Keys are sorted, so the output is stable.
+// Longhorn stores its data on the first data disk if there are dataDisks.
+// checkBundledChart checks the chart of a version configured without a URL is
+// bundled on the installer media, since k3s serves it from there once installed
+func checkBundledChart(chart *cfg.Chart, isoRoot string) error {
+	if chart == nil || chart.URL != "" || chart.Version == "" {
+		return nil
+	}
+	_, err := os.Stat(filepath.Join(isoRoot, fmt.Sprintf(harvesterBundledChartFile, chart.Version)))
+	if os.IsNotExist(err) {
+		return fmt.Errorf("Harvester chart %s is not bundled with the installer, set the chart URL to install it", chart.Version)
+	}
+	return errors.Wrapf(err, "failed to check the bundled Harvester chart %s", chart.Version)
+}
+
+func getHarvesterManifestContent(chart *cfg.Chart, dataDisks int) (string, error) {
+	if chart == nil {
+		chart = &cfg.Chart{}
+	}
+	chartValues := map[string]interface{}{}
+	for k, v := range harvesterChartValues {
+		chartValues[k] = v
+	}
+	if dataDisks > 0 {
+		chartValues["longhorn.defaultSettings.defaultDataPath"] = longhornDataPath
+	}
+	defaults, err := cfg.ExpandValues(chartValues)
+	if err != nil {
+		return "", err
+	}
+	values, err := cfg.ExpandValues(chart.Values)
+	if err != nil {
+		return "", err
+	}
+	valuesContent, err := yaml.Marshal(cfg.MergeValues(defaults, values))
+	if err != nil {
+		return "", err
+	}
+
+	spec := map[string]interface{}{
+		"chart":           harvesterChartURL,
+		"targetNamespace": harvesterNamespace,
+		"valuesContent":   string(valuesContent),
+	}
+	if chart.Version != "" {
+		spec["chart"] = fmt.Sprintf(harvesterBundledChartURL, chart.Version)
+	}
+	if chart.URL != "" {
+		spec["chart"] = chart.URL
+		if chart.Version != "" {
+			spec["version"] = chart.Version
+		}
+	}
+	helmChart, err := yaml.Marshal(map[string]interface{}{
+		"apiVersion": "helm.cattle.io/v1",
+		"kind":       "HelmChart",
+		"metadata": map[string]interface{}{
+			"name":      "harvester",
+			"namespace": "kube-system",
+		},
+		"spec": spec,
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`apiVersion: v1
+kind: Namespace
+metadata:
+  name: %s
+---
+%s`, harvesterNamespace, helmChart), nil
+}
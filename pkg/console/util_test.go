@@ -1,19 +1,186 @@
 package console
 
 import (
+	"fmt"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/rancher/harvester-installer/pkg/cluster"
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/rancher/harvester-installer/pkg/disk"
+	"github.com/rancher/harvester-installer/pkg/widgets"
+	"github.com/rancher/k3os/pkg/config"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestGetHarvesterManifestContent(t *testing.T) {
-	d := map[string]string{
-		"a": "b",
-		"b": "\"c\"",
+	testCases := []struct {
+		name      string
+		chart     *cfg.Chart
+		dataDisks int
+		expected  string
+		err       bool
+	}{
+		{
+			name: "defaults",
+			expected: `apiVersion: v1
+kind: Namespace
+metadata:
+  name: harvester-system
+---
+apiVersion: helm.cattle.io/v1
+kind: HelmChart
+metadata:
+  name: harvester
+  namespace: kube-system
+spec:
+  chart: https://%{KUBERNETES_API}%/static/charts/harvester-0.1.0.tgz
+  targetNamespace: harvester-system
+  valuesContent: |
+    containers:
+      apiserver:
+        authMode: localUser
+        image:
+          imagePullPolicy: IfNotPresent
+    harvester-network-controller:
+      image:
+        pullPolicy: IfNotPresent
+    longhorn:
+      defaultSettings:
+        createDefaultDiskLabeledNodes: true
+      enabled: true
+    minio:
+      persistence:
+        storageClass: longhorn
+    multus:
+      enabled: true
+    service:
+      harvester:
+        type: LoadBalancer
+`,
+		},
+		{
+			name: "values merged over the defaults with data disks",
+			chart: &cfg.Chart{
+				Version: "0.2.0",
+				Values: map[string]interface{}{
+					"longhorn.defaultSettings.defaultReplicaCount": 2,
+					"multus":                        map[string]interface{}{"enabled": false},
+					"service.harvester.annotations": map[string]interface{}{`metallb\.universe\.tf/address-pool`: "default"},
+					"containers": map[string]interface{}{
+						"apiserver.image.tag": "master-head",
+					},
+				},
+			},
+			dataDisks: 2,
+			expected: `apiVersion: v1
+kind: Namespace
+metadata:
+  name: harvester-system
+---
+apiVersion: helm.cattle.io/v1
+kind: HelmChart
+metadata:
+  name: harvester
+  namespace: kube-system
+spec:
+  chart: https://%{KUBERNETES_API}%/static/charts/harvester-0.2.0.tgz
+  targetNamespace: harvester-system
+  valuesContent: |
+    containers:
+      apiserver:
+        authMode: localUser
+        image:
+          imagePullPolicy: IfNotPresent
+          tag: master-head
+    harvester-network-controller:
+      image:
+        pullPolicy: IfNotPresent
+    longhorn:
+      defaultSettings:
+        createDefaultDiskLabeledNodes: true
+        defaultDataPath: /var/lib/harvester/defaultdisk
+        defaultReplicaCount: 2
+      enabled: true
+    minio:
+      persistence:
+        storageClass: longhorn
+    multus:
+      enabled: false
+    service:
+      harvester:
+        annotations:
+          metallb.universe.tf/address-pool: default
+        type: LoadBalancer
+`,
+		},
+		{
+			name: "chart URL",
+			chart: &cfg.Chart{
+				URL:     "https://charts.example.com/harvester-0.2.0-dev.tgz",
+				Version: "0.2.0-dev",
+				Values:  map[string]interface{}{"longhorn": "disabled"},
+			},
+			expected: `apiVersion: v1
+kind: Namespace
+metadata:
+  name: harvester-system
+---
+apiVersion: helm.cattle.io/v1
+kind: HelmChart
+metadata:
+  name: harvester
+  namespace: kube-system
+spec:
+  chart: https://charts.example.com/harvester-0.2.0-dev.tgz
+  targetNamespace: harvester-system
+  valuesContent: |
+    containers:
+      apiserver:
+        authMode: localUser
+        image:
+          imagePullPolicy: IfNotPresent
+    harvester-network-controller:
+      image:
+        pullPolicy: IfNotPresent
+    longhorn: disabled
+    minio:
+      persistence:
+        storageClass: longhorn
+    multus:
+      enabled: true
+    service:
+      harvester:
+        type: LoadBalancer
+  version: 0.2.0-dev
+`,
+		},
+		{
+			name: "conflicting values",
+			chart: &cfg.Chart{
+				Values: map[string]interface{}{"multus": true, "multus.enabled": false},
+			},
+			err: true,
+		},
+	}
+	for _, testCase := range testCases {
+		content, err := getHarvesterManifestContent(testCase.chart, testCase.dataDisks)
+		if testCase.err {
+			assert.NotNil(t, err, testCase.name)
+			continue
+		}
+		assert.Nil(t, err, testCase.name)
+		assert.Equal(t, testCase.expected, content, testCase.name)
 	}
-	res := getHarvesterManifestContent(d)
-	t.Log(res)
 }
 
 func TestGetHStatus(t *testing.T) {
@@ -25,27 +192,73 @@ func TestGetFormattedServerURL(t *testing.T) {
 	testCases := []struct {
 		Name   string
 		input  string
+		valid  bool
 		output string
 	}{
 		{
 			Name:   "ip",
 			input:  "1.2.3.4",
+			valid:  true,
 			output: "https://1.2.3.4:6443",
 		},
 		{
 			Name:   "domain name",
 			input:  "example.org",
+			valid:  true,
 			output: "https://example.org:6443",
 		},
 		{
 			Name:   "full",
 			input:  "https://1.2.3.4:6443",
+			valid:  true,
 			output: "https://1.2.3.4:6443",
 		},
+		{
+			Name:   "trailing slash",
+			input:  "https://example.org/",
+			valid:  true,
+			output: "https://example.org:6443",
+		},
+		{
+			Name:   "other port",
+			input:  "example.org:8443",
+			valid:  true,
+			output: "https://example.org:8443",
+		},
+		{
+			Name:   "ipv6",
+			input:  "fd00::1",
+			valid:  true,
+			output: "https://[fd00::1]:6443",
+		},
+		{
+			Name:   "ipv6 in brackets",
+			input:  "https://[fd00::1]",
+			valid:  true,
+			output: "https://[fd00::1]:6443",
+		},
+		{
+			Name:   "ipv6 with port",
+			input:  "[fd00::1]:8443",
+			valid:  true,
+			output: "https://[fd00::1]:8443",
+		},
+		{
+			Name:  "http",
+			input: "http://1.2.3.4",
+		},
+		{
+			Name:  "invalid port",
+			input: "1.2.3.4:99999",
+		},
 	}
 	for _, testCase := range testCases {
-		got := getFormattedServerURL(testCase.input)
-		assert.Equal(t, testCase.output, got)
+		// addresses are only formatted once validated
+		err := cfg.ValidateServerURL(testCase.input)
+		assert.Equal(t, testCase.valid, err == nil, testCase.Name)
+		if err == nil {
+			assert.Equal(t, testCase.output, getFormattedServerURL(testCase.input), testCase.Name)
+		}
 	}
 }
 
@@ -86,3 +299,660 @@ func TestGetServerURLFromEnvData(t *testing.T) {
 		assert.Equal(t, testCase.err, err)
 	}
 }
+
+func TestGetPreseededPanels(t *testing.T) {
+	c := &cfg.InstallConfig{
+		Harvester: cfg.Harvester{
+			InstallMode:  modeJoin,
+			ExtraK3sArgs: []string{"--flannel-iface", "eth0"},
+		},
+	}
+	c.K3OS.ServerURL = "https://1.2.3.4:6443"
+	c.K3OS.Token = "token"
+	c.K3OS.Environment = map[string]string{"http_proxy": "http://proxy:3128"}
+
+	expected := map[string]bool{
+		askCreatePanel:     true,
+		diskPanel:          false,
+		serverURLPanel:     true,
+		tokenPanel:         true,
+		passwordPanel:      false,
+		sshKeyPanel:        false,
+		networkPanel:       true,
+		networkMethodPanel: false,
+		hostnamePanel:      false,
+		proxyPanel:         true,
+		proxyUserPanel:     true,
+		noProxyPanel:       false,
+		registryPanel:      false,
+		cloudInitPanel:     false,
+	}
+	preseeded := getPreseededPanels(c)
+	for name, value := range expected {
+		assert.Equal(t, value, preseeded[name], name)
+	}
+
+	// the method is still asked when only the interface is pre-seeded
+	c, err := cfg.ToInstallConfig([]byte("harvester:\n  network:\n    interface: eth0\n"))
+	assert.Nil(t, err)
+	preseeded = getPreseededPanels(c)
+	assert.True(t, preseeded[networkPanel])
+	assert.False(t, preseeded[networkMethodPanel])
+}
+
+func TestParseDNSServers(t *testing.T) {
+	servers, err := parseDNSServers(" 8.8.8.8, 1.1.1.1,,2001:4860:4860::8888 ")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"8.8.8.8", "1.1.1.1", "2001:4860:4860::8888"}, servers)
+
+	_, err = parseDNSServers("8.8.8.8,dns.example.com")
+	assert.NotNil(t, err)
+}
+
+func TestParseNTPServers(t *testing.T) {
+	servers, err := parseNTPServers("ntp.ubuntu.com, 0.pool.ntp.org,10.0.0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"ntp.ubuntu.com", "0.pool.ntp.org", "10.0.0.1"}, servers)
+
+	servers, err = parseNTPServers("")
+	assert.Nil(t, err)
+	assert.Empty(t, servers)
+
+	_, err = parseNTPServers("ntp.ubuntu.com,-bad-.example")
+	assert.NotNil(t, err)
+}
+
+func TestMergeCloudConfig(t *testing.T) {
+	dst := config.CloudConfig{}
+	dst.K3OS.DNSNameservers = []string{"8.8.8.8"}
+	dst.K3OS.NTPServers = []string{"ntp.ubuntu.com"}
+	dst.K3OS.Modules = []string{"kvm"}
+
+	remote := config.CloudConfig{}
+	remote.K3OS.DNSNameservers = []string{"1.1.1.1"}
+	remote.K3OS.Modules = []string{"nvme"}
+
+	assert.Nil(t, mergeCloudConfig(&dst, &remote))
+	assert.Equal(t, []string{"1.1.1.1"}, dst.K3OS.DNSNameservers)
+	assert.Equal(t, []string{"ntp.ubuntu.com"}, dst.K3OS.NTPServers)
+	assert.Equal(t, []string{"kvm", "nvme"}, dst.K3OS.Modules)
+}
+
+func TestGetConnmanConfigContent(t *testing.T) {
+	network := cfg.Network{
+		Interface: "eth0",
+		Method:    networkMethodStatic,
+		IP:        "192.168.1.10/24",
+		Gateway:   "192.168.1.1",
+	}
+	expected := `[service_harvester_management]
+Type=ethernet
+MAC=52:54:00:12:34:56
+IPv4=192.168.1.10/24/192.168.1.1
+Nameservers=8.8.8.8,1.1.1.1
+`
+	assert.Equal(t, expected, getConnmanConfigContent(network, "52:54:00:12:34:56", []string{"8.8.8.8", "1.1.1.1"}))
+}
+
+func TestRenderHostname(t *testing.T) {
+	hostname, err := renderHostname("node-1", "")
+	assert.Nil(t, err)
+	assert.Equal(t, "node-1", hostname)
+
+	_, err = renderHostname("harvester-{mac}", "not-exist0")
+	assert.NotNil(t, err)
+}
+
+func TestGetProductSerial(t *testing.T) {
+	testCases := []struct {
+		Name    string
+		content string
+		serial  string
+		valid   bool
+	}{
+		{
+			Name:    "serial number",
+			content: "VMware-56 4d 9a 0b\n",
+			serial:  "vmware-56-4d-9a-0b",
+			valid:   true,
+		},
+		{
+			Name:    "placeholder",
+			content: "To be filled by O.E.M.\n",
+		},
+		{
+			Name:    "empty",
+			content: "\n",
+		},
+	}
+	dir, err := ioutil.TempDir("", "serial")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "product_serial")
+	for _, testCase := range testCases {
+		assert.Nil(t, ioutil.WriteFile(file, []byte(testCase.content), 0644))
+		serial, err := getProductSerial(file)
+		assert.Equal(t, testCase.valid, err == nil, testCase.Name)
+		assert.Equal(t, testCase.serial, serial, testCase.Name)
+	}
+}
+
+func TestGetDiskOptions(t *testing.T) {
+	disks := []disk.Disk{
+		{
+			Name:           "sda",
+			Path:           "/dev/sda",
+			Vendor:         "ATA",
+			Model:          "Samsung SSD 860",
+			Size:           500 << 30,
+			PartitionTable: "gpt",
+			FileSystems: []disk.FileSystem{
+				{Device: "/dev/sda1", Type: "ext4", Label: "K3OS_STATE"},
+			},
+		},
+		{
+			Name:       "sdb",
+			Path:       "/dev/sdb",
+			Size:       500 << 30,
+			Rotational: true,
+			FileSystems: []disk.FileSystem{
+				{Device: "/dev/sdb", Type: "iso9660", Label: "K3OS"},
+			},
+		},
+		{
+			Name: "sdc",
+			Path: "/dev/sdc",
+			Size: 16 << 30,
+		},
+		{
+			Name:       "sdd",
+			Path:       "/dev/sdd",
+			Model:      "ST4000NM0035",
+			Size:       4 << 40,
+			Rotational: true,
+		},
+	}
+	expected := []widgets.Option{
+		{
+			Value: "/dev/sda",
+			Text:  "/dev/sda 500.0 GiB SSD ATA Samsung SSD 860 [gpt, ext4:K3OS_STATE]",
+		},
+		{
+			Value: "/dev/sdd",
+			Text:  "/dev/sdd 4.0 TiB HDD ST4000NM0035",
+		},
+	}
+	assert.Equal(t, expected, getDiskOptions(disks, 80))
+
+	options := getDiskOptions(disks, 30)
+	assert.Equal(t, "/dev/sda 500.0 GiB SSD ATA ...", options[0].Text)
+}
+
+func TestGetDiskDataWarning(t *testing.T) {
+	disks := []disk.Disk{
+		{
+			Path:           "/dev/sdb",
+			Model:          "Samsung SSD 860",
+			PartitionTable: "gpt",
+			FileSystems: []disk.FileSystem{
+				{Device: "/dev/sdb1", Type: "ext4", Label: "HARVESTER_DATA0"},
+			},
+		},
+		{
+			Path:        "/dev/sdc",
+			Model:       "ST4000NM0035",
+			FileSystems: []disk.FileSystem{{Device: "/dev/sdc", Type: "xfs"}},
+		},
+	}
+	assert.Equal(t, "/dev/sdb Samsung SSD 860 contains data:\n"+
+		"  gpt partition table\n"+
+		"  /dev/sdb1 ext4 HARVESTER_DATA0\n"+
+		"All data on the disk will be lost. Continue?\n", getDiskDataWarning(disks[0]))
+	assert.Equal(t, "/dev/sdb Samsung SSD 860 contains data:\n"+
+		"  gpt partition table\n"+
+		"  /dev/sdb1 ext4 HARVESTER_DATA0\n"+
+		"/dev/sdc ST4000NM0035 contains data:\n"+
+		"  /dev/sdc xfs \n"+
+		"All data on the disks will be lost. Continue?\n", getDiskDataWarning(disks...))
+}
+
+func TestGetDataDiskOptions(t *testing.T) {
+	disks := []disk.Disk{
+		{Name: "sda", Path: "/dev/sda", Size: 500 << 30},
+		{Name: "sdb", Path: "/dev/sdb", Size: 8 << 30, FileSystems: []disk.FileSystem{{Device: "/dev/sdb", Type: "iso9660", Label: "K3OS"}}},
+		{Name: "nvme0n1", Path: "/dev/nvme0n1", StablePath: "/dev/disk/by-id/nvme-eui.0001", Size: 1 << 40},
+		{Name: "nvme1n1", Path: "/dev/nvme1n1", Size: 1 << 40},
+	}
+	expected := []widgets.Option{
+		{Value: dataDisksDone, Text: "Continue"},
+		{Value: "/dev/disk/by-id/nvme-eui.0001", Text: "[x] /dev/nvme0n1 1.0 TiB SSD"},
+		{Value: "/dev/nvme1n1", Text: "[ ] /dev/nvme1n1 1.0 TiB SSD"},
+	}
+	assert.Equal(t, expected, getDataDiskOptions(disks, "/dev/sda", []string{"/dev/disk/by-id/nvme-eui.0001"}, 80))
+}
+
+func TestGetDataDiskBootCmds(t *testing.T) {
+	expected := []string{
+		`mkdir -p /var/lib/harvester/defaultdisk && (mountpoint -q /var/lib/harvester/defaultdisk || mount "$(blkid -L HARVESTER_DATA0)" /var/lib/harvester/defaultdisk)`,
+		`mkdir -p /var/lib/harvester/extra-disks/harvester_data1 && (mountpoint -q /var/lib/harvester/extra-disks/harvester_data1 || mount "$(blkid -L HARVESTER_DATA1)" /var/lib/harvester/extra-disks/harvester_data1)`,
+	}
+	assert.Equal(t, expected, getDataDiskBootCmds(2))
+	assert.Empty(t, getDataDiskBootCmds(0))
+}
+
+func TestGetDataDiskAnnotateCmd(t *testing.T) {
+	cmd, err := getDataDiskAnnotateCmd(2)
+	assert.Nil(t, err)
+	assert.Equal(t, `(until k3s kubectl --kubeconfig /var/lib/rancher/k3s/agent/kubelet.kubeconfig annotate --overwrite node "$(hostname)" `+
+		`node.longhorn.io/default-disks-config='[{"allowScheduling":true,"path":"/var/lib/harvester/defaultdisk"},`+
+		`{"allowScheduling":true,"path":"/var/lib/harvester/extra-disks/harvester_data1"}]'; do sleep 5; done) >/dev/null 2>&1 &`, cmd)
+	assert.Equal(t, "node.longhorn.io/create-default-disk=config", getLonghornDiskLabel(2))
+	assert.Equal(t, "node.longhorn.io/create-default-disk=true", getLonghornDiskLabel(0))
+}
+
+func TestProgressTracker(t *testing.T) {
+	tracker := newProgressTracker()
+	testCases := []struct {
+		line     string
+		ok       bool
+		expected installProgress
+	}{
+		{
+			line:     "Copying ISO artifacts",
+			ok:       false,
+			expected: installProgress{Stage: -1},
+		},
+		{
+			line:     "::stage partition",
+			ok:       true,
+			expected: installProgress{Stage: 0},
+		},
+		{
+			line:     "::stage copy",
+			ok:       true,
+			expected: installProgress{Stage: 2, Percent: 10},
+		},
+		{
+			line:     "::progress 50",
+			ok:       true,
+			expected: installProgress{Stage: 2, StagePercent: 50, Percent: 20},
+		},
+		{
+			line:     "::progress 150",
+			ok:       true,
+			expected: installProgress{Stage: 2, StagePercent: 100, Percent: 30},
+		},
+		{
+			line:     "::progress abc",
+			ok:       false,
+			expected: installProgress{Stage: 2, StagePercent: 100, Percent: 30},
+		},
+		{
+			line:     "::stage finalize",
+			ok:       true,
+			expected: installProgress{Stage: 5, Percent: 90},
+		},
+		{
+			line:     "::progress 100",
+			ok:       true,
+			expected: installProgress{Stage: 5, StagePercent: 100, Percent: 100},
+		},
+	}
+	for _, testCase := range testCases {
+		progress, ok := tracker.parse(testCase.line)
+		assert.Equal(t, testCase.ok, ok, testCase.line)
+		assert.Equal(t, testCase.expected, progress, testCase.line)
+	}
+	assert.Equal(t, "Stage 6/6: Finalizing installation", tracker.progress.Description())
+}
+
+func TestInstallLog(t *testing.T) {
+	log := &installLog{}
+	assert.Empty(t, log.tail(installLogLines))
+	for i := 0; i < 15; i++ {
+		log.add(fmt.Sprintf("line %d", i))
+	}
+	tail := log.tail(3)
+	assert.Equal(t, []string{"line 12", "line 13", "line 14"}, tail)
+	assert.Len(t, log.tail(installLogLines), installLogLines)
+
+	dir, err := ioutil.TempDir("", "install-log")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "install.log")
+	assert.Nil(t, log.save(path))
+	content, err := ioutil.ReadFile(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 15, strings.Count(string(content), "\n"))
+	assert.True(t, strings.HasSuffix(string(content), "line 14\n"))
+
+	log.reset()
+	assert.Empty(t, log.tail(installLogLines))
+}
+
+func TestReadLines(t *testing.T) {
+	input := "first\n" + strings.Repeat("x", 2*1024*1024) + "\nlast\n"
+	lines := make(chan string)
+	go func() {
+		readLines(strings.NewReader(input), lines)
+		close(lines)
+	}()
+	var result []string
+	for line := range lines {
+		result = append(result, line)
+	}
+	assert.Equal(t, 2, len(result))
+	assert.Equal(t, "first", result[0])
+	assert.True(t, strings.HasPrefix(result[1], "failed to read install output"))
+}
+
+func TestGetAutomaticInstallFailure(t *testing.T) {
+	err := errors.Wrap(cfg.ValidationErrors{
+		{Field: cfg.FieldDevice, Message: "Installation target is required"},
+		{Field: cfg.FieldPassword, Message: "Password is required"},
+	}, "invalid install config")
+	assert.Equal(t, "Automatic installation failed: invalid install config\n"+
+		"  k3os.install.device: Installation target is required\n"+
+		"  k3os.password: Password is required\n"+
+		"Fix the install config and restart the host to install again", getAutomaticInstallFailure(err))
+
+	err = errors.Wrap(errors.New("install script exited with code 3"), "installation failed at Stage 1/6: Partitioning disk")
+	assert.Equal(t, "Automatic installation failed: installation failed at Stage 1/6: Partitioning disk: install script exited with code 3\n"+
+		"Fix the install config and restart the host to install again", getAutomaticInstallFailure(err))
+}
+
+func TestRunInstallScript(t *testing.T) {
+	dir, err := ioutil.TempDir("", "install-script")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	defer func(script, mkfs string) {
+		installScript, mkfsCommand = script, mkfs
+	}(installScript, mkfsCommand)
+	installScript = filepath.Join(dir, "install")
+	mkfsCommand = filepath.Join(dir, "mkfs")
+	formatted := filepath.Join(dir, "formatted")
+	assert.Nil(t, ioutil.WriteFile(mkfsCommand, []byte("#!/bin/sh\necho \"$@\" >> "+formatted+"\n"), 0755))
+
+	var lines []string
+	printer := func(line string) {
+		lines = append(lines, line)
+	}
+	reporter := func(installProgress) {}
+
+	// a failed installation leaves the data disks alone
+	assert.Nil(t, ioutil.WriteFile(installScript, []byte("#!/bin/sh\necho \"failed to partition $K3OS_INSTALL_DEVICE\"\nexit 3\n"), 0755))
+	err = runInstallScript([]string{"K3OS_INSTALL_DEVICE=/dev/sda"}, []string{"/dev/sdb"}, printer, reporter)
+	assert.EqualError(t, err, "install script exited with code 3")
+	assert.Equal(t, []string{"failed to partition /dev/sda"}, lines)
+	_, err = os.Stat(formatted)
+	assert.True(t, os.IsNotExist(err), "the data disks are not formatted")
+
+	assert.Nil(t, ioutil.WriteFile(installScript, []byte("#!/bin/sh\nexit 0\n"), 0755))
+	assert.Nil(t, runInstallScript(nil, []string{"/dev/sdb", "/dev/sdc"}, printer, reporter))
+	content, err := ioutil.ReadFile(formatted)
+	assert.Nil(t, err)
+	assert.Equal(t, "-F -L "+getDataDiskLabel(0)+" /dev/sdb\n-F -L "+getDataDiskLabel(1)+" /dev/sdc\n", string(content))
+}
+
+func TestGetInstallFailureContent(t *testing.T) {
+	content := getInstallFailureContent(installProgress{Stage: 2, Percent: 10}, fmt.Errorf("install script exited with code 1"), []string{"tar: write error", "No space left on device"})
+	assert.Equal(t, "Installation failed at Stage 3/6: Copying files\ninstall script exited with code 1\n\nLast log lines:\ntar: write error\nNo space left on device\n", content)
+
+	content = getInstallFailureContent(installProgress{Stage: -1}, fmt.Errorf("no such device"), nil)
+	assert.Equal(t, "Installation failed at Preparing installation\nno such device\n", content)
+}
+
+func TestCopyConfig(t *testing.T) {
+	c := &cfg.InstallConfig{
+		Harvester: cfg.Harvester{
+			InstallMode: modeCreate,
+			DataDisks:   []string{"/dev/sdb"},
+		},
+	}
+	c.K3OS.Install = &config.Install{Device: "/dev/sda"}
+	c.K3OS.Environment = map[string]string{"http_proxy": "http://proxy:3128"}
+
+	copied, err := copyConfig(c)
+	assert.Nil(t, err)
+	assert.Equal(t, c, copied)
+
+	assert.Nil(t, customizeConfig(copied))
+	copied.K3OS.Install.Device = "/dev/sdc"
+	copied.K3OS.Environment["https_proxy"] = "http://proxy:3128"
+	assert.Equal(t, "/dev/sda", c.K3OS.Install.Device)
+	assert.Empty(t, c.Hostname)
+	assert.Empty(t, c.WriteFiles)
+	assert.Len(t, c.K3OS.Environment, 1)
+}
+
+func TestGetJoinCheckMessage(t *testing.T) {
+	testCases := []struct {
+		err     error
+		message string
+		warning bool
+	}{
+		{
+			err:     &cluster.UnreachableError{URL: "https://172.16.0.1:6443", Err: fmt.Errorf("connection refused")},
+			message: "Unable to reach the management address https://172.16.0.1:6443",
+			warning: true,
+		},
+		{
+			err:     &cluster.ClockSkewError{Skew: 10 * time.Minute},
+			message: "The clock of this host differs from the server by 10m0s",
+			warning: true,
+		},
+		{
+			err:     cluster.ErrCAMismatch,
+			message: "The CA of the server doesn't match the cluster token",
+		},
+		{
+			err:     cluster.ErrInvalidToken,
+			message: "The cluster token is rejected by the server",
+		},
+		{
+			err:     fmt.Errorf("got 500 status code"),
+			message: "Failed to check the management address: got 500 status code",
+		},
+	}
+	for _, testCase := range testCases {
+		message, warning := getJoinCheckMessage(testCase.err)
+		assert.Equal(t, testCase.message, message)
+		assert.Equal(t, testCase.warning, warning)
+	}
+}
+
+func TestGenerateToken(t *testing.T) {
+	seen := map[string]bool{}
+	for i := 0; i < 20; i++ {
+		token, err := generateToken()
+		assert.Nil(t, err)
+		assert.Len(t, token, generatedTokenLength)
+		assert.Nil(t, cfg.ValidateToken(cfg.ModeCreate, token))
+		assert.False(t, seen[token])
+		seen[token] = true
+	}
+}
+
+func TestGetInstallSummary(t *testing.T) {
+	c := &cfg.InstallConfig{Harvester: cfg.Harvester{InstallMode: modeCreate}}
+	c.K3OS.Token = "xQ7vK2mP9sL4nR8wT3yZ"
+	assert.Equal(t, "Installation completed\n\n"+
+		"Management URL: https://172.16.0.10:8443\n"+
+		"Cluster token: xQ7vK2mP9sL4nR8wT3yZ\n\n"+
+		"To add a node, install Harvester in join mode with management address\n"+
+		"172.16.0.10 and the cluster token above.", getInstallSummary(c, "172.16.0.10"))
+
+	c.InstallMode = modeJoin
+	assert.Equal(t, "Installation completed", getInstallSummary(c, "172.16.0.10"))
+}
+
+func TestGetManagementAddress(t *testing.T) {
+	c := &cfg.InstallConfig{
+		Harvester: cfg.Harvester{
+			Network: cfg.Network{
+				Method: networkMethodStatic,
+				IP:     "172.16.0.10/24",
+			},
+		},
+	}
+	assert.Equal(t, "172.16.0.10", getManagementAddress(c))
+}
+
+func TestSetRegistryMirror(t *testing.T) {
+	c := &cfg.InstallConfig{}
+	setRegistryMirror(c, "docker.io", "https://harbor.example.com")
+	assert.Equal(t, &config.Registries{
+		Mirrors: map[string]config.Mirror{"docker.io": {Endpoints: []string{"https://harbor.example.com"}}},
+	}, c.K3OS.Registries)
+	assert.Equal(t, "registry mirror: docker.io -> https://harbor.example.com\n", getRegistryMirrorsContent(c.K3OS.Registries))
+
+	setRegistryMirror(c, "docker.io", "")
+	assert.Nil(t, c.K3OS.Registries)
+	assert.Empty(t, getRegistryMirrorsContent(c.K3OS.Registries))
+
+	// registry settings other than the mirror are kept
+	c.K3OS.Registries = &config.Registries{
+		Configs: map[string]config.RegistryConfig{"harbor.example.com": {TLS: &config.RegistryTLS{InsecureSkipVerify: true}}},
+	}
+	setRegistryMirror(c, "docker.io", "https://harbor.example.com")
+	setRegistryMirror(c, "docker.io", "")
+	assert.NotNil(t, c.K3OS.Registries)
+	assert.Empty(t, c.K3OS.Registries.Mirrors)
+}
+
+func TestGetAddonManifest(t *testing.T) {
+	const manifest = `apiVersion: harvester.cattle.io/v1alpha1
+kind: VirtualMachineImage
+metadata:
+  name: ubuntu
+  namespace: default
+spec:
+  url: https://cloud-images.ubuntu.com/focal/current/focal-server-cloudimg-amd64.img
+`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/image.yaml" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(manifest))
+	}))
+	defer server.Close()
+	isoRoot, err := ioutil.TempDir("", "iso")
+	assert.Nil(t, err)
+	defer os.RemoveAll(isoRoot)
+	assert.Nil(t, os.MkdirAll(filepath.Join(isoRoot, "addons"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(isoRoot, "addons", "image.yaml"), []byte(manifest), 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(isoRoot, "addons", "invalid.yaml"), []byte("kind: VirtualMachineImage"), 0644))
+
+	testCases := []struct {
+		name  string
+		addon cfg.Addon
+		err   bool
+	}{
+		{
+			name:  "inline",
+			addon: cfg.Addon{Name: "image", Content: manifest},
+		},
+		{
+			name:  "url",
+			addon: cfg.Addon{Name: "image", URL: server.URL + "/image.yaml"},
+		},
+		{
+			name:  "path",
+			addon: cfg.Addon{Name: "image", Path: "/addons/image.yaml"},
+		},
+		{
+			name:  "url not found",
+			addon: cfg.Addon{Name: "image", URL: server.URL + "/missing.yaml"},
+			err:   true,
+		},
+		{
+			name:  "invalid manifest",
+			addon: cfg.Addon{Name: "image", Path: "addons/invalid.yaml"},
+			err:   true,
+		},
+	}
+	for _, testCase := range testCases {
+		content, err := getAddonManifest(testCase.addon, isoRoot)
+		if testCase.err {
+			assert.NotNil(t, err, testCase.name)
+			continue
+		}
+		assert.Nil(t, err, testCase.name)
+		assert.Equal(t, manifest, string(content), testCase.name)
+	}
+}
+
+func TestCheckBundledChart(t *testing.T) {
+	isoRoot, err := ioutil.TempDir("", "iso")
+	assert.Nil(t, err)
+	defer os.RemoveAll(isoRoot)
+	bundled := filepath.Join(isoRoot, fmt.Sprintf(harvesterBundledChartFile, "0.1.0"))
+	assert.Nil(t, os.MkdirAll(filepath.Dir(bundled), 0755))
+	assert.Nil(t, ioutil.WriteFile(bundled, []byte("chart"), 0644))
+
+	testCases := []struct {
+		name  string
+		chart *cfg.Chart
+		err   bool
+	}{
+		{
+			name: "default chart",
+		},
+		{
+			name:  "bundled version",
+			chart: &cfg.Chart{Version: "0.1.0"},
+		},
+		{
+			name:  "version not bundled",
+			chart: &cfg.Chart{Version: "0.2.0"},
+			err:   true,
+		},
+		{
+			name:  "remote version",
+			chart: &cfg.Chart{URL: "https://charts.example.com/harvester", Version: "0.2.0"},
+		},
+	}
+	for _, testCase := range testCases {
+		err := checkBundledChart(testCase.chart, isoRoot)
+		if testCase.err {
+			assert.NotNil(t, err, testCase.name)
+			continue
+		}
+		assert.Nil(t, err, testCase.name)
+	}
+}
+
+func TestCustomizeConfigAddons(t *testing.T) {
+	const manifest = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: test\n"
+	c := &cfg.InstallConfig{
+		Harvester: cfg.Harvester{
+			InstallMode: modeCreate,
+			Addons: []cfg.Addon{
+				{Name: "network", Content: manifest},
+				{Content: manifest},
+			},
+		},
+	}
+	assert.Nil(t, customizeConfig(c))
+	var paths []string
+	for _, f := range c.WriteFiles {
+		paths = append(paths, f.Path)
+	}
+	assert.Equal(t, []string{
+		harvesterManifestFile,
+		"/var/lib/rancher/k3s/server/manifests/zz-addon-001-network.yaml",
+		"/var/lib/rancher/k3s/server/manifests/zz-addon-002-addon.yaml",
+	}, paths)
+	assert.True(t, sort.StringsAreSorted(paths))
+
+	// addons are deployed with a new cluster only
+	c = &cfg.InstallConfig{
+		Harvester: cfg.Harvester{
+			InstallMode: modeJoin,
+			Addons:      []cfg.Addon{{Content: manifest}},
+		},
+	}
+	assert.Nil(t, customizeConfig(c))
+	assert.Empty(t, c.WriteFiles)
+}
@@ -0,0 +1,411 @@
+//go:build linux
+// +build linux
+
+package console
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jroimartin/gocui"
+	cfg "github.com/rancher/harvester-installer/pkg/config"
+	"github.com/rancher/k3os/pkg/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestPreseed returns a config answering the panels which need the hardware
+// and the network of the host
+func newTestPreseed(mode string) *cfg.InstallConfig {
+	c := &cfg.InstallConfig{
+		Harvester: cfg.Harvester{
+			InstallMode: mode,
+			DataDisks:   []string{"/dev/sdb"},
+			SkipChecks:  true,
+			Network: cfg.Network{
+				Interface: "lo",
+				Method:    networkMethodDHCP,
+			},
+		},
+	}
+	c.K3OS.Install = &config.Install{Device: "/dev/sda"}
+	c.K3OS.DNSNameservers = []string{"8.8.8.8"}
+	c.K3OS.NTPServers = []string{"pool.ntp.org"}
+	return c
+}
+
+func TestConsoleLayout(t *testing.T) {
+	tc := newTestConsole(t, 120, 40, nil)
+	defer tc.close()
+
+	width, height := tc.size()
+	assert.Equal(t, 120, width)
+	assert.Equal(t, 40, height)
+	assert.Equal(t, askCreatePanel, tc.currentPanel())
+	assert.Equal(t, "Choose installation mode", tc.viewContent(titlePanel))
+	assert.Equal(t, "Create a new Harvester cluster\nJoin an existing Harvester cluster", tc.viewContent(askCreatePanel+"-options"))
+}
+
+func TestWizardModeNavigation(t *testing.T) {
+	tc := newTestConsole(t, 120, 40, newTestPreseed(""))
+	defer tc.close()
+
+	tc.press(keyDown, keyEnter)
+	assert.Equal(t, modeJoin, cfg.Config.InstallMode)
+	assert.Equal(t, serverURLPanel, tc.currentPanel(), "join mode asks the management address")
+
+	tc.fill("ftp://1.2.3.4")
+	assert.Equal(t, serverURLPanel, tc.currentPanel())
+	assert.Contains(t, tc.viewContent(validatorPanel), "Invalid management address")
+
+	tc.fill("1.2.3.4")
+	assert.Equal(t, "https://1.2.3.4:6443", cfg.Config.K3OS.ServerURL)
+	assert.Equal(t, tokenPanel, tc.currentPanel())
+	assert.Equal(t, "", tc.viewContent(validatorPanel))
+
+	tc.press(keyEsc)
+	assert.Equal(t, serverURLPanel, tc.currentPanel())
+	tc.press(keyEsc)
+	assert.Equal(t, askCreatePanel, tc.currentPanel())
+	tc.press(keyEsc)
+	assert.Equal(t, askCreatePanel, tc.currentPanel(), "nothing to go back to")
+
+	tc.press(keyUp, keyEnter)
+	assert.Equal(t, modeCreate, cfg.Config.InstallMode)
+	assert.Equal(t, tokenPanel, tc.currentPanel(), "create mode skips the management address")
+	tc.press(keyEsc)
+	assert.Equal(t, askCreatePanel, tc.currentPanel())
+}
+
+func TestWizardStaticAddress(t *testing.T) {
+	preseed := newTestPreseed(modeCreate)
+	preseed.K3OS.Token = "token1234"
+	preseed.K3OS.Password = "encrypted"
+	preseed.SSHKeyURL = "https://github.com/user.keys"
+	preseed.Network.Method = networkMethodStatic
+	tc := newTestConsole(t, 120, 40, preseed)
+	defer tc.close()
+
+	assert.Equal(t, addressPanel, tc.currentPanel())
+	tc.fill("192.168.1.10/24")
+	assert.Equal(t, gatewayPanel, tc.currentPanel())
+	tc.fill("10.0.0.1")
+	assert.Equal(t, gatewayPanel, tc.currentPanel(), "the step is not left with invalid answers")
+	assert.Equal(t, "Gateway 10.0.0.1 is not in subnet 192.168.1.0/24", tc.viewContent(validatorPanel))
+
+	tc.fill("192.168.1.1")
+	assert.Equal(t, hostnamePanel, tc.currentPanel())
+	assert.Equal(t, cfg.Network{
+		Interface: "lo",
+		Method:    networkMethodStatic,
+		IP:        "192.168.1.10/24",
+		Gateway:   "192.168.1.1",
+	}, cfg.Config.Network)
+}
+
+func TestWizardCreateWithProxy(t *testing.T) {
+	// the proxy serves plain HTTP only, so that the HTTPS check fails fast
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodConnect {
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer proxy.Close()
+	proxyAddress := proxy.Listener.Addr().String()
+
+	tc := newTestConsole(t, 120, 40, newTestPreseed(modeCreate))
+	defer tc.close()
+
+	assert.Equal(t, tokenPanel, tc.currentPanel())
+	tc.fill("token1234")
+	assert.Equal(t, "Cluster token must be at least 16 characters", tc.viewContent(validatorPanel))
+	tc.fill("harvester-token-1234")
+	assert.Equal(t, passwordPanel, tc.currentPanel())
+	tc.press("secret", keyEnter)
+	assert.Equal(t, passwordConfirmPanel, tc.currentPanel())
+	tc.press("secret", keyEnter)
+	assert.Equal(t, sshKeyPanel, tc.currentPanel())
+	tc.press(keyEnter)
+	assert.Equal(t, hostnamePanel, tc.currentPanel())
+	tc.fill("node1")
+
+	assert.Equal(t, proxyPanel, tc.currentPanel())
+	tc.press("http://"+proxyAddress, keyEnter)
+	assert.Equal(t, httpsProxyPanel, tc.currentPanel())
+	tc.press(keyEnter)
+	assert.Equal(t, proxyUserPanel, tc.currentPanel())
+	tc.press("user", keyEnter, "pass", keyEnter)
+
+	assert.Equal(t, noProxyPanel, tc.currentPanel())
+	tc.press(keyEnter)
+	// the proxy is checked in the background
+	tc.waitForContent(validatorPanel, "Unable to reach https://releases.rancher.com/ through the proxy. Press Enter to use anyway")
+	tc.press(keyEnter)
+
+	assert.Equal(t, registryPanel, tc.currentPanel())
+	tc.press(keyEnter)
+	assert.Equal(t, cloudInitPanel, tc.currentPanel())
+	tc.press(keyEnter)
+	assert.Equal(t, confirmPanel, tc.currentPanel())
+	review := tc.viewContent(confirmPanel + "-options")
+	assert.Contains(t, review, "Cluster token:       ********")
+	assert.Contains(t, review, "HTTP proxy:          http://user:*****@"+proxyAddress)
+	assert.NotContains(t, review, "pass@")
+
+	assert.Equal(t, "node1", cfg.Config.Hostname)
+	assert.Equal(t, "harvester-token-1234", cfg.Config.K3OS.Token)
+	assert.True(t, strings.HasPrefix(cfg.Config.K3OS.Password, "$6$"), "password is encrypted")
+	httpProxy, httpsProxy, noProxy := getProxies(&cfg.Config)
+	assert.Equal(t, "http://user:pass@"+proxyAddress, httpProxy)
+	assert.Equal(t, httpProxy, httpsProxy)
+	assert.Equal(t, "localhost,127.0.0.1,0.0.0.0,10.42.0.0/16,10.43.0.0/16,.svc,.cluster.local,127.0.0.0/8", noProxy)
+
+	installConfig, err := copyConfig(&cfg.Config)
+	assert.Nil(t, err)
+	assert.Nil(t, customizeConfig(installConfig))
+	assert.Equal(t, []string{
+		"server",
+		"--disable",
+		"local-storage",
+		"--node-label",
+		"svccontroller.k3s.cattle.io/enablelb=true",
+		"--flannel-iface",
+		"lo",
+		"--node-label",
+		"node.longhorn.io/create-default-disk=config",
+	}, installConfig.K3OS.K3sArgs, "the data disk is registered with Longhorn")
+	assert.Equal(t, httpProxy, installConfig.K3OS.Environment["HTTP_PROXY"])
+	assert.Equal(t, noProxy, installConfig.K3OS.Environment["NO_PROXY"])
+}
+
+func TestWizardReviewEdit(t *testing.T) {
+	preseed := newTestPreseed(modeCreate)
+	preseed.K3OS.Token = "harvester-token-1234"
+	preseed.K3OS.Password = "$6$encrypted"
+	preseed.SSHKeyURL = "https://github.com/user.keys"
+	preseed.Hostname = "node1"
+	// the review doesn't fit in the screen
+	tc := newTestConsole(t, 100, 30, preseed)
+	defer tc.close()
+
+	assert.Equal(t, proxyPanel, tc.currentPanel())
+	tc.press(keyEnter, keyEnter)
+	assert.Equal(t, registryPanel, tc.currentPanel())
+	tc.press(keyEnter, keyEnter)
+	assert.Equal(t, confirmPanel, tc.currentPanel())
+	assert.Contains(t, tc.viewContent(confirmPanel+"-options"), "Hostname:            node1")
+
+	tc.choose(hostnamePanel)
+	assert.Equal(t, hostnamePanel, tc.currentPanel(), "pre-seeded steps are edited too")
+	tc.fill("node2")
+	assert.Equal(t, confirmPanel, tc.currentPanel(), "return to review")
+	assert.Equal(t, "node2", cfg.Config.Hostname)
+	assert.Contains(t, tc.viewContent(confirmPanel+"-options"), "Hostname:            node2")
+
+	// the management address is asked once the mode is changed to join
+	tc.choose(askCreatePanel)
+	tc.press(keyDown, keyEnter)
+	assert.Equal(t, serverURLPanel, tc.currentPanel())
+	tc.press(keyEsc)
+	assert.Equal(t, askCreatePanel, tc.currentPanel())
+	tc.press(keyEsc)
+	assert.Equal(t, confirmPanel, tc.currentPanel())
+	tc.choose("yes")
+	assert.Equal(t, confirmPanel, tc.currentPanel(), "the install doesn't start without all answers")
+	assert.Equal(t, "Management address is required", tc.viewContent(validatorPanel))
+
+	// the token is checked against the new management address
+	nodes := make(chan []string, 1)
+	server := newTestCluster(nodes)
+	defer server.Close()
+	tc.choose(serverURLPanel)
+	tc.fill(server.URL)
+	assert.Equal(t, tokenPanel, tc.currentPanel())
+	nodes <- []string{"node1"}
+	tc.press(keyEnter)
+	tc.waitForContent(titlePanel, "Confirm installation options")
+	assert.Contains(t, tc.viewContent(confirmPanel+"-options"), "Management address:  "+server.URL)
+	assert.Contains(t, tc.viewContent(confirmPanel), "management CA: "+tc.serverFingerprint)
+
+	tc.choose(sshKeyPanel)
+	assert.Equal(t, "https://github.com/user.keys", tc.viewContent(sshKeyPanel+"-input"), "the answer is shown for editing")
+	tc.press(keyEsc)
+	assert.Equal(t, confirmPanel, tc.currentPanel())
+
+	assert.Equal(t, modeJoin, cfg.Config.InstallMode)
+	assert.Equal(t, "node2", cfg.Config.Hostname, "other answers are kept")
+	assert.Equal(t, "harvester-token-1234", cfg.Config.K3OS.Token)
+	assert.Equal(t, []string{"/dev/sdb"}, cfg.Config.DataDisks)
+
+	tc.press(keyEsc)
+	assert.Equal(t, cloudInitPanel, tc.currentPanel(), "going back from review returns to the last step")
+}
+
+func TestWizardBackgroundCheck(t *testing.T) {
+	tc := newTestConsole(t, 100, 30, nil)
+	defer tc.close()
+
+	release := make(chan error)
+	startCheck := func() {
+		tc.do(func(g *gocui.Gui) error {
+			return runCheck(tc.Console, func() error {
+				return <-release
+			}, func(err error) error {
+				return tc.setContentByName(validatorPanel, "checked: "+err.Error())
+			})
+		})
+	}
+	startCheck()
+	assert.Equal(t, "Checking...", tc.viewContent(validatorPanel))
+	tc.press(keyDown)
+	assert.Equal(t, askCreatePanel, tc.currentPanel(), "the console keeps responding")
+	tc.do(func(g *gocui.Gui) error {
+		return runCheck(tc.Console, func() error {
+			t.Error("a check is started while checking")
+			return nil
+		}, nil)
+	})
+	release <- errors.New("unreachable")
+	tc.waitForContent(validatorPanel, "checked: unreachable")
+
+	// the result is dropped once the step is left
+	startCheck()
+	tc.do(func(g *gocui.Gui) error {
+		return closeStep(tc.Console, askCreatePanel)
+	})
+	release <- errors.New("late")
+	startCheck()
+	release <- errors.New("again")
+	tc.waitForContent(validatorPanel, "checked: again")
+}
+
+// newTestCluster serves the CA of a cluster to join and lists its nodes, the
+// names of the nodes are received from nodes for each request
+func newTestCluster(nodes <-chan []string) *httptest.Server {
+	var caCerts []byte
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cacerts":
+			w.Write(caCerts)
+		case "/api/v1/nodes":
+			var list struct {
+				Items []map[string]map[string]string `json:"items"`
+			}
+			for _, name := range <-nodes {
+				list.Items = append(list.Items, map[string]map[string]string{"metadata": {"name": name}})
+			}
+			json.NewEncoder(w).Encode(list)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	caCerts = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return server
+}
+
+func TestWizardJoinCheck(t *testing.T) {
+	nodes := make(chan []string, 1)
+	server := newTestCluster(nodes)
+	defer server.Close()
+	preseed := newTestPreseed(modeJoin)
+	preseed.K3OS.ServerURL = server.URL
+	preseed.K3OS.Token = "token1234"
+	preseed.K3OS.Password = "encrypted"
+	preseed.SSHKeyURL = "https://github.com/user.keys"
+	tc := newTestConsole(t, 120, 40, preseed)
+	defer tc.close()
+
+	assert.Equal(t, tokenPanel, tc.currentPanel(), "the pre-seeded token is checked against the cluster")
+	nodes <- []string{"node1"}
+	tc.press(keyEnter)
+	tc.waitForContent(titlePanel, "Configure hostname")
+	assert.True(t, strings.HasPrefix(tc.serverFingerprint, "SHA256:"))
+
+	// the hostname is checked in the background
+	tc.fill("node1")
+	assert.Equal(t, "Checking...", tc.viewContent(validatorPanel))
+	tc.press(keyEnter)
+	assert.Equal(t, hostnamePanel, tc.currentPanel(), "the console keeps responding while checking")
+	nodes <- []string{"node1"}
+	tc.waitForContent(validatorPanel, `Hostname "node1" is used by another node of the cluster`)
+	assert.Equal(t, "", cfg.Config.Hostname)
+	nodes <- []string{"node1"}
+	tc.fill("node2")
+	tc.waitForContent(titlePanel, "Optional: configure proxy, the HTTPS proxy defaults to the HTTP proxy")
+	assert.Equal(t, "node2", cfg.Config.Hostname)
+}
+
+func TestWizardRemoteSession(t *testing.T) {
+	tc := newTestConsole(t, 100, 30, nil)
+	defer tc.close()
+
+	release := make(chan error)
+	tc.remote = newRemoteAPI(testRemoteToken)
+	tc.remote.install = func(c *cfg.InstallConfig, printer func(string), reporter func(installProgress)) error {
+		reporter(installProgress{Stage: 0, Percent: 2})
+		printer("Partitioning /dev/sda")
+		return <-release
+	}
+	tc.remote.onChange = func() {
+		tc.Gui.Update(func(g *gocui.Gui) error {
+			return showRemoteSession(tc.Console)
+		})
+	}
+	server := httptest.NewServer(tc.remote)
+	defer server.Close()
+
+	code, _ := doRemoteRequest(t, server, http.MethodGet, "/v1/status", testRemoteToken, "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, askCreatePanel, tc.currentPanel(), "reading the host doesn't take control")
+
+	code, _ = doRemoteRequest(t, server, http.MethodPut, "/v1/config", testRemoteToken, testRemoteConfig)
+	assert.Equal(t, http.StatusOK, code)
+	tc.waitForContent(remotePanel, "A remote session is in control of the installation.\n\n"+
+		"Install config submitted, waiting for the installation to start\n"+
+		"Press Enter to install on the console instead")
+	assert.Equal(t, remotePanel, tc.currentPanel(), "the wizard can't be used")
+	assert.Equal(t, "Remote installation", tc.viewContent(titlePanel))
+
+	code, _ = doRemoteRequest(t, server, http.MethodPost, "/v1/install", testRemoteToken, "")
+	assert.Equal(t, http.StatusAccepted, code)
+	tc.waitForContent(remotePanel, "A remote session is in control of the installation.\n\n"+
+		"Stage 1/6: Partitioning disk (2%)\n\n"+
+		"Partitioning /dev/sda")
+	release <- nil
+	tc.waitForContent(remotePanel, "A remote session is in control of the installation.\n\n"+
+		"Installation completed\n\n"+
+		"Partitioning /dev/sda")
+	tc.press(keyEnter)
+	assert.Equal(t, remotePanel, tc.currentPanel(), "the installation isn't taken over once started")
+}
+
+func TestWizardRemoteTakeOver(t *testing.T) {
+	tc := newTestConsole(t, 100, 30, nil)
+	defer tc.close()
+
+	tc.remote = newRemoteAPI(testRemoteToken)
+	tc.remote.onChange = func() {
+		tc.Gui.Update(func(g *gocui.Gui) error {
+			return showRemoteSession(tc.Console)
+		})
+	}
+	server := httptest.NewServer(tc.remote)
+	defer server.Close()
+
+	code, _ := doRemoteRequest(t, server, http.MethodPut, "/v1/config", testRemoteToken, testRemoteConfig)
+	assert.Equal(t, http.StatusOK, code)
+	tc.waitForContent(remotePanel, "A remote session is in control of the installation.\n\n"+
+		"Install config submitted, waiting for the installation to start\n"+
+		"Press Enter to install on the console instead")
+	tc.press(keyEnter)
+	assert.Equal(t, askCreatePanel, tc.currentPanel(), "the wizard starts over")
+	assert.Equal(t, "Choose installation mode", tc.viewContent(titlePanel))
+
+	code, body := doRemoteRequest(t, server, http.MethodPut, "/v1/config", testRemoteToken, testRemoteConfig)
+	assert.Equal(t, http.StatusConflict, code)
+	assert.JSONEq(t, `{"error": "The installation is driven on the console"}`, body)
+}
@@ -0,0 +1,271 @@
+package disk
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// label of the file system of the installer ISO
+	installerLabel = "K3OS"
+)
+
+var (
+	// block devices which are never install targets
+	ignoredPrefixes = []string{"loop", "ram", "zram", "sr", "fd", "dm-", "md", "nbd"}
+)
+
+// Disk is a block device of the host
+type Disk struct {
+	Name string
+	Path string
+	// StablePath is a link under /dev/disk which doesn't change between boots
+	StablePath string
+	Vendor     string
+	Model      string
+	Serial     string
+	Size       uint64
+	Rotational bool
+	Removable  bool
+	// PartitionTable is "gpt", "dos" or empty
+	PartitionTable string
+	// FileSystems found on the whole disk and its partitions
+	FileSystems []FileSystem
+}
+
+// FileSystem is a file system found on a block device
+type FileSystem struct {
+	Device string
+	Type   string
+	Label  string
+}
+
+// Scanner lists the disks of the host. Roots of the sys and dev file systems
+// and the udev database are configurable for testing.
+type Scanner struct {
+	SysRoot  string
+	DevRoot  string
+	UdevRoot string
+}
+
+func NewScanner() *Scanner {
+	return &Scanner{
+		SysRoot:  "/sys",
+		DevRoot:  "/dev",
+		UdevRoot: "/run/udev/data",
+	}
+}
+
+// Scan returns the disks sorted by name
+func (s *Scanner) Scan() ([]Disk, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(s.SysRoot, "block"))
+	if err != nil {
+		return nil, err
+	}
+	var disks []Disk
+	for _, entry := range entries {
+		if isIgnored(entry.Name()) {
+			continue
+		}
+		disk, err := s.GetDisk(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		disks = append(disks, *disk)
+	}
+	sort.Slice(disks, func(i, j int) bool {
+		return disks[i].Name < disks[j].Name
+	})
+	return disks, nil
+}
+
+// GetDisk returns the disk of the given name, e.g. sda
+func (s *Scanner) GetDisk(name string) (*Disk, error) {
+	sysPath := filepath.Join(s.SysRoot, "block", name)
+	sectors, err := strconv.ParseUint(readSysFile(filepath.Join(sysPath, "size")), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get size of %s: %v", name, err)
+	}
+	disk := &Disk{
+		Name:       name,
+		Path:       filepath.Join(s.DevRoot, name),
+		Vendor:     readSysFile(filepath.Join(sysPath, "device", "vendor")),
+		Model:      readSysFile(filepath.Join(sysPath, "device", "model")),
+		Serial:     s.getSerial(sysPath),
+		Size:       sectors * 512,
+		Rotational: readSysFile(filepath.Join(sysPath, "queue", "rotational")) == "1",
+		Removable:  readSysFile(filepath.Join(sysPath, "removable")) == "1",
+		StablePath: s.getStablePath(name),
+	}
+
+	// probe the whole disk first, an ISO image written to a USB stick has no partitions
+	devices := []string{name}
+	partitions, err := filepath.Glob(filepath.Join(sysPath, name+"*", "partition"))
+	if err != nil {
+		return nil, err
+	}
+	for _, partition := range partitions {
+		devices = append(devices, filepath.Base(filepath.Dir(partition)))
+	}
+	for _, device := range devices {
+		probe, err := probeDevice(filepath.Join(s.DevRoot, device))
+		if err != nil {
+			// the device might be gone or not readable, treat it as empty
+			continue
+		}
+		if device == name {
+			disk.PartitionTable = probe.partitionTable
+		}
+		if probe.fsType != "" {
+			disk.FileSystems = append(disk.FileSystems, FileSystem{
+				Device: filepath.Join(s.DevRoot, device),
+				Type:   probe.fsType,
+				Label:  probe.label,
+			})
+		}
+	}
+	return disk, nil
+}
+
+// Resolve returns the whole disk a device path or a link to it points to
+func (s *Scanner) Resolve(path string) (*Disk, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, err
+	}
+	name := filepath.Base(resolved)
+	if _, err := os.Stat(filepath.Join(s.SysRoot, "block", name)); err != nil {
+		return nil, fmt.Errorf("%s is not a disk", path)
+	}
+	return s.GetDisk(name)
+}
+
+// ID returns the stable path of the disk if any, the kernel path otherwise
+func (d *Disk) ID() string {
+	if d.StablePath != "" {
+		return d.StablePath
+	}
+	return d.Path
+}
+
+// HasData returns true if the disk holds a partition table or a file system
+func (d *Disk) HasData() bool {
+	return d.PartitionTable != "" || len(d.FileSystems) > 0
+}
+
+// IsInstaller returns true if the disk is the installer media
+func (d *Disk) IsInstaller() bool {
+	for _, fs := range d.FileSystems {
+		if fs.Label == installerLabel {
+			return true
+		}
+	}
+	return false
+}
+
+// Description returns the vendor, model and serial number of the disk
+func (d *Disk) Description() string {
+	var fields []string
+	for _, field := range []string{d.Vendor, d.Model} {
+		if field != "" {
+			fields = append(fields, field)
+		}
+	}
+	if d.Serial != "" {
+		fields = append(fields, fmt.Sprintf("(%s)", d.Serial))
+	}
+	return strings.Join(fields, " ")
+}
+
+// Type returns SSD or HDD
+func (d *Disk) Type() string {
+	if d.Rotational {
+		return "HDD"
+	}
+	return "SSD"
+}
+
+func (s *Scanner) getSerial(sysPath string) string {
+	if serial := readSysFile(filepath.Join(sysPath, "device", "serial")); serial != "" {
+		return serial
+	}
+	// the unit serial number VPD page, the serial number follows a 4 bytes header
+	if data, err := ioutil.ReadFile(filepath.Join(sysPath, "device", "vpd_pg80")); err == nil && len(data) > 4 {
+		if serial := strings.TrimSpace(strings.Trim(string(data[4:]), "\x00")); serial != "" {
+			return serial
+		}
+	}
+	// fall back to the serial number found by udev
+	dev := readSysFile(filepath.Join(sysPath, "dev"))
+	if dev == "" {
+		return ""
+	}
+	f, err := os.Open(filepath.Join(s.UdevRoot, "b"+dev))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); strings.HasPrefix(line, "E:ID_SERIAL_SHORT=") {
+			return strings.TrimPrefix(line, "E:ID_SERIAL_SHORT=")
+		}
+	}
+	return ""
+}
+
+// getStablePath finds the most stable link to the disk, links by WWN are
+// preferred over other links by id, which are preferred over links by path
+func (s *Scanner) getStablePath(name string) string {
+	var byID, byPath string
+	for _, dir := range []string{"by-id", "by-path"} {
+		links, err := filepath.Glob(filepath.Join(s.DevRoot, "disk", dir, "*"))
+		if err != nil {
+			continue
+		}
+		// the order of links by id is stable as Glob sorts the matches
+		for _, link := range links {
+			target, err := filepath.EvalSymlinks(link)
+			if err != nil || filepath.Base(target) != name {
+				continue
+			}
+			switch base := filepath.Base(link); {
+			case dir == "by-path":
+				if byPath == "" {
+					byPath = link
+				}
+			case strings.HasPrefix(base, "wwn-"):
+				return link
+			case byID == "":
+				byID = link
+			}
+		}
+	}
+	if byID != "" {
+		return byID
+	}
+	return byPath
+}
+
+func isIgnored(name string) bool {
+	for _, prefix := range ignoredPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func readSysFile(path string) string {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,226 @@
+package disk
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ext4Image(label string) []byte {
+	buf := make([]byte, 4096)
+	copy(buf[1080:], []byte{0x53, 0xef})
+	buf[1120] = 0x40
+	copy(buf[1144:], label)
+	return buf
+}
+
+func isoImage(label string) []byte {
+	buf := make([]byte, 34816)
+	copy(buf[510:], []byte{0x55, 0xaa})
+	copy(buf[32769:], "CD001")
+	copy(buf[32808:], label+"                                "[:32-len(label)])
+	return buf
+}
+
+func gptImage() []byte {
+	buf := make([]byte, 4096)
+	copy(buf[510:], []byte{0x55, 0xaa})
+	copy(buf[512:], "EFI PART")
+	return buf
+}
+
+func TestProbe(t *testing.T) {
+	fat32 := make([]byte, 4096)
+	copy(fat32[510:], []byte{0x55, 0xaa})
+	copy(fat32[71:], "EFI        FAT32   ")
+	swap := make([]byte, 4096)
+	copy(swap[1052:], "SWAP")
+	copy(swap[4086:], "SWAPSPACE2")
+
+	testCases := []struct {
+		Name     string
+		input    []byte
+		expected probeResult
+	}{
+		{
+			Name:     "empty",
+			input:    make([]byte, 4096),
+			expected: probeResult{},
+		},
+		{
+			Name:     "ext4",
+			input:    ext4Image("K3OS_STATE"),
+			expected: probeResult{fsType: "ext4", label: "K3OS_STATE"},
+		},
+		{
+			Name:     "hybrid iso",
+			input:    isoImage("K3OS"),
+			expected: probeResult{partitionTable: "dos", fsType: "iso9660", label: "K3OS"},
+		},
+		{
+			Name:     "gpt",
+			input:    gptImage(),
+			expected: probeResult{partitionTable: "gpt"},
+		},
+		{
+			Name:     "fat32",
+			input:    fat32,
+			expected: probeResult{fsType: "vfat", label: "EFI"},
+		},
+		{
+			Name:     "swap",
+			input:    swap,
+			expected: probeResult{fsType: "swap", label: "SWAP"},
+		},
+		{
+			Name:     "short read",
+			input:    make([]byte, 100),
+			expected: probeResult{},
+		},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, &testCase.expected, probe(testCase.input), testCase.Name)
+	}
+}
+
+func TestScan(t *testing.T) {
+	root, err := ioutil.TempDir("", "disk")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	s := &Scanner{
+		SysRoot:  filepath.Join(root, "sys"),
+		DevRoot:  filepath.Join(root, "dev"),
+		UdevRoot: filepath.Join(root, "udev"),
+	}
+	writeFile := func(path string, content []byte) {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, content, 0644))
+	}
+	addDisk := func(name string, size uint64, attrs map[string]string, image []byte) {
+		writeFile(filepath.Join(s.SysRoot, "block", name, "size"), []byte(fmt.Sprintf("%d\n", size/512)))
+		for attr, value := range attrs {
+			writeFile(filepath.Join(s.SysRoot, "block", name, attr), []byte(value+"\n"))
+		}
+		writeFile(filepath.Join(s.DevRoot, name), image)
+	}
+
+	addDisk("sda", 500<<30, map[string]string{
+		"device/vendor":    "ATA",
+		"device/model":     "Samsung SSD 860",
+		"queue/rotational": "0",
+		"dev":              "8:0",
+	}, gptImage())
+	writeFile(filepath.Join(s.SysRoot, "block", "sda", "sda1", "partition"), []byte("1\n"))
+	writeFile(filepath.Join(s.DevRoot, "sda1"), ext4Image("K3OS_STATE"))
+	writeFile(filepath.Join(s.UdevRoot, "b8:0"), []byte("S:disk/by-id/ata-Samsung\nE:ID_SERIAL_SHORT=S3Z9NB0K123456\n"))
+
+	addDisk("sdb", 8<<30, map[string]string{
+		"queue/rotational": "1",
+		"removable":        "1",
+	}, isoImage("K3OS"))
+
+	addDisk("nvme0n1", 1<<40, map[string]string{
+		"device/model":     "INTEL SSDPE2KX010T8",
+		"device/serial":    "PHLJ000000001P0DGN",
+		"queue/rotational": "0",
+	}, make([]byte, 4096))
+
+	addDisk("loop0", 1<<30, nil, nil)
+
+	link := func(dir, name, target string) {
+		assert.Nil(t, os.MkdirAll(filepath.Join(s.DevRoot, "disk", dir), 0755))
+		assert.Nil(t, os.Symlink(filepath.Join("..", "..", target), filepath.Join(s.DevRoot, "disk", dir, name)))
+	}
+	link("by-id", "ata-Samsung_SSD_860_S3Z9NB0K123456", "sda")
+	link("by-id", "ata-Samsung_SSD_860_S3Z9NB0K123456-part1", "sda1")
+	link("by-id", "wwn-0x5002538e40000000", "sda")
+	link("by-path", "pci-0000:00:1f.2-ata-1", "sda")
+	link("by-path", "pci-0000:00:14.0-usb-0:1:1.0-scsi-0:0:0:0", "sdb")
+	link("by-id", "nvme-INTEL_SSDPE2KX010T8_PHLJ000000001P0DGN", "nvme0n1")
+
+	disks, err := s.Scan()
+	assert.Nil(t, err)
+	assert.Equal(t, []Disk{
+		{
+			Name:       "nvme0n1",
+			Path:       filepath.Join(s.DevRoot, "nvme0n1"),
+			StablePath: filepath.Join(s.DevRoot, "disk", "by-id", "nvme-INTEL_SSDPE2KX010T8_PHLJ000000001P0DGN"),
+			Model:      "INTEL SSDPE2KX010T8",
+			Serial:     "PHLJ000000001P0DGN",
+			Size:       1 << 40,
+		},
+		{
+			Name:           "sda",
+			Path:           filepath.Join(s.DevRoot, "sda"),
+			StablePath:     filepath.Join(s.DevRoot, "disk", "by-id", "wwn-0x5002538e40000000"),
+			Vendor:         "ATA",
+			Model:          "Samsung SSD 860",
+			Serial:         "S3Z9NB0K123456",
+			Size:           500 << 30,
+			PartitionTable: "gpt",
+			FileSystems: []FileSystem{
+				{
+					Device: filepath.Join(s.DevRoot, "sda1"),
+					Type:   "ext4",
+					Label:  "K3OS_STATE",
+				},
+			},
+		},
+		{
+			Name:           "sdb",
+			Path:           filepath.Join(s.DevRoot, "sdb"),
+			StablePath:     filepath.Join(s.DevRoot, "disk", "by-path", "pci-0000:00:14.0-usb-0:1:1.0-scsi-0:0:0:0"),
+			Size:           8 << 30,
+			Rotational:     true,
+			Removable:      true,
+			PartitionTable: "dos",
+			FileSystems: []FileSystem{
+				{
+					Device: filepath.Join(s.DevRoot, "sdb"),
+					Type:   "iso9660",
+					Label:  "K3OS",
+				},
+			},
+		},
+	}, disks)
+
+	assert.False(t, disks[0].HasData())
+	assert.True(t, disks[1].HasData())
+	assert.False(t, disks[1].IsInstaller())
+	assert.True(t, disks[2].IsInstaller())
+	assert.Equal(t, "ATA Samsung SSD 860 (S3Z9NB0K123456)", disks[1].Description())
+	assert.Equal(t, "HDD", disks[2].Type())
+}
+
+func TestResolve(t *testing.T) {
+	root, err := ioutil.TempDir("", "disk")
+	assert.Nil(t, err)
+	defer os.RemoveAll(root)
+	s := &Scanner{
+		SysRoot: filepath.Join(root, "sys"),
+		DevRoot: filepath.Join(root, "dev"),
+	}
+	assert.Nil(t, os.MkdirAll(filepath.Join(s.SysRoot, "block", "sda", "sda1"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(s.SysRoot, "block", "sda", "size"), []byte("1024\n"), 0644))
+	assert.Nil(t, os.MkdirAll(filepath.Join(s.DevRoot, "disk", "by-id"), 0755))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(s.DevRoot, "sda"), nil, 0644))
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(s.DevRoot, "sda1"), nil, 0644))
+	stablePath := filepath.Join(s.DevRoot, "disk", "by-id", "wwn-0x5002538e40000000")
+	assert.Nil(t, os.Symlink("../../sda", stablePath))
+	assert.Nil(t, os.Symlink("../../sda1", stablePath+"-part1"))
+
+	d, err := s.Resolve(stablePath)
+	assert.Nil(t, err)
+	assert.Equal(t, filepath.Join(s.DevRoot, "sda"), d.Path)
+	assert.Equal(t, stablePath, d.ID())
+
+	_, err = s.Resolve(stablePath + "-part1")
+	assert.NotNil(t, err)
+
+	_, err = s.Resolve(filepath.Join(s.DevRoot, "disk", "by-id", "wwn-0x0"))
+	assert.NotNil(t, err)
+}
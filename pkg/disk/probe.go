@@ -0,0 +1,107 @@
+package disk
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+)
+
+const (
+	// enough to find the btrfs super block, the farthest one probed
+	probeSize = 65536 + 4096
+)
+
+type probeResult struct {
+	partitionTable string
+	fsType         string
+	label          string
+}
+
+// probeDevice reads the start of a block device to find a partition table
+// and a file system the same way blkid does, by their magic numbers
+func probeDevice(path string) (*probeResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := make([]byte, probeSize)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+	return probe(buf[:n]), nil
+}
+
+func probe(buf []byte) *probeResult {
+	result := &probeResult{}
+	result.fsType, result.label = probeFileSystem(buf)
+	switch {
+	case hasMagic(buf, 512, []byte("EFI PART")), hasMagic(buf, 4096, []byte("EFI PART")):
+		result.partitionTable = "gpt"
+	case hasMagic(buf, 510, []byte{0x55, 0xaa}) && result.fsType != "vfat" && result.fsType != "ntfs":
+		// boot sectors of FAT and NTFS carry the same signature as a MBR
+		result.partitionTable = "dos"
+	}
+	return result
+}
+
+func probeFileSystem(buf []byte) (string, string) {
+	switch {
+	case hasMagic(buf, 32769, []byte("CD001")):
+		return "iso9660", readLabel(buf, 32808, 32)
+	case hasMagic(buf, 1080, []byte{0x53, 0xef}):
+		return probeExt(buf), readLabel(buf, 1144, 16)
+	case hasMagic(buf, 0, []byte("XFSB")):
+		return "xfs", readLabel(buf, 108, 12)
+	case hasMagic(buf, 65600, []byte("_BHRfS_M")):
+		return "btrfs", readLabel(buf, 65835, 256)
+	case hasMagic(buf, 82, []byte("FAT32   ")):
+		return "vfat", readLabel(buf, 71, 11)
+	case hasMagic(buf, 54, []byte("FAT1")):
+		return "vfat", readLabel(buf, 43, 11)
+	case hasMagic(buf, 3, []byte("NTFS    ")):
+		return "ntfs", ""
+	case hasMagic(buf, 4086, []byte("SWAPSPACE2")), hasMagic(buf, 4086, []byte("SWAP-SPACE")):
+		return "swap", readLabel(buf, 1052, 16)
+	case hasMagic(buf, 536, []byte("LVM2 001")):
+		return "LVM2_member", ""
+	case hasMagic(buf, 0, []byte("LUKS\xba\xbe")):
+		return "crypto_LUKS", ""
+	}
+	return "", ""
+}
+
+// probeExt tells ext2, ext3 and ext4 apart by their feature flags
+func probeExt(buf []byte) string {
+	if len(buf) < 1124 {
+		return "ext2"
+	}
+	compat := binary.LittleEndian.Uint32(buf[1116:])
+	incompat := binary.LittleEndian.Uint32(buf[1120:])
+	switch {
+	// extents, 64bit or flex_bg
+	case incompat&(0x40|0x80|0x200) != 0:
+		return "ext4"
+	// has_journal
+	case compat&0x4 != 0:
+		return "ext3"
+	}
+	return "ext2"
+}
+
+func hasMagic(buf []byte, offset int, magic []byte) bool {
+	return len(buf) >= offset+len(magic) && bytes.Equal(buf[offset:offset+len(magic)], magic)
+}
+
+func readLabel(buf []byte, offset, size int) string {
+	if len(buf) < offset+size {
+		return ""
+	}
+	label := buf[offset : offset+size]
+	if i := bytes.IndexByte(label, 0); i >= 0 {
+		label = label[:i]
+	}
+	return string(bytes.TrimSpace(label))
+}
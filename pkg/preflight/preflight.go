@@ -0,0 +1,334 @@
+package preflight
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/rancher/harvester-installer/pkg/disk"
+	"github.com/rancher/harvester-installer/pkg/util"
+)
+
+const (
+	StatusPass = "PASS"
+	StatusWarn = "WARN"
+	StatusFail = "FAIL"
+
+	GiB = 1 << 30
+
+	// hard requirements, installation is blocked below them
+	MinCPUs     = 4
+	MinMemory   = 8 * GiB
+	MinDiskSize = 120 * GiB
+
+	// recommended for running production workloads
+	RecommendedCPUs   = 8
+	RecommendedMemory = 16 * GiB
+)
+
+// Result is the outcome of a single check
+type Result struct {
+	Name    string
+	Status  string
+	Message string
+}
+
+// Checker checks the hardware of the host against the requirements of Harvester.
+// Roots of the proc, sys and dev file systems are configurable for testing.
+type Checker struct {
+	ProcRoot string
+	SysRoot  string
+	DevRoot  string
+}
+
+func NewChecker() *Checker {
+	return &Checker{
+		ProcRoot: "/proc",
+		SysRoot:  "/sys",
+		DevRoot:  "/dev",
+	}
+}
+
+// Run runs all checks. The disk size of device is checked, if device is empty
+// e.g. before the disk is selected, any disk of the host has to be large enough
+func (c *Checker) Run(device string) []Result {
+	results := []Result{
+		c.CheckVirtualization(),
+		c.CheckKVM(),
+		c.CheckCPUs(),
+		c.CheckMemory(),
+	}
+	if device != "" {
+		results = append(results, c.CheckDiskSize(device))
+	} else {
+		results = append(results, c.CheckDisks())
+	}
+	return append(results, c.CheckNetwork())
+}
+
+// CheckVirtualization checks the CPU supports hardware virtualization
+func (c *Checker) CheckVirtualization() Result {
+	result := Result{Name: "CPU virtualization"}
+	f, err := os.Open(filepath.Join(c.ProcRoot, "cpuinfo"))
+	if err != nil {
+		return fail(result, err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value := splitField(scanner.Text())
+		if key != "flags" {
+			continue
+		}
+		for _, flag := range strings.Fields(value) {
+			if flag == "vmx" || flag == "svm" {
+				result.Status = StatusPass
+				result.Message = flag
+				return result
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fail(result, err)
+	}
+	result.Status = StatusFail
+	result.Message = "vmx or svm CPU flag not found, enable virtualization in BIOS"
+	return result
+}
+
+// CheckKVM checks the KVM device is available
+func (c *Checker) CheckKVM() Result {
+	result := Result{Name: "KVM device"}
+	path := filepath.Join(c.DevRoot, "kvm")
+	if _, err := os.Stat(path); err != nil {
+		result.Status = StatusWarn
+		result.Message = fmt.Sprintf("%s is not available", path)
+		return result
+	}
+	result.Status = StatusPass
+	result.Message = path
+	return result
+}
+
+// CheckCPUs checks the number of CPUs
+func (c *Checker) CheckCPUs() Result {
+	result := Result{Name: "CPU count"}
+	f, err := os.Open(filepath.Join(c.ProcRoot, "cpuinfo"))
+	if err != nil {
+		return fail(result, err)
+	}
+	defer f.Close()
+	var cpus int
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if key, _ := splitField(scanner.Text()); key == "processor" {
+			cpus++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fail(result, err)
+	}
+	result.Message = fmt.Sprintf("%d CPUs", cpus)
+	switch {
+	case cpus < MinCPUs:
+		result.Status = StatusFail
+		result.Message += fmt.Sprintf(", at least %d required", MinCPUs)
+	case cpus < RecommendedCPUs:
+		result.Status = StatusWarn
+		result.Message += fmt.Sprintf(", %d recommended", RecommendedCPUs)
+	default:
+		result.Status = StatusPass
+	}
+	return result
+}
+
+// CheckMemory checks the total memory
+func (c *Checker) CheckMemory() Result {
+	result := Result{Name: "Memory"}
+	f, err := os.Open(filepath.Join(c.ProcRoot, "meminfo"))
+	if err != nil {
+		return fail(result, err)
+	}
+	defer f.Close()
+	var total uint64
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value := splitField(scanner.Text())
+		if key != "MemTotal" {
+			continue
+		}
+		// in the form of "16326856 kB"
+		kb, err := strconv.ParseUint(strings.TrimSuffix(value, " kB"), 10, 64)
+		if err != nil {
+			return fail(result, err)
+		}
+		total = kb * 1024
+		break
+	}
+	if err := scanner.Err(); err != nil {
+		return fail(result, err)
+	}
+	result.Message = util.FormatSize(total)
+	switch {
+	case total < MinMemory:
+		result.Status = StatusFail
+		result.Message += fmt.Sprintf(", at least %s required", util.FormatSize(MinMemory))
+	case total < RecommendedMemory:
+		result.Status = StatusWarn
+		result.Message += fmt.Sprintf(", %s recommended", util.FormatSize(RecommendedMemory))
+	default:
+		result.Status = StatusPass
+	}
+	return result
+}
+
+// CheckDiskSize checks the size of the install target device, e.g. /dev/sda
+func (c *Checker) CheckDiskSize(device string) Result {
+	result := Result{Name: "Disk size"}
+	name := filepath.Base(device)
+	// the device might be a stable link such as /dev/disk/by-id/...
+	path := filepath.Join(c.DevRoot, strings.TrimPrefix(device, "/dev/"))
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		name = filepath.Base(resolved)
+	}
+	size, err := c.getDiskSize(name)
+	if err != nil {
+		return fail(result, err)
+	}
+	result.Message = fmt.Sprintf("%s %s", device, util.FormatSize(size))
+	if size < MinDiskSize {
+		result.Status = StatusFail
+		result.Message += fmt.Sprintf(", at least %s required", util.FormatSize(MinDiskSize))
+		return result
+	}
+	result.Status = StatusPass
+	return result
+}
+
+// CheckDisks checks there is a disk large enough to install to, other than the
+// installer media
+func (c *Checker) CheckDisks() Result {
+	result := Result{Name: "Disk size"}
+	dir := filepath.Join(c.SysRoot, "block")
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fail(result, err)
+	}
+	scanner := &disk.Scanner{SysRoot: c.SysRoot, DevRoot: c.DevRoot}
+	var largest string
+	var largestSize uint64
+	for _, entry := range entries {
+		// only physical disks have a device link
+		if _, err := os.Stat(filepath.Join(dir, entry.Name(), "device")); err != nil {
+			continue
+		}
+		size, err := c.getDiskSize(entry.Name())
+		if err != nil || size <= largestSize {
+			continue
+		}
+		if d, err := scanner.GetDisk(entry.Name()); err == nil && d.IsInstaller() {
+			continue
+		}
+		largest, largestSize = entry.Name(), size
+	}
+	if largest == "" {
+		result.Status = StatusFail
+		result.Message = "no disk found"
+		return result
+	}
+	result.Message = fmt.Sprintf("%s %s", largest, util.FormatSize(largestSize))
+	if largestSize < MinDiskSize {
+		result.Status = StatusFail
+		result.Message += fmt.Sprintf(", at least %s required", util.FormatSize(MinDiskSize))
+		return result
+	}
+	result.Status = StatusPass
+	return result
+}
+
+// CheckNetwork checks there is a physical network interface with link
+func (c *Checker) CheckNetwork() Result {
+	result := Result{Name: "Network link"}
+	dir := filepath.Join(c.SysRoot, "class", "net")
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return fail(result, err)
+	}
+	var nics []string
+	for _, entry := range entries {
+		// only physical interfaces have a device link
+		if _, err := os.Stat(filepath.Join(dir, entry.Name(), "device")); err != nil {
+			continue
+		}
+		nics = append(nics, entry.Name())
+		carrier, err := ioutil.ReadFile(filepath.Join(dir, entry.Name(), "carrier"))
+		if err == nil && strings.TrimSpace(string(carrier)) == "1" {
+			result.Status = StatusPass
+			result.Message = fmt.Sprintf("%s is connected", entry.Name())
+			return result
+		}
+	}
+	if len(nics) == 0 {
+		result.Status = StatusFail
+		result.Message = "no network interface found"
+		return result
+	}
+	result.Status = StatusWarn
+	result.Message = fmt.Sprintf("no link detected on %s", strings.Join(nics, ", "))
+	return result
+}
+
+// HasFailure returns true if any of the results failed
+func HasFailure(results []Result) bool {
+	for _, result := range results {
+		if result.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatResults renders the results as a table
+func FormatResults(results []Result) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	for _, result := range results {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", result.Status, result.Name, result.Message)
+	}
+	w.Flush()
+	return b.String()
+}
+
+// getDiskSize returns the size in bytes of the disk name, e.g. sda
+func (c *Checker) getDiskSize(name string) (uint64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(c.SysRoot, "block", name, "size"))
+	if err != nil {
+		return 0, err
+	}
+	// the size is always in 512 bytes sectors
+	sectors, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return sectors * 512, nil
+}
+
+func fail(result Result, err error) Result {
+	result.Status = StatusFail
+	result.Message = err.Error()
+	return result
+}
+
+// splitField splits a "key : value" line of the proc files
+func splitField(line string) (string, string) {
+	parts := strings.SplitN(line, ":", 2)
+	if len(parts) != 2 {
+		return strings.TrimSpace(line), ""
+	}
+	return strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+}
@@ -0,0 +1,215 @@
+package preflight
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type host struct {
+	cpus     int
+	flags    string
+	memoryKB uint64
+	kvm      bool
+	// disk name to size in sectors
+	disks map[string]uint64
+	// interface name to carrier, interfaces without carrier are virtual
+	nics map[string]string
+}
+
+func newTestChecker(t *testing.T, h host) (*Checker, func()) {
+	root, err := ioutil.TempDir("", "preflight")
+	assert.Nil(t, err)
+	c := &Checker{
+		ProcRoot: filepath.Join(root, "proc"),
+		SysRoot:  filepath.Join(root, "sys"),
+		DevRoot:  filepath.Join(root, "dev"),
+	}
+	writeFile := func(path, content string) {
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+
+	var cpuinfo strings.Builder
+	for i := 0; i < h.cpus; i++ {
+		fmt.Fprintf(&cpuinfo, "processor\t: %d\nflags\t\t: fpu vme de %s\n\n", i, h.flags)
+	}
+	writeFile(filepath.Join(c.ProcRoot, "cpuinfo"), cpuinfo.String())
+	writeFile(filepath.Join(c.ProcRoot, "meminfo"), fmt.Sprintf("MemTotal:       %d kB\nMemFree:         1024 kB\n", h.memoryKB))
+	assert.Nil(t, os.MkdirAll(c.DevRoot, 0755))
+	if h.kvm {
+		writeFile(filepath.Join(c.DevRoot, "kvm"), "")
+	}
+	for name, sectors := range h.disks {
+		writeFile(filepath.Join(c.SysRoot, "block", name, "size"), fmt.Sprintf("%d\n", sectors))
+		assert.Nil(t, os.MkdirAll(filepath.Join(c.SysRoot, "block", name, "device"), 0755))
+		writeFile(filepath.Join(c.DevRoot, name), "")
+	}
+	assert.Nil(t, os.MkdirAll(filepath.Join(c.SysRoot, "class", "net", "lo"), 0755))
+	for name, carrier := range h.nics {
+		writeFile(filepath.Join(c.SysRoot, "class", "net", name, "carrier"), carrier+"\n")
+		assert.Nil(t, os.MkdirAll(filepath.Join(c.SysRoot, "class", "net", name, "device"), 0755))
+	}
+	return c, func() {
+		os.RemoveAll(root)
+	}
+}
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		host     host
+		device   string
+		expected map[string]string
+	}{
+		{
+			Name: "recommended hardware",
+			host: host{
+				cpus:     8,
+				flags:    "vmx",
+				memoryKB: 32 * 1024 * 1024,
+				kvm:      true,
+				disks:    map[string]uint64{"sda": 500 * GiB / 512},
+				nics:     map[string]string{"eth0": "0", "eth1": "1"},
+			},
+			device: "/dev/sda",
+			expected: map[string]string{
+				"CPU virtualization": StatusPass,
+				"KVM device":         StatusPass,
+				"CPU count":          StatusPass,
+				"Memory":             StatusPass,
+				"Disk size":          StatusPass,
+				"Network link":       StatusPass,
+			},
+		},
+		{
+			Name: "minimum hardware",
+			host: host{
+				cpus:     4,
+				flags:    "svm",
+				memoryKB: 8 * 1024 * 1024,
+				disks:    map[string]uint64{"vda": 120 * GiB / 512},
+				nics:     map[string]string{"eth0": "0"},
+			},
+			device: "/dev/vda",
+			expected: map[string]string{
+				"CPU virtualization": StatusPass,
+				"KVM device":         StatusWarn,
+				"CPU count":          StatusWarn,
+				"Memory":             StatusWarn,
+				"Disk size":          StatusPass,
+				"Network link":       StatusWarn,
+			},
+		},
+		{
+			Name: "insufficient hardware",
+			host: host{
+				cpus:     2,
+				memoryKB: 4 * 1024 * 1024,
+				disks:    map[string]uint64{"sda": 60 * GiB / 512},
+			},
+			device: "/dev/sda",
+			expected: map[string]string{
+				"CPU virtualization": StatusFail,
+				"KVM device":         StatusWarn,
+				"CPU count":          StatusFail,
+				"Memory":             StatusFail,
+				"Disk size":          StatusFail,
+				"Network link":       StatusFail,
+			},
+		},
+		{
+			Name: "no device selected",
+			host: host{
+				cpus:     8,
+				flags:    "vmx",
+				memoryKB: 16 * 1024 * 1024,
+				kvm:      true,
+				disks:    map[string]uint64{"sda": 60 * GiB / 512, "sdb": 200 * GiB / 512},
+				nics:     map[string]string{"eth0": "1"},
+			},
+			expected: map[string]string{
+				"CPU virtualization": StatusPass,
+				"KVM device":         StatusPass,
+				"CPU count":          StatusPass,
+				"Memory":             StatusPass,
+				"Disk size":          StatusPass,
+				"Network link":       StatusPass,
+			},
+		},
+		{
+			Name: "no disk",
+			host: host{
+				cpus:     8,
+				flags:    "vmx",
+				memoryKB: 16 * 1024 * 1024,
+				kvm:      true,
+				nics:     map[string]string{"eth0": "1"},
+			},
+			expected: map[string]string{
+				"CPU virtualization": StatusPass,
+				"KVM device":         StatusPass,
+				"CPU count":          StatusPass,
+				"Memory":             StatusPass,
+				"Disk size":          StatusFail,
+				"Network link":       StatusPass,
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		c, cleanup := newTestChecker(t, testCase.host)
+		statuses := map[string]string{}
+		for _, result := range c.Run(testCase.device) {
+			statuses[result.Name] = result.Status
+		}
+		cleanup()
+		assert.Equal(t, testCase.expected, statuses, testCase.Name)
+	}
+}
+
+func TestCheckDiskSize(t *testing.T) {
+	c, cleanup := newTestChecker(t, host{
+		disks: map[string]uint64{"sda": 500 * GiB / 512, "sdb": 60 * GiB / 512},
+	})
+	defer cleanup()
+	assert.Nil(t, os.MkdirAll(filepath.Join(c.DevRoot, "disk", "by-id"), 0755))
+	assert.Nil(t, os.Symlink("../../sda", filepath.Join(c.DevRoot, "disk", "by-id", "ata-disk1")))
+
+	result := c.CheckDiskSize("/dev/disk/by-id/ata-disk1")
+	assert.Equal(t, StatusPass, result.Status)
+	assert.Equal(t, "/dev/disk/by-id/ata-disk1 500.0 GiB", result.Message)
+	result = c.CheckDiskSize("/dev/sdb")
+	assert.Equal(t, StatusFail, result.Status)
+
+	result = c.CheckDisks()
+	assert.Equal(t, StatusPass, result.Status)
+	assert.Equal(t, "sda 500.0 GiB", result.Message)
+
+	// the installer media doesn't count, even when it is the largest disk
+	iso := make([]byte, 34816)
+	copy(iso[32769:], "CD001")
+	copy(iso[32808:], "K3OS")
+	assert.Nil(t, ioutil.WriteFile(filepath.Join(c.DevRoot, "sda"), iso, 0644))
+	result = c.CheckDisks()
+	assert.Equal(t, StatusFail, result.Status)
+	assert.Equal(t, "sdb 60.0 GiB, at least 120.0 GiB required", result.Message)
+}
+
+func TestHasFailure(t *testing.T) {
+	assert.False(t, HasFailure([]Result{{Status: StatusPass}, {Status: StatusWarn}}))
+	assert.True(t, HasFailure([]Result{{Status: StatusPass}, {Status: StatusFail}}))
+}
+
+func TestFormatResults(t *testing.T) {
+	results := []Result{
+		{Name: "CPU count", Status: StatusPass, Message: "8 CPUs"},
+		{Name: "Network link", Status: StatusWarn, Message: "no link detected on eth0"},
+	}
+	expected := "PASS  CPU count     8 CPUs\nWARN  Network link  no link detected on eth0\n"
+	assert.Equal(t, expected, FormatResults(results))
+}
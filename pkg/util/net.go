@@ -0,0 +1,100 @@
+package util
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+var (
+	checkTimeout = 3 * time.Second
+	// requests through a proxy take longer, e.g. to resolve the target
+	proxyCheckTimeout = 10 * time.Second
+	// a name that never resolves, a NXDOMAIN answer still proves the server is reachable
+	dnsCheckName = "harvester-dns-check.invalid"
+)
+
+// CheckDNSServer sends a query to the DNS server at address and returns an error if
+// it doesn't answer
+func CheckDNSServer(address string) error {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			d := net.Dialer{Timeout: checkTimeout}
+			return d.DialContext(ctx, network, net.JoinHostPort(address, "53"))
+		},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	_, err := resolver.LookupHost(ctx, dnsCheckName)
+	if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+		return nil
+	}
+	return err
+}
+
+// CheckNTPServer sends a SNTP request to the NTP server at address and returns an
+// error if it doesn't answer
+func CheckNTPServer(address string) error {
+	return checkNTPServer(net.JoinHostPort(address, "123"))
+}
+
+func checkNTPServer(hostPort string) error {
+	conn, err := net.DialTimeout("udp", hostPort, checkTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(checkTimeout)); err != nil {
+		return err
+	}
+	// LI = 0, VN = 3, Mode = 3 (client)
+	req := make([]byte, 48)
+	req[0] = 0x1b
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return err
+	}
+	if n < 48 || resp[0]&0x07 != 4 {
+		return fmt.Errorf("got invalid NTP response from %s", hostPort)
+	}
+	return nil
+}
+
+// CheckProxy sends a request to target through the proxy and returns an error if
+// it doesn't get a response from the target
+func CheckProxy(proxy, target string) error {
+	proxyURL, err := url.Parse(proxy)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{
+		Timeout: proxyCheckTimeout,
+		Transport: &http.Transport{
+			Proxy: http.ProxyURL(proxyURL),
+		},
+		// a redirect is a response of the target already
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(target)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		return fmt.Errorf("proxy authentication required")
+	}
+	if resp.StatusCode == http.StatusBadGateway || resp.StatusCode == http.StatusGatewayTimeout {
+		return fmt.Errorf("proxy failed to reach %s: %s", target, resp.Status)
+	}
+	return nil
+}
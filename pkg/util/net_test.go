@@ -0,0 +1,50 @@
+package util
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckNTPServer(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	go func() {
+		buf := make([]byte, 48)
+		_, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		// LI = 0, VN = 3, Mode = 4 (server)
+		resp := make([]byte, 48)
+		resp[0] = 0x1c
+		conn.WriteTo(resp, addr)
+	}()
+	assert.Nil(t, checkNTPServer(conn.LocalAddr().String()))
+}
+
+func TestCheckProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Header.Get("Proxy-Authorization") == "":
+			w.WriteHeader(http.StatusProxyAuthRequired)
+		case r.URL.Host != "example.com":
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer proxy.Close()
+	proxyURL := "http://user:pass@" + proxy.Listener.Addr().String()
+
+	assert.Nil(t, CheckProxy(proxyURL, "http://example.com/"))
+	assert.NotNil(t, CheckProxy(proxyURL, "http://unreachable.example.com/"))
+	assert.NotNil(t, CheckProxy(proxy.URL, "http://example.com/"))
+
+	proxy.Close()
+	assert.NotNil(t, CheckProxy(proxyURL, "http://example.com/"))
+}
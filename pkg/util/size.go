@@ -0,0 +1,20 @@
+package util
+
+import (
+	"fmt"
+)
+
+// FormatSize formats a size in bytes with binary units, e.g. 1.5 GiB
+func FormatSize(size uint64) string {
+	units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB"}
+	value := float64(size)
+	i := 0
+	for value >= 1024 && i < len(units)-1 {
+		value /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%d B", size)
+	}
+	return fmt.Sprintf("%.1f %s", value, units[i])
+}
@@ -1,6 +1,8 @@
 package widgets
 
 import (
+	"fmt"
+
 	"github.com/jroimartin/gocui"
 )
 
@@ -8,6 +10,8 @@ type Input struct {
 	*Panel
 
 	Mask bool
+	// Value is the initial text of the input
+	Value string
 }
 
 func NewInput(g *gocui.Gui, name string, label string, mask bool) (*Input, error) {
@@ -50,6 +54,14 @@ func (i *Input) Show() error {
 		if i.Mask {
 			v.Mask ^= '*'
 		}
+		if i.Value != "" {
+			if _, err := fmt.Fprint(v, i.Value); err != nil {
+				return err
+			}
+			if err := moveCursorToEnd(v, len(i.Value)); err != nil {
+				return err
+			}
+		}
 		if i.KeyBindings != nil {
 			for key, f := range i.KeyBindings {
 				if err := i.g.SetKeybinding(inputViewName, key, gocui.ModNone, f); err != nil {
@@ -90,3 +102,31 @@ func (i *Input) GetData() (string, error) {
 	}
 	return ov.Line(0)
 }
+
+// SetData replaces the text of the input
+func (i *Input) SetData(data string) error {
+	inputViewName := i.Name + "-input"
+	ov, err := i.g.View(inputViewName)
+	if err != nil {
+		return err
+	}
+	ov.Clear()
+	if _, err := fmt.Fprint(ov, data); err != nil {
+		return err
+	}
+	return moveCursorToEnd(ov, len(data))
+}
+
+// moveCursorToEnd puts the cursor after the text of n characters, the text is
+// scrolled when it is longer than the view
+func moveCursorToEnd(v *gocui.View, n int) error {
+	maxX, _ := v.Size()
+	ox := 0
+	if n >= maxX {
+		ox = n - maxX + 1
+	}
+	if err := v.SetOrigin(ox, 0); err != nil {
+		return err
+	}
+	return v.SetCursor(n-ox, 0)
+}
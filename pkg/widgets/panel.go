@@ -109,7 +109,10 @@ func (p *Panel) SetContent(content string) {
 	p.Content = content
 	p.g.Update(func(g *gocui.Gui) error {
 		v, err := p.g.View(p.Name)
-		if err != nil && err != gocui.ErrUnknownView {
+		if err == gocui.ErrUnknownView {
+			// closed before the update
+			return nil
+		} else if err != nil {
 			return err
 		}
 		v.Clear()
@@ -0,0 +1,45 @@
+package widgets
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jroimartin/gocui"
+)
+
+// ProgressBar is a panel showing a text line above a progress bar
+type ProgressBar struct {
+	*Panel
+}
+
+func NewProgressBar(g *gocui.Gui, name string) *ProgressBar {
+	return &ProgressBar{
+		Panel: &Panel{
+			Name: name,
+			g:    g,
+		},
+	}
+}
+
+// SetProgress shows text and a bar filled to percent, info is shown right to the bar
+func (p *ProgressBar) SetProgress(text string, percent int, info string) {
+	width := p.X1 - p.X0 - 1
+	p.SetContent(text + "\n" + FormatProgressBar(percent, width-len(info)-1) + " " + info)
+}
+
+// FormatProgressBar renders a progress bar of width characters, e.g. [#####     ]  50%
+func FormatProgressBar(percent int, width int) string {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	label := fmt.Sprintf(" %3d%%", percent)
+	// the brackets and the label take space from the bar
+	size := width - len(label) - 2
+	if size < 1 {
+		return strings.TrimSpace(label)
+	}
+	filled := size * percent / 100
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(" ", size-filled) + "]" + label
+}
@@ -0,0 +1,44 @@
+package widgets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatProgressBar(t *testing.T) {
+	testCases := []struct {
+		Name    string
+		percent int
+		width   int
+		output  string
+	}{
+		{
+			Name:    "empty",
+			percent: 0,
+			width:   17,
+			output:  "[          ]   0%",
+		},
+		{
+			Name:    "half",
+			percent: 50,
+			width:   17,
+			output:  "[#####     ]  50%",
+		},
+		{
+			Name:    "overflow",
+			percent: 120,
+			width:   17,
+			output:  "[##########] 100%",
+		},
+		{
+			Name:    "too narrow",
+			percent: 50,
+			width:   5,
+			output:  "50%",
+		},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.output, FormatProgressBar(testCase.percent, testCase.width), testCase.Name)
+	}
+}
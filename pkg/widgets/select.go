@@ -17,6 +17,10 @@ type GetOptionsFunc func() ([]Option, error)
 type Select struct {
 	*Panel
 
+	// Selected is the value of the option which the cursor is put on when the
+	// options are shown, the cursor is on the first option if none matches
+	Selected string
+
 	getOptionsFunc GetOptionsFunc
 	options        []Option
 	optionV        *gocui.View
@@ -47,6 +51,10 @@ func (s *Select) Show() error {
 	offset := len(strings.Split(s.Content, "\n"))
 	y0 := s.Y0 + offset - 1
 	y1 := s.Y0 + offset + len(s.options) + 2
+	// long lists scroll within the screen
+	if _, maxY := s.g.Size(); y1 > maxY-1 && maxY-1 > y0+1 {
+		y1 = maxY - 1
+	}
 	v, err := s.g.SetView(optionViewName, s.X0, y0, s.X1, y1)
 	if err != nil {
 		if err != gocui.ErrUnknownView {
@@ -75,6 +83,11 @@ func (s *Select) Show() error {
 				}
 			}
 		}
+		for i, opt := range s.options {
+			if s.Selected != "" && opt.Value == s.Selected {
+				return s.SetCursor(i)
+			}
+		}
 	}
 	return nil
 }
@@ -97,14 +110,37 @@ func (s *Select) GetData() (string, error) {
 	if len(ov.BufferLines()) == 0 {
 		return "", nil
 	}
+	_, oy := ov.Origin()
 	_, cy := ov.Cursor()
 	var value string
-	if len(s.options) >= cy+1 {
-		value = s.options[cy].Value
+	if len(s.options) >= oy+cy+1 {
+		value = s.options[oy+cy].Value
 	}
 	return value, nil
 }
 
+// SetCursor moves the cursor to the option at index
+func (s *Select) SetCursor(index int) error {
+	optionViewName := s.Name + "-options"
+	ov, err := s.g.View(optionViewName)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= len(s.options) {
+		return nil
+	}
+	// scroll the option into the view
+	_, height := ov.Size()
+	origin := 0
+	if index >= height {
+		origin = index - height + 1
+	}
+	if err := ov.SetOrigin(0, origin); err != nil {
+		return err
+	}
+	return ov.SetCursor(0, index-origin)
+}
+
 func setOptionsKeyBindings(g *gocui.Gui, viewName string) error {
 	if err := g.SetKeybinding(viewName, gocui.KeyArrowUp, gocui.ModNone, ArrowUp); err != nil {
 		return err
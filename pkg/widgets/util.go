@@ -35,17 +35,19 @@ func ArrowDown(g *gocui.Gui, v *gocui.View) error {
 }
 
 func isAtTop(v *gocui.View) bool {
+	_, oy := v.Origin()
 	_, cy := v.Cursor()
-	if cy == 0 {
+	if oy+cy == 0 {
 		return true
 	}
 	return false
 }
 
 func isAtEnd(v *gocui.View) bool {
+	_, oy := v.Origin()
 	_, cy := v.Cursor()
 	lines := len(v.BufferLines())
-	if lines < 2 || cy == lines-2 {
+	if lines < 2 || oy+cy == lines-2 {
 		return true
 	}
 	return false